@@ -3,6 +3,7 @@ module github.com/adamsanghera/go-websub
 require (
 	github.com/golang/protobuf v1.3.0
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.10.0
 	github.com/peterhellberg/link v1.0.0
 	github.com/spf13/cobra v0.0.3
@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+)
+
+// Cache remembers the result of discovering each topic, keyed by the url that the topic was discovered at.
+// Results are reused for as long as their caching headers allow. Once stale, they are refreshed with
+// conditional requests, so that topics which haven't changed only need to answer with a 304 Not Modified.
+type Cache struct {
+	discoverer *Discoverer
+
+	mut     sync.Mutex
+	results map[string]*Result
+}
+
+// NewCache returns an empty Cache, which discovers topics with the given Discoverer (or the default one, if nil)
+func NewCache(d *Discoverer) *Cache {
+	if d == nil {
+		d = NewDiscoverer()
+	}
+	return &Cache{
+		discoverer: d,
+		results:    make(map[string]*Result),
+	}
+}
+
+// Lookup returns the cached result for the topic url (nil if there is none), and whether it is still fresh.
+// Results are shared between callers, and must not be modified.
+func (c *Cache) Lookup(topicURL string) (*Result, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	res, ok := c.results[topicURL]
+	if !ok {
+		return nil, false
+	}
+	return res, res.Fresh()
+}
+
+// Discover returns the cached result for the topic url, if it is still fresh.
+// Otherwise the topic is discovered again, conditionally if a stale result is cached, and the cache is updated.
+// A failed discovery leaves the stale result in the cache.
+func (c *Cache) Discover(ctx context.Context, topicURL string) (*Result, error) {
+	prev, fresh := c.Lookup(topicURL)
+	if fresh {
+		return prev, nil
+	}
+
+	res, err := c.discoverer.discover(ctx, topicURL, prev)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Responses that forbid storing them are handed to the caller, but forgotten
+	if _, noStore := parseCacheControl(res.CacheControl)["no-store"]; noStore {
+		delete(c.results, topicURL)
+	} else {
+		c.results[topicURL] = res
+	}
+	return res, nil
+}
+
+// Invalidate forgets the cached result for the topic url, so that the next discovery starts from scratch
+func (c *Cache) Invalidate(topicURL string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	delete(c.results, topicURL)
+}
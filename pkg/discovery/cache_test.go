@@ -0,0 +1,134 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResult_Expiration(t *testing.T) {
+	fetched := time.Date(2018, 7, 16, 23, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name         string
+		cacheControl string
+		expires      string
+		expected     time.Time
+	}{
+		{"No caching headers", "", "", fetched},
+		{"max-age", "public, max-age=60", "", fetched.Add(time.Minute)},
+		{"max-age beats Expires", "max-age=60", "Mon, 16 Jul 2018 23:30:00 GMT", fetched.Add(time.Minute)},
+		{"Expires", "", "Mon, 16 Jul 2018 23:30:00 GMT", fetched.Add(30 * time.Minute)},
+		{"Invalid Expires", "", "0", fetched},
+		{"no-cache", "no-cache, max-age=60", "", fetched},
+		{"no-store", "No-Store", "Mon, 16 Jul 2018 23:30:00 GMT", fetched},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := &Result{CacheControl: c.cacheControl, Expires: c.expires, Fetched: fetched}
+			if exp := res.Expiration(); !exp.Equal(c.expected) {
+				t.Fatalf("Expected expiration {%v} but got {%v}", c.expected, exp)
+			}
+		})
+	}
+}
+
+func TestCache_Discover(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	requests := make(map[string]int)
+	notModified := make(map[string]int)
+	topic := func(path string, headers map[string]string, unchanged func(req *http.Request) bool) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+			requests[path]++
+			for k, v := range headers {
+				w.Header().Set(k, v)
+			}
+			if unchanged(req) {
+				notModified[path]++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Link", `<https://hub.example.com/>; rel="hub", <http://example.com`+path+`>; rel="self"`)
+		})
+	}
+	etagMatches := func(req *http.Request) bool { return req.Header.Get("If-None-Match") == `"v1"` }
+	modifiedSince := func(req *http.Request) bool { return req.Header.Get("If-Modified-Since") != "" }
+
+	topic("/fresh", map[string]string{"ETag": `"v1"`, "Cache-Control": "max-age=60"}, etagMatches)
+	topic("/etag", map[string]string{"ETag": `"v1"`}, etagMatches)
+	topic("/modified", map[string]string{"Last-Modified": "Mon, 16 Jul 2018 23:02:50 GMT"}, modifiedSince)
+	topic("/no-store", map[string]string{"ETag": `"v1"`, "Cache-Control": "no-store"}, etagMatches)
+
+	cache := NewCache(&Discoverer{Client: srv.Client()})
+	discoverTwice := func(t *testing.T, path string) (*Result, *Result) {
+		first, err := cache.Discover(context.Background(), srv.URL+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := cache.Discover(context.Background(), srv.URL+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(second.Hubs) != 1 || second.Hubs[0].URL != "https://hub.example.com/" || second.Self != first.Self {
+			t.Fatalf("Expected the links of the first discovery, but got %+v", second)
+		}
+		return first, second
+	}
+
+	t.Run("Fresh results are reused without a request", func(t *testing.T) {
+		first, second := discoverTwice(t, "/fresh")
+		if requests["/fresh"] != 1 || first != second {
+			t.Fatalf("Expected a single request, but got %d", requests["/fresh"])
+		}
+		if _, fresh := cache.Lookup(srv.URL + "/fresh"); !fresh {
+			t.Fatal("Expected the cached result to be fresh")
+		}
+	})
+
+	t.Run("Stale results are revalidated by ETag", func(t *testing.T) {
+		first, second := discoverTwice(t, "/etag")
+		if requests["/etag"] != 2 || notModified["/etag"] != 1 {
+			t.Fatalf("Expected a conditional request, but got %d requests and %d 304s", requests["/etag"], notModified["/etag"])
+		}
+		if first.NotModified || !second.NotModified || second.ETag != `"v1"` {
+			t.Fatalf("Expected the second result to come from a 304, but got %+v", second)
+		}
+		if cached, fresh := cache.Lookup(srv.URL + "/etag"); cached != second || fresh {
+			t.Fatal("Expected the revalidated (but still stale) result to be cached")
+		}
+	})
+
+	t.Run("Stale results are revalidated by Last-Modified", func(t *testing.T) {
+		_, second := discoverTwice(t, "/modified")
+		if notModified["/modified"] != 1 || !second.NotModified {
+			t.Fatalf("Expected a conditional request, but got %d 304s", notModified["/modified"])
+		}
+	})
+
+	t.Run("no-store results are not cached", func(t *testing.T) {
+		discoverTwice(t, "/no-store")
+		if requests["/no-store"] != 2 || notModified["/no-store"] != 0 {
+			t.Fatalf("Expected two unconditional requests, but got %d requests and %d 304s", requests["/no-store"], notModified["/no-store"])
+		}
+		if cached, _ := cache.Lookup(srv.URL + "/no-store"); cached != nil {
+			t.Fatal("Expected nothing to be cached")
+		}
+	})
+
+	t.Run("Invalidated results are discovered from scratch", func(t *testing.T) {
+		cache.Invalidate(srv.URL + "/etag")
+		res, err := cache.Discover(context.Background(), srv.URL+"/etag")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.NotModified || notModified["/etag"] != 1 {
+			t.Fatal("Expected an unconditional request")
+		}
+	})
+}
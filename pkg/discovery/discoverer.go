@@ -0,0 +1,261 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxBodySize is the most of a topic's body that a Discoverer reads, unless configured otherwise
+const DefaultMaxBodySize = 1 << 20
+
+// Source identifies where a discovered link was found
+type Source int
+
+const (
+	// SourceHeader links were found in the Link header of the response
+	SourceHeader Source = iota
+	// SourceHTML links were found in the <head> of an html body
+	SourceHTML
+	// SourceXML links were found in an Atom or RSS feed
+	SourceXML
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceHeader:
+		return "header"
+	case SourceHTML:
+		return "html"
+	case SourceXML:
+		return "xml"
+	}
+	return "unknown"
+}
+
+// Link is a single link discovered for a topic
+type Link struct {
+	URL    string
+	Source Source
+}
+
+// Result is everything learned about a topic by discovering it
+type Result struct {
+	Hubs []Link // the hubs of the topic, in the order that the topic lists them
+	Self Link   // the canonical url of the topic
+
+	FinalURL string // the url that the topic was fetched from, after following redirects
+
+	// Caching headers of the response, which tell us how long the result stays fresh
+	ETag         string
+	LastModified string
+	CacheControl string
+	Expires      string
+
+	Fetched     time.Time // when the response was received
+	NotModified bool      // whether the topic answered a conditional request with 304, leaving the links of an earlier result
+}
+
+// Expiration returns the moment that the result goes stale, according to the caching headers of its response.
+// Cache-Control's max-age takes priority over Expires. Results that must be revalidated before reuse
+// (no-cache, no-store, or no freshness information at all) are stale as soon as they're fetched.
+func (res *Result) Expiration() time.Time {
+	directives := parseCacheControl(res.CacheControl)
+	if _, ok := directives["no-store"]; ok {
+		return res.Fetched
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return res.Fetched
+	}
+
+	if maxAge, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.Atoi(maxAge); err == nil && seconds > 0 {
+			return res.Fetched.Add(time.Duration(seconds) * time.Second)
+		}
+		return res.Fetched
+	}
+
+	if expires, err := http.ParseTime(res.Expires); err == nil {
+		return expires
+	}
+	return res.Fetched
+}
+
+// Fresh reports whether the result may still be used without asking the topic again
+func (res *Result) Fresh() bool {
+	return time.Now().Before(res.Expiration())
+}
+
+// parseCacheControl splits a Cache-Control header into its (lowercased) directives and their arguments
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, directive := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if kv[0] == "" {
+			continue
+		}
+		if len(kv) == 1 {
+			directives[strings.ToLower(kv[0])] = ""
+		} else {
+			directives[strings.ToLower(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		}
+	}
+	return directives
+}
+
+// Discoverer fetches topics, and discovers their hubs and self urls
+type Discoverer struct {
+	Client      *http.Client  // the client that topics are fetched with (http.DefaultClient if nil)
+	Timeout     time.Duration // the longest that a single discovery may take (no limit if zero)
+	MaxBodySize int64         // the most of a topic's body that is read (DefaultMaxBodySize if zero)
+	UserAgent   string        // the User-Agent header sent to topics (Go's default if empty)
+}
+
+// NewDiscoverer returns a Discoverer with the default configuration
+func NewDiscoverer() *Discoverer {
+	return &Discoverer{
+		Client:      http.DefaultClient,
+		MaxBodySize: DefaultMaxBodySize,
+	}
+}
+
+// Discover fetches the topic at the given url, and discovers its hubs and self url.
+// Links in the Link header take priority over links in the body, as the spec requires;
+// the body is only parsed (as html, or as an Atom or RSS feed) if the header lacks a self link.
+func (d *Discoverer) Discover(ctx context.Context, topicURL string) (*Result, error) {
+	return d.discover(ctx, topicURL, nil)
+}
+
+// discover fetches and discovers the topic like Discover does.
+// If an earlier result is given, the request is conditional on the topic having changed since,
+// and a 304 response returns a copy of the earlier result, with its caching headers refreshed.
+func (d *Discoverer) discover(ctx context.Context, topicURL string, prev *Result) (*Result, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	// Form the request
+	req, err := http.NewRequest("GET", topicURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if d.UserAgent != "" {
+		req.Header.Set("User-Agent", d.UserAgent)
+	}
+	if prev != nil {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+
+	// Make the request
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	res := &Result{
+		FinalURL:     topicURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		CacheControl: resp.Header.Get("Cache-Control"),
+		Expires:      resp.Header.Get("Expires"),
+		Fetched:      time.Now(),
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		res.FinalURL = resp.Request.URL.String()
+	}
+
+	if resp.StatusCode == http.StatusNotModified && prev != nil {
+		return prev.revalidated(res), nil
+	}
+
+	// Relative links are relative to the url that the topic was fetched from
+	base, err := url.Parse(res.FinalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// If header contains links, try to get them there.
+	if _, ok := resp.Header["Link"]; ok {
+		hubs, self := parseFromHeader(resp.Header, base)
+		if self != "" {
+			res.setLinks(hubs, self, SourceHeader)
+			return res, nil
+		}
+	}
+
+	maxBodySize := d.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	body := io.LimitReader(resp.Body, maxBodySize)
+
+	// If the goods weren't in the header, go deeper
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if strings.Contains(contentType, "text/html") {
+		hubs, self, err := parseLinksFromHTML(body, base)
+		if err != nil {
+			return nil, err
+		}
+		res.setLinks(hubs, self, SourceHTML)
+		return res, nil
+	} else if _, ok := feedMediaTypes[mediaType]; ok {
+		hubs, self, err := parseLinksFromXML(body, base)
+		if err != nil {
+			return nil, err
+		}
+		res.setLinks(hubs, self, SourceXML)
+		return res, nil
+	}
+
+	return nil, errors.New("Response from URL provided was not parseable")
+}
+
+// revalidated returns a copy of the result, refreshed by the given response to a conditional request.
+// Caching headers that the 304 response left out keep their earlier values.
+func (res *Result) revalidated(notModified *Result) *Result {
+	fresh := *res
+	fresh.Hubs = append([]Link(nil), res.Hubs...)
+	fresh.FinalURL = notModified.FinalURL
+	fresh.Fetched = notModified.Fetched
+	fresh.NotModified = true
+
+	if notModified.ETag != "" {
+		fresh.ETag = notModified.ETag
+	}
+	if notModified.LastModified != "" {
+		fresh.LastModified = notModified.LastModified
+	}
+	if notModified.CacheControl != "" {
+		fresh.CacheControl = notModified.CacheControl
+	}
+	if notModified.Expires != "" {
+		fresh.Expires = notModified.Expires
+	}
+	return &fresh
+}
+
+func (res *Result) setLinks(hubs []string, self string, source Source) {
+	for _, hub := range hubs {
+		res.Hubs = append(res.Hubs, Link{URL: hub, Source: source})
+	}
+	res.Self = Link{URL: self, Source: source}
+}
@@ -0,0 +1,104 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoverer_Discover(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "/feed", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, req *http.Request) {
+		if ua := req.Header.Get("User-Agent"); ua != "go-websub-test" {
+			t.Errorf("Expected User-Agent {go-websub-test} but got {%v}", ua)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 16 Jul 2018 23:02:50 GMT")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(`<html><head>
+			<link rel="hub" href="https://hub.example.com/b">
+			<link rel="hub" href="https://hub.example.com/a">
+			<link rel="hub" href="https://hub.example.com/b">
+			<link rel="self" href="http://example.com/feed">
+		</head></html>`))
+	})
+	mux.HandleFunc("/header", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Link", `<https://hub.example.com/>; rel="hub", <http://example.com/header>; rel="self"`)
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>" + strings.Repeat("a", 2048) + "</title>"))
+		w.Write([]byte(`<link rel="self" href="http://example.com/huge"></head></html>`))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	d := &Discoverer{
+		Client:      srv.Client(),
+		Timeout:     100 * time.Millisecond,
+		MaxBodySize: 1024,
+		UserAgent:   "go-websub-test",
+	}
+
+	t.Run("Hubs in document order, after redirects", func(t *testing.T) {
+		res, err := d.Discover(context.Background(), srv.URL+"/moved")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []Link{{"https://hub.example.com/b", SourceHTML}, {"https://hub.example.com/a", SourceHTML}}
+		if len(res.Hubs) != len(expected) {
+			t.Fatalf("Expected hubs %v but got %v", expected, res.Hubs)
+		}
+		for idx := range expected {
+			if res.Hubs[idx] != expected[idx] {
+				t.Fatalf("Expected hubs %v but got %v", expected, res.Hubs)
+			}
+		}
+		if res.Self != (Link{"http://example.com/feed", SourceHTML}) {
+			t.Fatalf("Unexpected self link {%v}", res.Self)
+		}
+		if res.FinalURL != srv.URL+"/feed" {
+			t.Fatalf("Expected final url {%v} but got {%v}", srv.URL+"/feed", res.FinalURL)
+		}
+		if res.ETag != `"v1"` || res.LastModified != "Mon, 16 Jul 2018 23:02:50 GMT" || res.CacheControl != "max-age=60" {
+			t.Fatalf("Caching headers were not carried over: %+v", res)
+		}
+	})
+
+	t.Run("Links from the header", func(t *testing.T) {
+		res, err := d.Discover(context.Background(), srv.URL+"/header")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Hubs) != 1 || res.Hubs[0] != (Link{"https://hub.example.com/", SourceHeader}) {
+			t.Fatalf("Unexpected hubs %v", res.Hubs)
+		}
+		if res.Self.Source != SourceHeader {
+			t.Fatalf("Expected self link from the %v, but got it from the %v", SourceHeader, res.Self.Source)
+		}
+	})
+
+	t.Run("Bodies are read up to the max size", func(t *testing.T) {
+		if _, err := d.Discover(context.Background(), srv.URL+"/huge"); err == nil {
+			t.Fatal("Expected links past the max body size to go unseen")
+		}
+	})
+
+	t.Run("Discovery times out", func(t *testing.T) {
+		if _, err := d.Discover(context.Background(), srv.URL+"/slow"); err == nil {
+			t.Fatal("Expected discovery to time out")
+		}
+	})
+}
@@ -1,141 +1,211 @@
 package discovery
 
 import (
+	"context"
+	"encoding/xml"
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
-	"github.com/peterhellberg/link"
 	"golang.org/x/net/html"
 )
 
+// atomNamespace is the namespace of Atom elements, which RSS feeds borrow their atom:link elements from
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// feedMediaTypes are the content types that are parsed as Atom or RSS feeds
+var feedMediaTypes = map[string]struct{}{
+	"application/atom+xml": {},
+	"application/rss+xml":  {},
+	"application/xml":      {},
+	"text/xml":             {},
+}
+
 // DiscoverTopic is a request to a given topic url.
 //
 // Recipient: typically a publisher, but hubs implement it too.
 // Response: a list of hubs who forward the content associated with this topic
+//
+// DiscoverTopic uses the default Discoverer, see Discoverer.Discover for more control over discovery.
 func DiscoverTopic(topic string) (map[string]struct{}, string, error) {
-	// Form the request
-	req, err := http.NewRequest("GET", topic, nil)
+	res, err := NewDiscoverer().Discover(context.Background(), topic)
 	if err != nil {
 		return make(map[string]struct{}), "", err
 	}
 
-	// Make the request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return make(map[string]struct{}), "", err
+	hubURLs := make(map[string]struct{})
+	for _, hub := range res.Hubs {
+		hubURLs[hub.URL] = struct{}{}
 	}
+	return hubURLs, res.Self.URL, nil
+}
 
-	contentType := resp.Header.Get("Content-Type")
-
-	// If header contains links, try to get them there.
-	if _, ok := resp.Header["Link"]; ok {
-		hubs, self := parseFromHeader(resp.Header)
-		if self != "" {
-			return hubs, self, nil
-		}
+// Parse links from the header of an http reply, resolving them against the given base url.
+// Will return an empty list and an empty string if no link headers exist
+func parseFromHeader(header http.Header, base *url.URL) ([]string, string) {
+	var links rawLinks
+	for _, l := range parseLinkHeader(header) {
+		links = append(links, rawLink{rels: l.rels, href: l.target})
 	}
 
-	// If the goods weren't in the header, go deeper
-	if strings.Contains(contentType, "text/html") {
-		return parseLinksFromHTML(resp.Body)
-	} else if strings.Contains(contentType, "text/xml") {
-		// TODO(adam) handle xml [atom, rss, etc.]
-	}
+	return links.resolve(base)
+}
 
-	return make(map[string]struct{}), "", errors.New("Response from URL provided was not parseable")
+// rawLink is a link as it appears in a response, before it is resolved
+type rawLink struct {
+	rels []string
+	href string
 }
 
-// Parse links from the header of an http reply
-// Will return an un-init'd map and an empty string if no link headers exist
-func parseFromHeader(header http.Header) (map[string]struct{}, string) {
-	hubURLs := make(map[string]struct{})
-	selfURL := ""
-	group := link.ParseHeader(header)
-
-	for _, link := range group {
-		switch link.Rel {
-		case "self":
-			selfURL = link.URI
-		case "hub":
-			hubURLs[link.URI] = struct{}{}
+// rawLinks are the links found in a response, in the order that they appear
+type rawLinks []rawLink
+
+// resolve resolves the links against the given base url, and picks out the hubs (in order, without duplicates)
+// and the first self link. Links that are not valid urls are dropped.
+func (links rawLinks) resolve(base *url.URL) (hubURLs []string, selfURL string) {
+	for _, l := range links {
+		href, ok := resolveReference(base, l.href)
+		if !ok {
+			continue
+		}
+
+		for _, rel := range l.rels {
+			switch rel {
+			case "hub":
+				hubURLs = appendUnique(hubURLs, href)
+			case "self":
+				if selfURL == "" {
+					selfURL = href
+				}
+			}
 		}
 	}
 
 	return hubURLs, selfURL
 }
 
-// Parse links from the body of an http reply, assumes that the body is in html
-// TODO(adam) make this code much more legible
-func parseLinksFromHTML(htmlReader io.Reader) (map[string]struct{}, string, error) {
+// appendUnique appends the url to the list, unless it is already in there
+func appendUnique(urls []string, url string) []string {
+	for _, u := range urls {
+		if u == url {
+			return urls
+		}
+	}
+	return append(urls, url)
+}
+
+// Parse links from the body of an http reply, assumes that the body is in html.
+// Only links within the <head> count, and they are resolved against its <base> (if any), or else the given base url.
+func parseLinksFromHTML(htmlReader io.Reader, base *url.URL) ([]string, string, error) {
 	tokenizer := html.NewTokenizer(htmlReader)
 
-	hubURLs := make(map[string]struct{})
-	selfURL := ""
-	inHead := false
-	parsing := true
-	for parsing {
-		tt := tokenizer.Next()
-		switch tt {
+	var links rawLinks
+	inHead, baseSeen := false, false
+	for parsing := true; parsing; {
+		switch tokenizer.Next() {
 
 		// We're looking for links embedded in heads
-		case html.StartTagToken:
+		case html.StartTagToken, html.SelfClosingTagToken:
 			t := tokenizer.Token()
 			if t.Data == "head" {
 				inHead = true
-			} else if t.Data == "link" && inHead {
-				// We've found a link tag, now we need to validate that it has the following components:
-				// 1. rel, which is one of (a) hub or (b) self
-				// 2. href, which is a valid url
-				var href string
-				isHub := false
-				isSelf := false
-				for _, a := range t.Attr {
-					if a.Key == "rel" {
-						isHub = a.Val == "hub"
-						isSelf = a.Val == "self"
-						if !(isHub || isSelf) {
-							break
-						}
-					} else if a.Key == "href" {
-						href = a.Val
-					}
-				}
-				if isHub || isSelf && len(href) != 0 {
-					if isHub {
-						hubURLs[href] = struct{}{}
-					} else {
-						selfURL = href
-					}
+			}
+			if !inHead {
+				continue
+			}
+
+			var rel, href string
+			hasHref := false
+			for _, a := range t.Attr {
+				switch a.Key {
+				case "rel":
+					rel = a.Val
+				case "href":
+					href, hasHref = a.Val, true
 				}
+			}
 
+			switch {
+			case t.Data == "link":
+				// rel may be a space-separated list of relation types, e.g. rel="hub self"
+				links = append(links, rawLink{rels: strings.Fields(strings.ToLower(rel)), href: href})
+			case t.Data == "base" && hasHref && !baseSeen:
+				// Only the first <base> counts, and it applies to every link in the document
+				baseSeen = true
+				if resolved, ok := resolveReference(base, href); ok {
+					base, _ = url.Parse(resolved)
+				}
 			}
+
 		// Stop parsing once we exit the head
 		case html.EndTagToken:
 			tn, _ := tokenizer.TagName()
-			if len(tn) == 4 {
-				if string(tn) == "html" {
-
-					parsing = false
-					break
-				}
-				if string(tn) == "head" {
-					inHead = false
-					parsing = false
-					break
-				}
+			if string(tn) == "html" || string(tn) == "head" {
+				parsing = false
 			}
+
 		// Obviously, stop parsing if we hit an error token
 		case html.ErrorToken:
-			parsing = false
-			return make(map[string]struct{}), "", errors.New("Received malformed html from target")
+			return nil, "", errors.New("Received malformed html from target")
+		}
+	}
+
+	hubURLs, selfURL := links.resolve(base)
+	if len(selfURL) == 0 {
+		return hubURLs, selfURL, errors.New("Target did not provide a self reference")
+	}
+	return hubURLs, selfURL, nil
+}
+
+// Parse links from the body of an http reply, assumes that the body is an Atom or RSS 2.0 feed.
+// Atom feeds carry their links as children of <feed>, and RSS feeds as <atom:link> children of <channel>.
+// Links anywhere else (e.g. the alternate links of entries) are ignored.
+func parseLinksFromXML(xmlReader io.Reader, base *url.URL) ([]string, string, error) {
+	decoder := xml.NewDecoder(xmlReader)
+
+	var links rawLinks
+	var parents []string
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", errors.New("Received malformed xml from target")
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			parent := ""
+			if len(parents) > 0 {
+				parent = parents[len(parents)-1]
+			}
+			parents = append(parents, t.Name.Local)
+
+			if t.Name.Local != "link" || t.Name.Space != atomNamespace || (parent != "feed" && parent != "channel") {
+				continue
+			}
+
+			var rel, href string
+			for _, a := range t.Attr {
+				switch a.Name.Local {
+				case "rel":
+					rel = a.Value
+				case "href":
+					href = a.Value
+				}
+			}
+			links = append(links, rawLink{rels: strings.Fields(strings.ToLower(rel)), href: href})
+		case xml.EndElement:
+			parents = parents[:len(parents)-1]
 		}
 	}
 
+	hubURLs, selfURL := links.resolve(base)
 	if len(selfURL) == 0 {
 		return hubURLs, selfURL, errors.New("Target did not provide a self reference")
-	} else {
-		return hubURLs, selfURL, nil
 	}
+	return hubURLs, selfURL, nil
 }
@@ -143,3 +143,84 @@ func TestMalformedHTML(t *testing.T) {
 		}
 	})
 }
+
+func getXMLBodyFromFile(testCode string) string {
+	bytes, err := ioutil.ReadFile(fmt.Sprintf("test-assets/%s.xml", testCode))
+	if err != nil {
+		panic(err)
+	}
+	return string(bytes)
+}
+
+func TestDiscoverTopicFeeds(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	cases := []struct {
+		name        string
+		testCode    string
+		contentType string
+		linkHeader  string
+		hub, self   string
+	}{
+		{
+			name:        "Parsing Links from an Atom feed",
+			testCode:    "102",
+			contentType: "application/atom+xml",
+			hub:         "https://websub.rocks/blog/102/wYDS1Km3NAmQozX7XnoG/hub",
+			self:        "https://websub.rocks/blog/102/wYDS1Km3NAmQozX7XnoG",
+		},
+		{
+			name:        "Parsing Links from an RSS feed",
+			testCode:    "103",
+			contentType: "application/rss+xml; charset=UTF-8",
+			hub:         "https://websub.rocks/blog/103/HiQLr1bdrRZABYNEJh78/hub",
+			self:        "https://websub.rocks/blog/103/HiQLr1bdrRZABYNEJh78",
+		},
+		{
+			name:        "Parsing Links from a feed served as generic xml",
+			testCode:    "102",
+			contentType: "text/xml",
+			hub:         "https://websub.rocks/blog/102/wYDS1Km3NAmQozX7XnoG/hub",
+			self:        "https://websub.rocks/blog/102/wYDS1Km3NAmQozX7XnoG",
+		},
+		{
+			name:        "Preferring Links in the header over Links in the feed",
+			testCode:    "104",
+			contentType: "application/xml",
+			linkHeader:  "<https://websub.rocks/blog/104/bN1xyBZGKz9pkdsJj5E0/hub>; rel=\"hub\", <https://websub.rocks/blog/104/bN1xyBZGKz9pkdsJj5E0>; rel=\"self\"",
+			hub:         "https://websub.rocks/blog/104/bN1xyBZGKz9pkdsJj5E0/hub",
+			self:        "https://websub.rocks/blog/104/bN1xyBZGKz9pkdsJj5E0",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		httpmock.Reset()
+		httpmock.RegisterResponder("GET", "http://example.com/feed",
+			func(req *http.Request) (*http.Response, error) {
+				resp := httpmock.NewStringResponse(200, getXMLBodyFromFile(c.testCode))
+				resp.Header.Set("Content-Type", c.contentType)
+				if c.linkHeader != "" {
+					resp.Header.Set("Link", c.linkHeader)
+				}
+				return resp, nil
+			})
+
+		t.Run(c.name, func(t *testing.T) {
+			hubs, self, err := DiscoverTopic("http://example.com/feed")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hubs) != 1 {
+				t.Fatalf("Expected 1 hub, but found %v", hubs)
+			}
+			if _, ok := hubs[c.hub]; !ok {
+				t.Fatalf("Failed to parse hub link {%v}, found %v", c.hub, hubs)
+			}
+			if self != c.self {
+				t.Fatalf("Expected self link {%v} but got {%v}", c.self, self)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package discovery
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// headerLink is a single link from a Link header, as described by RFC 8288
+type headerLink struct {
+	target string
+	rels   []string // the link's relation types, lowercased
+}
+
+// parseLinkHeader parses every link in every Link field of the header.
+// Links are returned in the order that they appear, and malformed links are skipped.
+func parseLinkHeader(header http.Header) []headerLink {
+	var links []headerLink
+	for _, field := range header[http.CanonicalHeaderKey("Link")] {
+		for _, value := range splitOutsideQuotes(field, ',') {
+			if l, ok := parseLinkValue(value); ok {
+				links = append(links, l)
+			}
+		}
+	}
+	return links
+}
+
+// parseLinkValue parses a single link-value, e.g. `<https://hub.example.com/>; rel="hub"`
+func parseLinkValue(value string) (headerLink, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "<") {
+		return headerLink{}, false
+	}
+	end := strings.Index(value, ">")
+	if end < 0 {
+		return headerLink{}, false
+	}
+
+	l := headerLink{target: strings.TrimSpace(value[1:end])}
+
+	params := splitOutsideQuotes(value[end+1:], ';')
+	for _, param := range params {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 || strings.ToLower(strings.TrimSpace(kv[0])) != "rel" {
+			continue
+		}
+
+		// Only the first occurrence of rel counts, and it may hold a space-separated list of relation types
+		for _, rel := range strings.Fields(unquote(strings.TrimSpace(kv[1]))) {
+			l.rels = append(l.rels, strings.ToLower(rel))
+		}
+		break
+	}
+
+	return l, true
+}
+
+// splitOutsideQuotes splits s around every sep that is not within a quoted string, or within <...>
+func splitOutsideQuotes(s string, sep rune) []string {
+	var parts []string
+	inQuotes, inBrackets, escaped := false, false, false
+	start := 0
+	for idx, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes && r == '\\':
+			escaped = true
+		case r == '"' && !inBrackets:
+			inQuotes = !inQuotes
+		case r == '<' && !inQuotes:
+			inBrackets = true
+		case r == '>' && !inQuotes:
+			inBrackets = false
+		case r == sep && !inQuotes && !inBrackets:
+			parts = append(parts, s[start:idx])
+			start = idx + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote strips the quotes (and escapes) from a quoted-string, and returns tokens as they are
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	var b strings.Builder
+	escaped := false
+	for _, r := range s[1 : len(s)-1] {
+		if !escaped && r == '\\' {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// resolveReference resolves a (possibly relative) link against the given base url.
+// The second return value is false if the link is not a valid url.
+func resolveReference(base *url.URL, href string) (string, bool) {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || href == "" {
+		return "", false
+	}
+	if base == nil {
+		return ref.String(), true
+	}
+	return base.ResolveReference(ref).String(), true
+}
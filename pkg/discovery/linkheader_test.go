@@ -0,0 +1,167 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestParseLinkHeader(t *testing.T) {
+	cases := []struct {
+		name   string
+		fields []string
+		want   []headerLink
+	}{
+		{
+			name:   "Comma-delimited links",
+			fields: []string{`<https://hub.example.com/>; rel="hub", <http://example.com/feed>; rel="self"`},
+			want:   []headerLink{{"https://hub.example.com/", []string{"hub"}}, {"http://example.com/feed", []string{"self"}}},
+		},
+		{
+			name:   "Multiple Link fields, with repeated relations",
+			fields: []string{`<https://hub.example.com/a>; rel="hub"`, `<https://hub.example.com/b>; rel="hub"`},
+			want:   []headerLink{{"https://hub.example.com/a", []string{"hub"}}, {"https://hub.example.com/b", []string{"hub"}}},
+		},
+		{
+			name:   "Space-separated relation types, unquoted and in any case",
+			fields: []string{`<https://example.com/>; REL="Hub self", <https://example.com/other>; rel=hub`},
+			want:   []headerLink{{"https://example.com/", []string{"hub", "self"}}, {"https://example.com/other", []string{"hub"}}},
+		},
+		{
+			name:   "Separators within quotes and brackets",
+			fields: []string{`<https://example.com/a,b;c>; title="x, y; z"; rel="hub"`},
+			want:   []headerLink{{"https://example.com/a,b;c", []string{"hub"}}},
+		},
+		{
+			name:   "Malformed links are skipped",
+			fields: []string{`https://example.com/; rel="hub", <https://example.com/ok>; rel="self"`},
+			want:   []headerLink{{"https://example.com/ok", []string{"self"}}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			header := http.Header{"Link": c.fields}
+			if got := parseLinkHeader(header); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("Expected links %v but got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestDiscoverer_resolvesLinks(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	cases := []struct {
+		name        string
+		body        string
+		contentType string
+		linkHeader  []string
+		hubs        []string
+		self        string
+	}{
+		{
+			name:       "Fixture 100, links in the header",
+			body:       getBodyFromFile("100"),
+			linkHeader: []string{`<https://hub.example.com/>; rel="hub", <http://example.com/feed>; rel="self"`},
+			hubs:       []string{"https://hub.example.com/"},
+			self:       "http://example.com/feed",
+		},
+		{
+			name:        "Fixture 101, links in the html head",
+			body:        getBodyFromFile("101"),
+			contentType: "text/html",
+			hubs:        []string{"https://websub.rocks/blog/101/kjEJaVI57HetbbiZWivI/hub"},
+			self:        "https://websub.rocks/blog/101/kjEJaVI57HetbbiZWivI",
+		},
+		{
+			name:        "Fixture 102, links in an Atom feed",
+			body:        getXMLBodyFromFile("102"),
+			contentType: "application/atom+xml",
+			hubs:        []string{"https://websub.rocks/blog/102/wYDS1Km3NAmQozX7XnoG/hub"},
+			self:        "https://websub.rocks/blog/102/wYDS1Km3NAmQozX7XnoG",
+		},
+		{
+			name:        "Fixture 103, links in an RSS feed",
+			body:        getXMLBodyFromFile("103"),
+			contentType: "application/rss+xml",
+			hubs:        []string{"https://websub.rocks/blog/103/HiQLr1bdrRZABYNEJh78/hub"},
+			self:        "https://websub.rocks/blog/103/HiQLr1bdrRZABYNEJh78",
+		},
+		{
+			name:       "Relative links in multiple header fields",
+			linkHeader: []string{`</hub>; rel="hub"`, `<hub2>; rel="hub"`, `<?page=1>; rel="self"`},
+			hubs:       []string{"http://example.com/hub", "http://example.com/blog/hub2"},
+			self:       "http://example.com/blog/feed?page=1",
+		},
+		{
+			name:        "Relative links in html",
+			body:        `<html><head><link rel="hub" href="/hub"><link rel="self" href="feed"/></head></html>`,
+			contentType: "text/html",
+			hubs:        []string{"http://example.com/hub"},
+			self:        "http://example.com/blog/feed",
+		},
+		{
+			name: "Links resolved against the html base",
+			body: `<html><head>
+				<link rel="hub" href="hub">
+				<base href="https://cdn.example.com/feeds/">
+				<base href="https://ignored.example.com/">
+				<link rel="self" href="mine">
+			</head></html>`,
+			contentType: "text/html",
+			hubs:        []string{"https://cdn.example.com/feeds/hub"},
+			self:        "https://cdn.example.com/feeds/mine",
+		},
+		{
+			name:        "Multi-valued relations in html",
+			body:        `<html><head><link rel="Hub self" href="https://example.com/both"></head></html>`,
+			contentType: "text/html",
+			hubs:        []string{"https://example.com/both"},
+			self:        "https://example.com/both",
+		},
+		{
+			name:        "Relative links in a feed",
+			body:        `<feed xmlns="http://www.w3.org/2005/Atom"><link rel="hub" href="/hub"/><link rel="self" href="feed.atom"/></feed>`,
+			contentType: "application/atom+xml",
+			hubs:        []string{"http://example.com/hub"},
+			self:        "http://example.com/blog/feed.atom",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		httpmock.Reset()
+		httpmock.RegisterResponder("GET", "http://example.com/blog/feed",
+			func(req *http.Request) (*http.Response, error) {
+				resp := httpmock.NewStringResponse(200, c.body)
+				if c.contentType != "" {
+					resp.Header.Set("Content-Type", c.contentType)
+				}
+				resp.Header["Link"] = c.linkHeader
+				return resp, nil
+			})
+
+		t.Run(c.name, func(t *testing.T) {
+			res, err := NewDiscoverer().Discover(context.Background(), "http://example.com/blog/feed")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var hubs []string
+			for _, hub := range res.Hubs {
+				hubs = append(hubs, hub.URL)
+			}
+			if !reflect.DeepEqual(hubs, c.hubs) {
+				t.Fatalf("Expected hubs %v but got %v", c.hubs, hubs)
+			}
+			if res.Self.URL != c.self {
+				t.Fatalf("Expected self link {%v} but got {%v}", c.self, res.Self.URL)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package hub
+
+import (
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/discovery"
+	"github.com/adamsanghera/go-websub/pkg/hub/storage"
+	"github.com/adamsanghera/go-websub/pkg/hub/storage/sql"
+)
+
+// Config is the configuration information for a Hub
+type Config struct {
+	port string
+
+	// URL is the url that the hub is reached at, which topics must advertise as one of their hubs.
+	// Empty skips that check, but topics must still be discoverable when ValidateTopics is set.
+	URL string
+
+	// TrustForwardedProto makes the hub believe the X-Forwarded-Proto header of a request, when deciding whether it
+	// arrived over https (and so may carry a hub.secret). Only set it when a proxy that terminates TLS, and sets
+	// the header, stands in front of every request, since anyone who reaches the hub directly can set it too.
+	TrustForwardedProto bool
+
+	// Storage configures the Hub's sqlite3 storage.
+	// Content waiting to be delivered is queued there too, so a file DSN keeps the queue across restarts.
+	Storage *sql.Config
+
+	// Backend replaces the sqlite3 storage with another implementation of storage.Storage, when set.
+	// The Hub takes ownership of the backend, and shuts it down along with itself.
+	Backend storage.Storage
+
+	// ValidateTopics makes the hub discover the topic of every subscription request, and deny subscriptions
+	// to topics that can't be discovered (or that don't advertise URL as one of their hubs).
+	ValidateTopics bool
+
+	// Discoverer discovers the topics of subscription requests. Nil uses the default Discoverer.
+	Discoverer *discovery.Discoverer
+
+	// DefaultLease is granted to subscribers that don't ask for a lease.
+	// Leases that subscribers ask for are kept between MinLease and MaxLease.
+	DefaultLease time.Duration
+	MinLease     time.Duration
+	MaxLease     time.Duration
+
+	// VerificationTimeout bounds the time spent validating a topic, and verifying the intent of a subscriber
+	VerificationTimeout time.Duration
+
+	// Publishers are the parties allowed to publish topics, each with its own credentials and topic allowlist.
+	// Empty lets anyone publish any topic.
+	Publishers []*Publisher
+
+	// PublishWindow is how far the X-Hub-Timestamp of a signed publish request may be from the hub's clock,
+	// which must be positive. Signed requests are only accepted once, so a captured request can't be sent again.
+	PublishWindow time.Duration
+
+	// MaxContentSize is the largest topic content (in bytes) that the hub fetches and distributes,
+	// which also bounds the content that publishers push in fat pings
+	MaxContentSize int64
+
+	// DefaultPingMode is the kind of publish request that topics accept, unless PingModes says otherwise
+	// (ThinPings, by default). PingModes sets it for individual topics, keyed by their url.
+	// Fat pings are only ever accepted from an authenticated publisher, since the hub signs what they carry
+	// for every subscriber.
+	DefaultPingMode PingMode
+	PingModes       map[string]PingMode
+
+	// DeliveryTimeout bounds each attempt to deliver content to a subscriber. It must be positive.
+	DeliveryTimeout time.Duration
+
+	// DeliveryPollInterval is how often the hub looks for deliveries that have come due. It must be positive.
+	DeliveryPollInterval time.Duration
+
+	// MaxConcurrentDeliveries is the most deliveries that the hub attempts at once. It must be positive.
+	MaxConcurrentDeliveries int
+
+	// SignatureMethod is the X-Hub-Signature method (sha1, sha256, sha384 or sha512) that content is signed with,
+	// for subscribers that sent a hub.secret
+	SignatureMethod string
+
+	// RetryPolicy governs how failed deliveries are retried, before they are dead-lettered. It must be set.
+	RetryPolicy *RetryPolicy
+
+	// DeadLetterRetention is how long dead letters are kept for inspection, before they are purged.
+	// Zero keeps them until an operator purges them from storage.
+	DeadLetterRetention time.Duration
+}
+
+// PingMode is the kind of publish request (ping) that a topic accepts.
+// Thin pings only name the topic, and make the hub fetch its content.
+// Fat pings carry the content along with them, which spares the topic's origin a fetch.
+type PingMode int
+
+const (
+	// AnyPings accepts both thin and fat pings
+	AnyPings PingMode = iota
+
+	// ThinPings only accepts thin pings, so that content is always fetched from the topic's origin
+	ThinPings
+
+	// FatPings only accepts fat pings, so that the topic's origin is never fetched
+	FatPings
+)
+
+// NewConfig returns the default config for Hub
+func NewConfig() *Config {
+	return &Config{
+		port:                "5000",
+		Storage:             sql.NewConfig(),
+		ValidateTopics:      true,
+		DefaultLease:        10 * 24 * time.Hour,
+		MinLease:            5 * time.Minute,
+		MaxLease:            30 * 24 * time.Hour,
+		VerificationTimeout: 10 * time.Second,
+		PublishWindow:       5 * time.Minute,
+
+		MaxContentSize:          10 << 20,
+		DefaultPingMode:         ThinPings,
+		DeliveryTimeout:         30 * time.Second,
+		DeliveryPollInterval:    time.Second,
+		MaxConcurrentDeliveries: 16,
+		SignatureMethod:         "sha256",
+		RetryPolicy:             NewRetryPolicy(),
+		DeadLetterRetention:     7 * 24 * time.Hour,
+	}
+}
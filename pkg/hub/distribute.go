@@ -0,0 +1,180 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/hub/storage"
+	"github.com/adamsanghera/go-websub/pkg/signature"
+)
+
+// deliveryLoop dispatches queued deliveries as they come due, until the Hub shuts down.
+// Each delivery takes one of MaxConcurrentDeliveries slots for as long as it is in flight, and deliveries are only
+// claimed for the slots that are free, so that a slow subscriber holds up nobody but itself.
+func (hub *Hub) deliveryLoop(ctx context.Context) {
+	defer close(hub.deliveryDone)
+	defer hub.deliveriesInFlight.Wait()
+
+	ticker := time.NewTicker(hub.deliveryPollInterval)
+	defer ticker.Stop()
+
+	// Dead letters are purged as often as they expire, but at most hourly
+	var purge <-chan time.Time
+	if hub.deadLetterRetention > 0 {
+		every := hub.deadLetterRetention
+		if every > time.Hour {
+			every = time.Hour
+		}
+		purgeTicker := time.NewTicker(every)
+		defer purgeTicker.Stop()
+		purge = purgeTicker.C
+	}
+
+	for {
+		// Keep dispatching for as long as the queue fills every free slot
+		for {
+			free := cap(hub.deliverySlots) - len(hub.deliverySlots)
+			if free == 0 || hub.dispatchDeliveries(ctx, free) < free {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-hub.deliveryWake:
+		case <-purge:
+			hub.purgeDeadLetters(ctx)
+		}
+	}
+}
+
+// purgeDeadLetters removes the dead letters that have been kept for longer than the Hub's retention
+func (hub *Hub) purgeDeadLetters(ctx context.Context) {
+	purged, err := hub.storage.PurgeDeadLetters(ctx, time.Now().Add(-hub.deadLetterRetention))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to purge dead letters: %v\n", err)
+		}
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d dead letters\n", purged)
+	}
+}
+
+// wakeDeliveries tells the delivery loop that new deliveries may be due, or that a slot freed up, without waiting for it
+func (hub *Hub) wakeDeliveries() {
+	select {
+	case hub.deliveryWake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchDeliveries claims at most 'limit' due deliveries, and delivers each of them in the background,
+// in a slot of its own. Only the delivery loop takes slots, so it must not ask for more than are free.
+// It returns the number of deliveries dispatched.
+func (hub *Hub) dispatchDeliveries(ctx context.Context, limit int) int {
+	deliveries, err := hub.storage.ClaimDeliveries(ctx, limit, 2*hub.deliveryTimeout)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to claim deliveries: %v\n", err)
+		}
+		return 0
+	}
+
+	for _, delivery := range deliveries {
+		hub.deliverySlots <- struct{}{}
+		hub.deliveriesInFlight.Add(1)
+		go func(delivery *storage.Delivery) {
+			defer hub.deliveriesInFlight.Done()
+			defer hub.wakeDeliveries()
+			defer func() { <-hub.deliverySlots }()
+
+			hub.deliver(ctx, delivery)
+		}(delivery)
+	}
+
+	return len(deliveries)
+}
+
+// deliver sends the delivery's content to its callback, and settles the delivery according to the response.
+// Deliveries that fail are retried according to the Hub's RetryPolicy, and subscribers that answer
+// 410 Gone are unsubscribed.
+func (hub *Hub) deliver(ctx context.Context, delivery *storage.Delivery) {
+	err := hub.sendContent(ctx, delivery)
+	if ctx.Err() != nil {
+		// The Hub is shutting down, and the delivery comes due again once its claim runs out
+		return
+	}
+
+	switch e := err.(type) {
+	case nil:
+		err = hub.storage.CompleteDelivery(ctx, delivery.ID)
+	case ErrCallbackStatus:
+		if e.StatusCode == http.StatusGone {
+			log.Printf("Callback {%v} is gone, unsubscribing it from topic {%v}\n", delivery.Callback, delivery.Topic)
+			err = hub.storage.Unsubscribe(ctx, delivery.Topic, delivery.Callback)
+			break
+		}
+		err = hub.failDelivery(ctx, delivery, e)
+	default:
+		err = hub.failDelivery(ctx, delivery, e)
+	}
+
+	if err != nil {
+		log.Printf("Failed to settle delivery %d to callback {%v}: %v\n", delivery.ID, delivery.Callback, err)
+	}
+}
+
+// failDelivery records a failed try of the delivery, and dead-letters it once it has run out of tries
+func (hub *Hub) failDelivery(ctx context.Context, delivery *storage.Delivery, cause error) error {
+	attempt := delivery.Attempts + 1
+	if attempt >= hub.retryPolicy.MaxAttempts {
+		log.Printf("Giving up on delivery %d to callback {%v} after %d attempts: %v\n", delivery.ID, delivery.Callback, attempt, cause)
+		return hub.storage.DeadLetterDelivery(ctx, delivery.ID, cause.Error())
+	}
+
+	return hub.storage.RetryDelivery(ctx, delivery.ID, cause.Error(), time.Now().Add(hub.retryPolicy.backoff(attempt)))
+}
+
+// sendContent POSTs the delivery's content to its callback, along with the topic's Content-Type and links,
+// and a signature of the content if the subscriber shared a secret
+func (hub *Hub) sendContent(ctx context.Context, delivery *storage.Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, hub.deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, delivery.Callback, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return err
+	}
+	if delivery.ContentType != "" {
+		req.Header.Set("Content-Type", delivery.ContentType)
+	}
+	if hub.url != "" {
+		req.Header.Add("Link", "<"+hub.url+">; rel=\"hub\"")
+	}
+	req.Header.Add("Link", "<"+delivery.Topic+">; rel=\"self\"")
+	if delivery.Secret != "" {
+		header, err := signature.Sign(hub.signatureMethod, delivery.Secret, delivery.Body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-Hub-Signature", header)
+	}
+
+	resp, err := hub.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return ErrCallbackStatus{resp.StatusCode}
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package hub
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/hub/storage"
+	"github.com/adamsanghera/go-websub/pkg/signature"
+)
+
+/*
+	# Test Cases
+
+	1. Published content is delivered to every subscriber, with its Content-Type and Link headers
+	   and a signature for the subscribers that shared a secret
+	2. Topics that can't be fetched are reported to the publisher, without the cause unless the publisher authenticated
+	3. Topics that nobody subscribes to aren't fetched
+	4. Failed deliveries are retried, and dead-lettered once they run out of attempts
+	5. Subscribers that answer 410 Gone are unsubscribed
+	6. A slow subscriber doesn't hold up deliveries to the others
+	7. Hubs need a positive MaxConcurrentDeliveries, DeliveryPollInterval and DeliveryTimeout, and a RetryPolicy
+	8. Dead letters are purged once they've been kept for DeadLetterRetention
+*/
+
+// delivered is a request that a subscriber's callback received
+type delivered struct {
+	header http.Header
+	body   string
+}
+
+// newDeliveryCallback serves a subscriber's callback, which answers every delivery with the given status code
+func newDeliveryCallback(t *testing.T, code int) (*httptest.Server, chan delivered) {
+	t.Helper()
+
+	received := make(chan delivered, 10)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		received <- delivered{req.Header, string(body)}
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(callback.Close)
+	return callback, received
+}
+
+// newDeliveryHub creates a hub that retries failed deliveries quickly
+func newDeliveryHub(t *testing.T, hubURL string) *Hub {
+	t.Helper()
+
+	cfg := NewConfig()
+	cfg.URL = hubURL
+	cfg.DeliveryPollInterval = 10 * time.Millisecond
+	cfg.RetryPolicy = &RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	return newTestHubFromConfig(t, cfg)
+}
+
+// expectDelivery waits for the callback to receive a delivery
+func expectDelivery(t *testing.T, received chan delivered) delivered {
+	t.Helper()
+
+	select {
+	case d := <-received:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a delivery")
+	}
+	return delivered{}
+}
+
+func TestHub_publish(t *testing.T) {
+	hub := newDeliveryHub(t, "http://hub.example.com/")
+	topic := newTestTopic(t, "http://hub.example.com/")
+	first, firstReceived := newDeliveryCallback(t, 200)
+	second, secondReceived := newDeliveryCallback(t, 204)
+	ctx := context.Background()
+
+	for callback, secret := range map[string]string{first.URL: "", second.URL: "secret"} {
+		if err := hub.storage.Subscribe(ctx, topic.URL, callback, secret, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1. Published content is delivered to every subscriber, with its Content-Type and Link headers
+	//    and a signature for the subscribers that shared a secret
+	rec := postForm(hub, url.Values{
+		"hub.mode": {"publish"},
+		"hub.url":  {topic.URL},
+	})
+	if rec.Code != 202 {
+		t.Fatalf("Expected code 202 but received %d", rec.Code)
+	}
+
+	var deliveries []delivered
+	for _, received := range []chan delivered{firstReceived, secondReceived} {
+		d := expectDelivery(t, received)
+		deliveries = append(deliveries, d)
+		if d.body != "content" {
+			t.Fatalf("Expected body {content} but received {%v}", d.body)
+		}
+		if ct := d.header.Get("Content-Type"); ct != "text/plain" {
+			t.Fatalf("Expected content type {text/plain} but received {%v}", ct)
+		}
+		links := d.header["Link"]
+		if len(links) != 2 || links[0] != "<http://hub.example.com/>; rel=\"hub\"" || links[1] != "<"+topic.URL+">; rel=\"self\"" {
+			t.Fatalf("Expected hub and self links but received %v", links)
+		}
+	}
+
+	if header := deliveries[0].header.Get("X-Hub-Signature"); header != "" {
+		t.Fatalf("Expected no signature without a secret, but received {%v}", header)
+	}
+	header := deliveries[1].header.Get("X-Hub-Signature")
+	if !strings.HasPrefix(header, "sha256=") {
+		t.Fatalf("Expected a sha256 signature, but received {%v}", header)
+	}
+	if err := signature.Verify("secret", header, []byte(deliveries[1].body)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2. Topics that can't be fetched are reported to the publisher, without the cause unless the publisher authenticated
+	missing, _ := newDeliveryCallback(t, 404)
+	if err := hub.storage.Subscribe(ctx, missing.URL, first.URL, "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	rec = postForm(hub, url.Values{
+		"hub.mode": {"publish"},
+		"hub.url":  {missing.URL},
+	})
+	if rec.Code != 502 || strings.Contains(rec.Body.String(), "404") {
+		t.Fatalf("Expected code 502 without the topic's status, but received %d {%v}", rec.Code, rec.Body.String())
+	}
+	rec = postForm(hub, url.Values{
+		"hub.mode": {"publish"},
+		"hub.url":  {"not a url"},
+	})
+	if rec.Code != 400 {
+		t.Fatalf("Expected code 400 but received %d", rec.Code)
+	}
+
+	// 3. Topics that nobody subscribes to aren't fetched
+	unsubscribed, fetched := newDeliveryCallback(t, 200)
+	rec = postForm(hub, url.Values{
+		"hub.mode": {"publish"},
+		"hub.url":  {unsubscribed.URL},
+	})
+	if rec.Code != 202 {
+		t.Fatalf("Expected code 202 but received %d", rec.Code)
+	}
+	select {
+	case <-fetched:
+		t.Fatal("Expected the topic not to be fetched, without subscribers")
+	default:
+	}
+}
+
+func TestHub_publish_retries(t *testing.T) {
+	hub := newDeliveryHub(t, "http://hub.example.com/")
+	topic := newTestTopic(t, "http://hub.example.com/")
+	failing, failingReceived := newDeliveryCallback(t, 500)
+	gone, goneReceived := newDeliveryCallback(t, 410)
+	ctx := context.Background()
+
+	for _, callback := range []string{failing.URL, gone.URL} {
+		if err := hub.storage.Subscribe(ctx, topic.URL, callback, "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queued, err := hub.Publish(ctx, topic.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued != 2 {
+		t.Fatalf("Expected 2 deliveries to be queued but got %d", queued)
+	}
+
+	// 4. Failed deliveries are retried, and dead-lettered once they run out of attempts
+	for i := 0; i < 3; i++ {
+		expectDelivery(t, failingReceived)
+	}
+
+	var dead []*storage.Delivery
+	for deadline := time.Now().Add(5 * time.Second); len(dead) == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if dead, err = hub.storage.GetDeadLetters(ctx, topic.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(dead) != 1 || dead[0].Callback != failing.URL || dead[0].Attempts != 3 {
+		t.Fatalf("Expected the failing delivery to be dead-lettered after 3 attempts, but got %v", dead)
+	}
+	if len(failingReceived) != 0 {
+		t.Fatal("Dead-lettered delivery was attempted again")
+	}
+
+	// 5. Subscribers that answer 410 Gone are unsubscribed
+	expectDelivery(t, goneReceived)
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, err = hub.storage.GetSubscription(ctx, topic.URL, gone.URL); err == storage.ErrNotFound {
+			break
+		}
+	}
+	if err != storage.ErrNotFound {
+		t.Fatalf("Expected the gone subscriber to be unsubscribed, but got {%v}", err)
+	}
+	if len(goneReceived) != 0 {
+		t.Fatal("Gone subscriber was delivered to again")
+	}
+}
+
+func TestHub_publish_slowSubscriber(t *testing.T) {
+	hub := newDeliveryHub(t, "http://hub.example.com/")
+	slowTopic := newTestTopic(t, "http://hub.example.com/")
+	fastTopic := newTestTopic(t, "http://hub.example.com/")
+	fast, fastReceived := newDeliveryCallback(t, 200)
+	ctx := context.Background()
+
+	stuck := make(chan struct{})
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		stuck <- struct{}{}
+		<-release
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+
+	if err := hub.storage.Subscribe(ctx, slowTopic.URL, slow.URL, "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.storage.Subscribe(ctx, fastTopic.URL, fast.URL, "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// 6. A slow subscriber doesn't hold up deliveries to the others
+	if _, err := hub.Publish(ctx, slowTopic.URL); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stuck:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the slow subscriber's delivery")
+	}
+
+	if _, err := hub.Publish(ctx, fastTopic.URL); err != nil {
+		t.Fatal(err)
+	}
+	expectDelivery(t, fastReceived)
+}
+
+func TestHub_New_deliveryConfig(t *testing.T) {
+	// 7. Hubs need a positive MaxConcurrentDeliveries, DeliveryPollInterval and DeliveryTimeout, and a RetryPolicy
+	cfg := NewConfig()
+	cfg.MaxConcurrentDeliveries = 0
+	if _, err := New(cfg); err != (ErrInvalidConfig{"MaxConcurrentDeliveries", "must be positive"}) {
+		t.Fatalf("Expected MaxConcurrentDeliveries to be rejected, but got {%v}", err)
+	}
+
+	cfg = NewConfig()
+	cfg.DeliveryPollInterval = 0
+	if _, err := New(cfg); err != (ErrInvalidConfig{"DeliveryPollInterval", "must be positive"}) {
+		t.Fatalf("Expected DeliveryPollInterval to be rejected, but got {%v}", err)
+	}
+
+	cfg = NewConfig()
+	cfg.DeliveryTimeout = 0
+	if _, err := New(cfg); err != (ErrInvalidConfig{"DeliveryTimeout", "must be positive"}) {
+		t.Fatalf("Expected DeliveryTimeout to be rejected, but got {%v}", err)
+	}
+
+	cfg = NewConfig()
+	cfg.RetryPolicy = nil
+	if _, err := New(cfg); err != (ErrInvalidConfig{"RetryPolicy", "must be set"}) {
+		t.Fatalf("Expected RetryPolicy to be rejected, but got {%v}", err)
+	}
+}
+
+func TestHub_publish_deadLetterRetention(t *testing.T) {
+	cfg := NewConfig()
+	cfg.URL = "http://hub.example.com/"
+	cfg.DeliveryPollInterval = 10 * time.Millisecond
+	cfg.RetryPolicy = &RetryPolicy{MaxAttempts: 1}
+	// Dead letters are timestamped to the second, so they're kept for at least half a second
+	cfg.DeadLetterRetention = 1500 * time.Millisecond
+	hub := newTestHubFromConfig(t, cfg)
+	topic := newTestTopic(t, "http://hub.example.com/")
+	failing, failingReceived := newDeliveryCallback(t, 500)
+	ctx := context.Background()
+
+	if err := hub.storage.Subscribe(ctx, topic.URL, failing.URL, "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hub.Publish(ctx, topic.URL); err != nil {
+		t.Fatal(err)
+	}
+	expectDelivery(t, failingReceived)
+
+	// 8. Dead letters are purged once they've been kept for DeadLetterRetention
+	sawDead := false
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		dead, err := hub.storage.GetDeadLetters(ctx, topic.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dead) > 0 {
+			sawDead = true
+		} else if sawDead {
+			return
+		}
+	}
+	t.Fatalf("Expected the dead letter to be kept and then purged, but saw it: {%v}", sawDead)
+}
@@ -0,0 +1,101 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrChallengeMismatch is returned when a subscriber's callback fails to echo the hub.challenge back
+	ErrChallengeMismatch = errors.New("Hub: callback did not echo the hub.challenge")
+
+	// ErrUnauthenticated is returned when a publish request doesn't carry the credentials of any known publisher
+	ErrUnauthenticated = errors.New("Hub: publish request lacked valid publisher credentials")
+
+	// ErrUnauthenticatedContent is returned for fat pings to a hub that doesn't know of any publishers,
+	// which could only come from unauthenticated parties
+	ErrUnauthenticatedContent = errors.New("Hub: fat pings are only accepted from authenticated publishers")
+
+	// ErrStaleSignature is returned when a signed publish request doesn't carry an X-Hub-Timestamp within the
+	// hub's PublishWindow
+	ErrStaleSignature = errors.New("Hub: signed publish request lacked a fresh X-Hub-Timestamp")
+
+	// ErrReplayedSignature is returned when a signed publish request was already accepted once
+	ErrReplayedSignature = errors.New("Hub: signed publish request was already received")
+)
+
+// ErrBadRequest is returned when a request to the hub is missing a parameter, or has a malformed one
+type ErrBadRequest struct {
+	Param  string
+	Reason string
+}
+
+func (e ErrBadRequest) Error() string {
+	return fmt.Sprintf("Hub: %s %s", e.Param, e.Reason)
+}
+
+// ErrInvalidConfig is returned by New when a setting of the Config can't be used
+type ErrInvalidConfig struct {
+	Field  string
+	Reason string
+}
+
+func (e ErrInvalidConfig) Error() string {
+	return fmt.Sprintf("Hub: config %s %s", e.Field, e.Reason)
+}
+
+// ErrCallbackStatus is returned when a subscriber's callback responds to verification with a non-2xx status
+type ErrCallbackStatus struct {
+	StatusCode int
+}
+
+func (e ErrCallbackStatus) Error() string {
+	return fmt.Sprintf("Hub: callback responded with unexpected status code %d", e.StatusCode)
+}
+
+// ErrHubNotAdvertised is returned when a topic does not list this hub among its hubs
+type ErrHubNotAdvertised struct {
+	Topic string
+}
+
+func (e ErrHubNotAdvertised) Error() string {
+	return fmt.Sprintf("Hub: topic {%s} does not advertise this hub", e.Topic)
+}
+
+// ErrTopicStatus is returned when a topic answers a request for its content with a non-2xx status
+type ErrTopicStatus struct {
+	StatusCode int
+}
+
+func (e ErrTopicStatus) Error() string {
+	return fmt.Sprintf("Hub: topic responded with unexpected status code %d", e.StatusCode)
+}
+
+// ErrContentTooLarge is returned when a topic's content exceeds the most that the hub distributes
+type ErrContentTooLarge struct {
+	MaxSize int64
+}
+
+func (e ErrContentTooLarge) Error() string {
+	return fmt.Sprintf("Hub: content is larger than the limit of %d bytes", e.MaxSize)
+}
+
+// ErrTopicNotAllowed is returned when a publisher publishes a topic that isn't in its allowlist
+type ErrTopicNotAllowed struct {
+	Publisher string
+	Topic     string
+}
+
+func (e ErrTopicNotAllowed) Error() string {
+	return fmt.Sprintf("Hub: publisher {%s} may not publish topic {%s}", e.Publisher, e.Topic)
+}
+
+// ErrPublishFailed is returned to publishers that the hub doesn't know of, when a topic that they published
+// couldn't be fetched or queued. The cause is only logged.
+type ErrPublishFailed struct {
+	Topic string
+}
+
+func (e ErrPublishFailed) Error() string {
+	return fmt.Sprintf("Hub: failed to publish topic {%s}", e.Topic)
+}
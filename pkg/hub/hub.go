@@ -0,0 +1,185 @@
+/*
+Package hub is a Go Server that implements the hub role of the W3 Group's
+WebSub protocol (https://www.w3.org/TR/websub/).
+
+Subscribers ask the hub to subscribe them to topics, and the hub verifies their intent
+before it records (or removes) their subscriptions.
+
+Publishers tell the hub when a topic has new content, either pushing the content along (a fat ping)
+or leaving the hub to fetch it (a thin ping), and the hub queues the content for delivery
+to every subscriber of the topic, retrying the deliveries that fail.
+Deliveries to subscribers that shared a hub.secret are signed with it, in an X-Hub-Signature header.
+*/
+package hub
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/discovery"
+	"github.com/adamsanghera/go-websub/pkg/hub/storage"
+	"github.com/adamsanghera/go-websub/pkg/hub/storage/sql"
+	"github.com/adamsanghera/go-websub/pkg/signature"
+)
+
+// Hub accepts subscription requests, and keeps track of the subscriptions that subscribers verify
+type Hub struct {
+	// The url that topics advertise the hub at
+	url string
+
+	// Whether requests are known to reach the hub through a proxy that terminates TLS
+	trustForwardedProto bool
+
+	// Client, to make calls to subscribers and topics
+	client *http.Client
+
+	// Server and mux, to handle requests from subscribers
+	mux *http.ServeMux
+	srv *http.Server
+
+	// Centralized source of truth for subscriptions
+	storage storage.Storage
+
+	// Discovers topics, to validate subscription requests
+	validateTopics bool
+	discoveries    *discovery.Cache
+
+	// Lease bookkeeping
+	defaultLease time.Duration
+	minLease     time.Duration
+	maxLease     time.Duration
+
+	// Verifications of intent in flight, which are abandoned on shutdown
+	verificationTimeout time.Duration
+	verifyCtx           context.Context
+	stopVerifying       context.CancelFunc
+	verifications       sync.WaitGroup
+
+	// Parties allowed to publish, or nil if anyone may, and the signed publish requests that they sent recently
+	publishers     []*Publisher
+	publishWindow  time.Duration
+	publishReplays *replayCache
+
+	// Distribution of content, from the queue in storage
+	maxContentSize       int64
+	defaultPingMode      PingMode
+	pingModes            map[string]PingMode
+	deliveryTimeout      time.Duration
+	deliveryPollInterval time.Duration
+	signatureMethod      string
+	retryPolicy          *RetryPolicy
+	deadLetterRetention  time.Duration
+	deliverySlots        chan struct{}  // bounds the number of deliveries in flight
+	deliveriesInFlight   sync.WaitGroup // tracks the routines of dispatched deliveries
+	deliveryWake         chan struct{}
+	stopDelivering       context.CancelFunc
+	deliveryDone         chan struct{}
+}
+
+// New creates and returns a new Hub from a given config object
+func New(cfg *Config) (*Hub, error) {
+	if !signature.Supported(cfg.SignatureMethod) {
+		return nil, signature.ErrUnsupportedMethod{Method: cfg.SignatureMethod}
+	}
+	if cfg.MaxConcurrentDeliveries <= 0 {
+		return nil, ErrInvalidConfig{"MaxConcurrentDeliveries", "must be positive"}
+	}
+	if cfg.DeliveryPollInterval <= 0 {
+		return nil, ErrInvalidConfig{"DeliveryPollInterval", "must be positive"}
+	}
+	if cfg.DeliveryTimeout <= 0 {
+		return nil, ErrInvalidConfig{"DeliveryTimeout", "must be positive"}
+	}
+	if cfg.RetryPolicy == nil {
+		return nil, ErrInvalidConfig{"RetryPolicy", "must be set"}
+	}
+	if cfg.PublishWindow <= 0 {
+		return nil, ErrInvalidConfig{"PublishWindow", "must be positive"}
+	}
+
+	// Init our storage system, unless we were handed one
+	stor := cfg.Backend
+	if stor == nil {
+		sqlStor, err := sql.New(cfg.Storage)
+		if err != nil {
+			return nil, err
+		}
+		stor = sqlStor
+	}
+
+	mux := http.NewServeMux()
+	srv := &http.Server{Addr: ":" + cfg.port, Handler: mux}
+
+	verifyCtx, stopVerifying := context.WithCancel(context.Background())
+	deliveryCtx, stopDelivering := context.WithCancel(context.Background())
+
+	hub := &Hub{
+		url:                 cfg.URL,
+		trustForwardedProto: cfg.TrustForwardedProto,
+		client:              &http.Client{Transport: &http.Transport{}},
+		mux:                 mux,
+		srv:                 srv,
+		storage:             stor,
+		validateTopics:      cfg.ValidateTopics,
+		discoveries:         discovery.NewCache(cfg.Discoverer),
+		defaultLease:        cfg.DefaultLease,
+		minLease:            cfg.MinLease,
+		maxLease:            cfg.MaxLease,
+		verificationTimeout: cfg.VerificationTimeout,
+		verifyCtx:           verifyCtx,
+		stopVerifying:       stopVerifying,
+
+		publishers:     cfg.Publishers,
+		publishWindow:  cfg.PublishWindow,
+		publishReplays: newReplayCache(cfg.PublishWindow),
+
+		maxContentSize:       cfg.MaxContentSize,
+		defaultPingMode:      cfg.DefaultPingMode,
+		pingModes:            cfg.PingModes,
+		deliveryTimeout:      cfg.DeliveryTimeout,
+		deliveryPollInterval: cfg.DeliveryPollInterval,
+		signatureMethod:      cfg.SignatureMethod,
+		retryPolicy:          cfg.RetryPolicy,
+		deadLetterRetention:  cfg.DeadLetterRetention,
+		deliverySlots:        make(chan struct{}, cfg.MaxConcurrentDeliveries),
+		deliveryWake:         make(chan struct{}, 1),
+		stopDelivering:       stopDelivering,
+		deliveryDone:         make(chan struct{}),
+	}
+
+	mux.HandleFunc("/", hub.hubSwitch)
+
+	go hub.deliveryLoop(deliveryCtx)
+
+	return hub, nil
+}
+
+// ServeHTTP handles a request to the hub, so that the Hub can be mounted in any server
+func (hub *Hub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	hub.mux.ServeHTTP(w, req)
+}
+
+// Run starts the Hub's server, which effectively means that the hub is on
+func (hub *Hub) Run() error {
+	return hub.srv.ListenAndServe()
+}
+
+// Shutdown is called to indicate that a Hub is no longer going to be used.
+// It stops the Hub's server, abandons the verifications and deliveries in flight, and closes its storage.
+// Abandoned deliveries stay queued, and are retried once their claim runs out.
+func (hub *Hub) Shutdown() error {
+	if err := hub.srv.Shutdown(context.Background()); err != nil {
+		return fmt.Errorf("Failed to shutdown hub Server %v", err)
+	}
+
+	hub.stopVerifying()
+	hub.verifications.Wait()
+
+	hub.stopDelivering()
+	<-hub.deliveryDone
+
+	return hub.storage.Shutdown()
+}
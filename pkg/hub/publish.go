@@ -0,0 +1,200 @@
+package hub
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// receivePublish accepts a publisher's notification that one or more topics have new content.
+// Topics are named by hub.url, and a batch of them by repeating hub.url[].
+// A fat ping names a single topic in the query, and carries the topic's new content as its body (with its Content-Type),
+// which is distributed as is. Thin pings are form-encoded, and the hub fetches each topic that they name in the body
+// (never the query, which a thin ping has no reason to use).
+// When the hub knows of any publishers, the request must carry the credentials of one, and every topic must be
+// in its allowlist.
+// Fat pings are refused outright when it doesn't, since nobody could vouch for their content.
+// The content is queued for delivery before the hub responds, so that the publisher learns of failures.
+func (hub *Hub) receivePublish(w http.ResponseWriter, req *http.Request, body []byte) {
+	fat := isFatPing(req, body)
+	form := req.PostForm
+	if fat {
+		form = req.URL.Query()
+	}
+
+	topics := append(append([]string{}, form["hub.url"]...), form["hub.url[]"]...)
+	if len(topics) == 0 {
+		http.Error(w, ErrBadRequest{"hub.url", "is missing"}.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, topic := range topics {
+		if err := validateURL("hub.url", topic); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if fat && len(topics) != 1 {
+		http.Error(w, ErrBadRequest{"hub.url", "must name exactly one topic in a fat ping"}.Error(), http.StatusBadRequest)
+		return
+	}
+	if fat && int64(len(body)) > hub.maxContentSize {
+		http.Error(w, ErrContentTooLarge{hub.maxContentSize}.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	for _, topic := range topics {
+		if err := hub.checkPingMode(topic, fat); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if fat && len(hub.publishers) == 0 {
+		http.Error(w, ErrUnauthenticatedContent.Error(), http.StatusForbidden)
+		return
+	}
+
+	authenticated := len(hub.publishers) > 0
+	if authenticated {
+		pub, err := hub.authenticatePublisher(req, body)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		for _, topic := range topics {
+			if !pub.allows(topic) {
+				http.Error(w, ErrTopicNotAllowed{pub.Name, topic}.Error(), http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	if fat {
+		topic := topics[0]
+		queued, err := hub.PublishContent(req.Context(), topic, req.Header.Get("Content-Type"), body)
+		if err != nil {
+			log.Printf("Failed to publish content of topic {%v}: %v\n", topic, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Queued pushed content of topic {%v} for %d subscribers\n", topic, queued)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	var failures []string
+	for _, topic := range topics {
+		queued, err := hub.Publish(req.Context(), topic)
+		if err != nil {
+			log.Printf("Failed to publish topic {%v}: %v\n", topic, err)
+			// Only publishers that we know of learn why, so that the hub doesn't report on hosts for anyone who asks
+			if authenticated {
+				failures = append(failures, topic+": "+err.Error())
+			} else {
+				failures = append(failures, ErrPublishFailed{topic}.Error())
+			}
+			continue
+		}
+		log.Printf("Queued content of topic {%v} for %d subscribers\n", topic, queued)
+	}
+
+	if len(failures) > 0 {
+		http.Error(w, strings.Join(failures, "\n"), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Publish fetches the topic's content, and queues it for delivery to every subscriber of the topic.
+// Topics that nobody subscribes to aren't fetched at all.
+// It returns the number of deliveries queued.
+func (hub *Hub) Publish(ctx context.Context, topic string) (int, error) {
+	subs, err := hub.storage.GetSubscribers(ctx, topic)
+	if err != nil {
+		return 0, err
+	}
+	if len(subs) == 0 {
+		return 0, nil
+	}
+
+	contentType, body, err := hub.fetchContent(ctx, topic)
+	if err != nil {
+		return 0, err
+	}
+
+	return hub.PublishContent(ctx, topic, contentType, body)
+}
+
+// PublishContent queues the given content of the topic for delivery to every subscriber of the topic,
+// without fetching the topic. It returns the number of deliveries queued.
+func (hub *Hub) PublishContent(ctx context.Context, topic, contentType string, body []byte) (int, error) {
+	if int64(len(body)) > hub.maxContentSize {
+		return 0, ErrContentTooLarge{hub.maxContentSize}
+	}
+
+	queued, err := hub.storage.EnqueueContent(ctx, topic, contentType, body)
+	if err != nil {
+		return 0, err
+	}
+
+	hub.wakeDeliveries()
+	return queued, nil
+}
+
+// fetchContent fetches the topic, and returns its Content-Type and body
+func (hub *Hub) fetchContent(ctx context.Context, topic string) (string, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, topic, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := hub.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", nil, ErrTopicStatus{resp.StatusCode}
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, hub.maxContentSize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if int64(len(body)) > hub.maxContentSize {
+		return "", nil, ErrContentTooLarge{hub.maxContentSize}
+	}
+
+	return resp.Header.Get("Content-Type"), body, nil
+}
+
+// isFatPing reports whether the publish request carries the topic's content, rather than a form
+func isFatPing(req *http.Request, body []byte) bool {
+	if len(body) == 0 {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return mediaType != "application/x-www-form-urlencoded"
+}
+
+// checkPingMode checks that the topic accepts the kind of ping (fat or thin) that it was published with
+func (hub *Hub) checkPingMode(topic string, fat bool) error {
+	mode, ok := hub.pingModes[topic]
+	if !ok {
+		mode = hub.defaultPingMode
+	}
+
+	switch {
+	case fat && mode == ThinPings:
+		return ErrBadRequest{"hub.url", "{" + topic + "} only accepts thin pings, without content"}
+	case !fat && mode == FatPings:
+		return ErrBadRequest{"hub.url", "{" + topic + "} only accepts fat pings, with content"}
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package hub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/signature"
+)
+
+/*
+	# Test Cases
+
+	1. Batches of topics are published with hub.url[]
+	2. Publishers that the hub knows of must authenticate, with a bearer token or a signed request
+	3. Publishers may only publish the topics in their allowlist
+	4. Fat pings are distributed byte for byte, with their Content-Type, and without fetching the topic
+	5. Fat pings are limited to MaxContentSize, and to a single topic
+	6. Topics only accept the kind of ping that they are configured for
+	7. Signed requests must carry a fresh timestamp, and are only accepted once
+	8. Thin pings only name topics in their (signed) body, so topics added to the query aren't published
+	9. The query of a fat ping, which names its topic, is signed along with its content
+	10. Fat pings are only accepted from authenticated publishers
+*/
+
+// postPublish sends a publish request for the topics to the hub, with the given headers, and returns the hub's response
+func postPublish(hub *Hub, headers map[string]string, topics ...string) *httptest.ResponseRecorder {
+	return postFormTo(hub, "/", headers, url.Values{"hub.mode": {"publish"}, "hub.url[]": topics})
+}
+
+func TestHub_publish_batch(t *testing.T) {
+	hub := newDeliveryHub(t, "http://hub.example.com/")
+	first := newTestTopic(t, "http://hub.example.com/")
+	second := newTestTopic(t, "http://hub.example.com/")
+	callback, received := newDeliveryCallback(t, 200)
+	ctx := context.Background()
+
+	for _, topic := range []string{first.URL, second.URL} {
+		if err := hub.storage.Subscribe(ctx, topic, callback.URL, "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1. Batches of topics are published with hub.url[]
+	if rec := postPublish(hub, nil, first.URL, second.URL); rec.Code != 202 {
+		t.Fatalf("Expected code 202 but received %d", rec.Code)
+	}
+
+	topics := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		d := expectDelivery(t, received)
+		for _, link := range d.header["Link"] {
+			if strings.HasSuffix(link, "rel=\"self\"") {
+				topics[link] = true
+			}
+		}
+	}
+	if len(topics) != 2 {
+		t.Fatalf("Expected content from both topics, but received %v", topics)
+	}
+
+	missing, _ := newDeliveryCallback(t, 404)
+	if err := hub.storage.Subscribe(ctx, missing.URL, callback.URL, "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if rec := postPublish(hub, nil, first.URL, missing.URL); rec.Code != 502 || !strings.Contains(rec.Body.String(), missing.URL) {
+		t.Fatalf("Expected code 502 naming the failed topic, but received %d {%v}", rec.Code, rec.Body.String())
+	}
+}
+
+// postFatPing pushes the content of the topic to the hub, with the given headers, and returns the hub's response
+func postFatPing(hub *Hub, headers map[string]string, query url.Values, contentType, content string) *httptest.ResponseRecorder {
+	query.Set("hub.mode", "publish")
+	return postTo(hub, "/?"+query.Encode(), contentType, content, headers)
+}
+
+// bearer returns the headers that authenticate a request with the given token
+func bearer(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+// signPublish returns the headers that sign a publish request with the given query and body, as of the given time
+func signPublish(t *testing.T, secret string, signedAt time.Time, query, body string) map[string]string {
+	t.Helper()
+
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	header, err := signature.Sign("sha256", secret, []byte(timestamp+"\n"+query+"\n"+body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{"X-Hub-Signature": header, "X-Hub-Timestamp": timestamp}
+}
+
+func TestHub_publish_fat(t *testing.T) {
+	// The topic's origin is never fetched
+	var fetched int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+	}))
+	t.Cleanup(origin.Close)
+	topic := origin.URL + "/feed"
+
+	cfg := NewConfig()
+	cfg.DeliveryPollInterval = 10 * time.Millisecond
+	cfg.MaxContentSize = 64
+	cfg.DefaultPingMode = AnyPings
+	cfg.Publishers = []*Publisher{{Name: "token", Token: "letmein", Topics: []string{topic + "*"}}}
+	hub := newTestHubFromConfig(t, cfg)
+
+	callback, received := newDeliveryCallback(t, 200)
+	if err := hub.storage.Subscribe(context.Background(), topic, callback.URL, "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// 4. Fat pings are distributed byte for byte, with their Content-Type, and without fetching the topic
+	content := "{\"items\": [1, 2, 3]}\n"
+	if rec := postFatPing(hub, bearer("letmein"), url.Values{"hub.url": {topic}}, "application/json", content); rec.Code != 202 {
+		t.Fatalf("Expected code 202 but received %d {%v}", rec.Code, rec.Body.String())
+	}
+
+	d := expectDelivery(t, received)
+	if d.body != content {
+		t.Fatalf("Expected body {%v} but received {%v}", content, d.body)
+	}
+	if ct := d.header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Expected content type {application/json} but received {%v}", ct)
+	}
+	if n := atomic.LoadInt32(&fetched); n != 0 {
+		t.Fatalf("Expected the topic not to be fetched, but it was fetched %d times", n)
+	}
+
+	// 5. Fat pings are limited to MaxContentSize, and to a single topic
+	if rec := postFatPing(hub, bearer("letmein"), url.Values{"hub.url": {topic}}, "text/plain", strings.Repeat("x", 65)); rec.Code != 413 {
+		t.Fatalf("Expected code 413 but received %d", rec.Code)
+	}
+	if rec := postFatPing(hub, bearer("letmein"), url.Values{"hub.url[]": {topic, topic + "2"}}, "text/plain", "x"); rec.Code != 400 {
+		t.Fatalf("Expected code 400 but received %d", rec.Code)
+	}
+
+	// 10. Fat pings are only accepted from authenticated publishers
+	if rec := postFatPing(hub, nil, url.Values{"hub.url": {topic}}, "text/plain", "forged"); rec.Code != 401 {
+		t.Fatalf("Expected a fat ping without credentials to get code 401, but received %d", rec.Code)
+	}
+	cfg = NewConfig()
+	cfg.DefaultPingMode = AnyPings
+	anonymous := newTestHubFromConfig(t, cfg)
+	if rec := postFatPing(anonymous, nil, url.Values{"hub.url": {topic}}, "text/plain", "forged"); rec.Code != 403 {
+		t.Fatalf("Expected a fat ping to a hub without publishers to get code 403, but received %d", rec.Code)
+	}
+	if len(received) != 0 {
+		t.Fatal("Unauthenticated fat ping was delivered")
+	}
+}
+
+func TestHub_publish_pingModes(t *testing.T) {
+	thin := newTestTopic(t)
+	fat := newTestTopic(t)
+
+	cfg := NewConfig()
+	cfg.PingModes = map[string]PingMode{thin.URL: ThinPings, fat.URL: FatPings}
+	cfg.Publishers = []*Publisher{{Name: "token", Token: "letmein", Topics: []string{thin.URL, fat.URL}}}
+	hub := newTestHubFromConfig(t, cfg)
+	auth := bearer("letmein")
+
+	// 6. Topics only accept the kind of ping that they are configured for (only thin pings, by default)
+	if rec := postFatPing(hub, auth, url.Values{"hub.url": {thin.URL}}, "text/plain", "content"); rec.Code != 400 {
+		t.Fatalf("Expected a fat ping of a thin topic to get code 400, but received %d", rec.Code)
+	}
+	if rec := postPublish(hub, auth, fat.URL); rec.Code != 400 {
+		t.Fatalf("Expected a thin ping of a fat topic to get code 400, but received %d", rec.Code)
+	}
+	if rec := postPublish(hub, auth, thin.URL); rec.Code != 202 {
+		t.Fatalf("Expected a thin ping of a thin topic to get code 202, but received %d", rec.Code)
+	}
+	if rec := postFatPing(hub, auth, url.Values{"hub.url": {fat.URL}}, "text/plain", "content"); rec.Code != 202 {
+		t.Fatalf("Expected a fat ping of a fat topic to get code 202, but received %d", rec.Code)
+	}
+	unlisted := newTestTopic(t)
+	if rec := postFatPing(hub, auth, url.Values{"hub.url": {unlisted.URL}}, "text/plain", "content"); rec.Code != 400 {
+		t.Fatalf("Expected a fat ping of a topic without a ping mode to get code 400, but received %d", rec.Code)
+	}
+}
+
+func TestHub_publish_auth(t *testing.T) {
+	first := newTestTopic(t)
+	second := newTestTopic(t)
+
+	cfg := NewConfig()
+	cfg.Publishers = []*Publisher{
+		{Name: "token", Token: "letmein", Topics: []string{first.URL}},
+		{Name: "signed", Secret: "kitties", Topics: []string{second.URL + "/feeds/*"}},
+	}
+	hub := newTestHubFromConfig(t, cfg)
+	feed := second.URL + "/feeds/1"
+
+	signed := func(secret string, topics ...string) map[string]string {
+		return signPublish(t, secret, time.Now(), "", url.Values{"hub.mode": {"publish"}, "hub.url[]": topics}.Encode())
+	}
+
+	// 2. Publishers that the hub knows of must authenticate, with a bearer token or a signed request
+	for _, test := range []struct {
+		name    string
+		headers map[string]string
+		topic   string
+		code    int
+	}{
+		{"No credentials", nil, first.URL, 401},
+		{"Wrong token", map[string]string{"Authorization": "Bearer letmeout"}, first.URL, 401},
+		{"Not a bearer token", map[string]string{"Authorization": "Basic letmein"}, first.URL, 401},
+		{"Wrong secret", signed("puppies", feed), feed, 401},
+		{"Token", map[string]string{"Authorization": "Bearer letmein"}, first.URL, 202},
+		{"Signed", signed("kitties", feed), feed, 202},
+
+		// 3. Publishers may only publish the topics in their allowlist
+		{"Token outside allowlist", map[string]string{"Authorization": "Bearer letmein"}, feed, 403},
+		{"Signed outside prefix", signed("kitties", second.URL), second.URL, 403},
+		{"Signed outside allowlist", signed("kitties", "http://example.com/topic"), "http://example.com/topic", 403},
+	} {
+		if rec := postPublish(hub, test.headers, test.topic); rec.Code != test.code {
+			t.Fatalf("%s: expected code %d but received %d {%v}", test.name, test.code, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHub_publish_signed(t *testing.T) {
+	signedTopic := newTestTopic(t)
+
+	// The injected topic counts how often it is fetched
+	var fetched int32
+	injected := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+	}))
+	t.Cleanup(injected.Close)
+
+	cfg := NewConfig()
+	cfg.DefaultPingMode = AnyPings
+	cfg.Publishers = []*Publisher{
+		{Name: "signed", Secret: "kitties", Topics: []string{signedTopic.URL, injected.URL}},
+	}
+	hub := newTestHubFromConfig(t, cfg)
+	callback, _ := newDeliveryCallback(t, 200)
+	for _, topic := range []string{signedTopic.URL, injected.URL} {
+		if err := hub.storage.Subscribe(context.Background(), topic, callback.URL, "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const form = "application/x-www-form-urlencoded"
+	body := url.Values{"hub.mode": {"publish"}, "hub.url": {signedTopic.URL}}.Encode()
+
+	// 7. Signed requests must carry a fresh timestamp, and are only accepted once
+	stale := signPublish(t, "kitties", time.Now().Add(-time.Hour), "", body)
+	if rec := postTo(hub, "/", form, body, stale); rec.Code != 401 {
+		t.Fatalf("Expected a stale request to get code 401, but received %d", rec.Code)
+	}
+	untimed := signPublish(t, "kitties", time.Now(), "", body)
+	delete(untimed, "X-Hub-Timestamp")
+	if rec := postTo(hub, "/", form, body, untimed); rec.Code != 401 {
+		t.Fatalf("Expected a request without a timestamp to get code 401, but received %d", rec.Code)
+	}
+	fresh := signPublish(t, "kitties", time.Now(), "", body)
+	if rec := postTo(hub, "/", form, body, fresh); rec.Code != 202 {
+		t.Fatalf("Expected a fresh request to get code 202, but received %d {%v}", rec.Code, rec.Body.String())
+	}
+	if rec := postTo(hub, "/", form, body, fresh); rec.Code != 401 {
+		t.Fatalf("Expected a replayed request to get code 401, but received %d", rec.Code)
+	}
+
+	// 8. Thin pings only name topics in their (signed) body, so topics added to the query aren't published
+	headers := signPublish(t, "kitties", time.Now(), "", body)
+	target := "/?" + url.Values{"hub.url": {injected.URL}}.Encode()
+	if rec := postTo(hub, target, form, body, headers); rec.Code != 401 {
+		t.Fatalf("Expected a request with a query it wasn't signed with to get code 401, but received %d", rec.Code)
+	}
+	headers = signPublish(t, "kitties", time.Now(), url.Values{"hub.url": {injected.URL}}.Encode(), body)
+	if rec := postTo(hub, target, form, body, headers); rec.Code != 202 {
+		t.Fatalf("Expected code 202 but received %d {%v}", rec.Code, rec.Body.String())
+	}
+	if n := atomic.LoadInt32(&fetched); n != 0 {
+		t.Fatalf("Expected the topic in the query not to be published, but it was fetched %d times", n)
+	}
+
+	// 9. The query of a fat ping, which names its topic, is signed along with its content
+	query := url.Values{"hub.mode": {"publish"}, "hub.url": {signedTopic.URL}}.Encode()
+	headers = signPublish(t, "kitties", time.Now(), query, "content")
+	moved := "/?" + url.Values{"hub.mode": {"publish"}, "hub.url": {injected.URL}}.Encode()
+	if rec := postTo(hub, moved, "text/plain", "content", headers); rec.Code != 401 {
+		t.Fatalf("Expected a fat ping moved to another topic to get code 401, but received %d", rec.Code)
+	}
+	if rec := postTo(hub, "/?"+query, "text/plain", "content", headers); rec.Code != 202 {
+		t.Fatalf("Expected a signed fat ping to get code 202, but received %d {%v}", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,137 @@
+package hub
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/signature"
+)
+
+// Publisher is a party that is allowed to notify the hub of new content, on the topics that it owns.
+// A publisher authenticates its publish requests with a bearer token, or by signing them with a secret
+// (in an X-Hub-Signature header, like the hub signs content for subscribers), or with either when both are set.
+// Signed requests carry the unix time that they were signed at in an X-Hub-Timestamp header, and the signature
+// covers that timestamp, the request's query and its body, joined by newlines.
+type Publisher struct {
+	Name   string // identifies the publisher in logs
+	Token  string // the token of an "Authorization: Bearer <token>" header
+	Secret string // the secret that publish requests are signed with
+
+	// Topics lists the topic urls that the publisher may publish.
+	// An entry ending in "*" allows every topic url that begins with the rest of the entry.
+	Topics []string
+}
+
+// allows reports whether the topic is in the publisher's allowlist
+func (pub *Publisher) allows(topic string) bool {
+	for _, allowed := range pub.Topics {
+		if prefix := strings.TrimSuffix(allowed, "*"); prefix != allowed {
+			if strings.HasPrefix(topic, prefix) {
+				return true
+			}
+		} else if topic == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticatePublisher returns the publisher whose credentials the publish request carries.
+// The body is the raw body of the request, which signed requests are verified against.
+func (hub *Hub) authenticatePublisher(req *http.Request, body []byte) (*Publisher, error) {
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if token == auth {
+			return nil, ErrUnauthenticated
+		}
+		for _, pub := range hub.publishers {
+			if pub.Token != "" && subtle.ConstantTimeCompare([]byte(pub.Token), []byte(token)) == 1 {
+				return pub, nil
+			}
+		}
+		return nil, ErrUnauthenticated
+	}
+
+	if header := req.Header.Get("X-Hub-Signature"); header != "" {
+		timestamp := req.Header.Get("X-Hub-Timestamp")
+		signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, ErrStaleSignature
+		}
+		now := time.Now()
+		if skew := now.Sub(time.Unix(signedAt, 0)); skew > hub.publishWindow || skew < -hub.publishWindow {
+			return nil, ErrStaleSignature
+		}
+
+		signed := signedPublish(timestamp, req.URL.RawQuery, body)
+		for _, pub := range hub.publishers {
+			if pub.Secret != "" && signature.Verify(pub.Secret, header, signed) == nil {
+				if hub.publishReplays.replayed(header, now) {
+					return nil, ErrReplayedSignature
+				}
+				return pub, nil
+			}
+		}
+	}
+
+	return nil, ErrUnauthenticated
+}
+
+// signedPublish returns what a signed publish request's signature covers: the timestamp it was signed at,
+// its query (where fat pings name their topic), and its body, joined by newlines
+func signedPublish(timestamp, query string, body []byte) []byte {
+	signed := make([]byte, 0, len(timestamp)+len(query)+len(body)+2)
+	signed = append(signed, timestamp...)
+	signed = append(signed, '\n')
+	signed = append(signed, query...)
+	signed = append(signed, '\n')
+	return append(signed, body...)
+}
+
+// replayCache remembers the signatures of the publish requests accepted recently, so that a captured request
+// can't be sent again while its timestamp is still fresh. Signatures are forgotten once their window has passed,
+// since the timestamp check turns away anything older.
+type replayCache struct {
+	window time.Duration
+
+	mut   sync.Mutex
+	seen  map[string]struct{}
+	order []seenSignature // the same signatures, oldest first, so that expired ones are cheap to forget
+}
+
+type seenSignature struct {
+	header string
+	at     time.Time
+}
+
+func newReplayCache(window time.Duration) *replayCache {
+	return &replayCache{
+		window: window,
+		seen:   make(map[string]struct{}),
+	}
+}
+
+// replayed reports whether the signature was already accepted within the window.
+// Signatures that weren't are remembered from now on.
+func (c *replayCache) replayed(header string, now time.Time) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// A signature stays fresh for at most twice the window after it was first accepted, since its timestamp
+	// may have been up to a window ahead of us
+	for len(c.order) > 0 && now.Sub(c.order[0].at) >= 2*c.window {
+		delete(c.seen, c.order[0].header)
+		c.order = c.order[1:]
+	}
+
+	if _, ok := c.seen[header]; ok {
+		return true
+	}
+	c.seen[header] = struct{}{}
+	c.order = append(c.order, seenSignature{header: header, at: now})
+	return false
+}
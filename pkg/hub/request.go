@@ -0,0 +1,173 @@
+package hub
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/signature"
+)
+
+// maxRequestSize is the largest request body that the hub reads, which matches net/http's limit on forms
+const maxRequestSize = 10 << 20
+
+// subscriptionRequest is a subscriber's request to subscribe to, or unsubscribe from, a topic
+type subscriptionRequest struct {
+	mode     string
+	topic    string
+	callback string
+	secret   string
+	lease    time.Duration // the lease that the hub will grant, if the subscriber verifies its intent
+}
+
+// hubSwitch is the branching point between the various types of requests that the hub accepts
+func (hub *Hub) hubSwitch(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Hub: requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The raw body is kept, so that signed publish requests can be verified against it.
+	// Fat pings carry the topic's content as their body, so they may be as large as the content that the hub distributes.
+	limit := int64(maxRequestSize)
+	if hub.maxContentSize > limit {
+		limit = hub.maxContentSize
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, limit+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > limit {
+		http.Error(w, "Hub: request body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Fat pings name the mode in the query, since their body is the topic's content
+	mode := req.PostForm.Get("hub.mode")
+	if mode == "" && req.URL.Query().Get("hub.mode") == "publish" {
+		mode = "publish"
+	}
+
+	switch mode {
+	case "subscribe", "unsubscribe":
+		hub.receiveSubscriptionRequest(w, req)
+	case "publish":
+		hub.receivePublish(w, req, body)
+	default:
+		http.Error(w, ErrBadRequest{"hub.mode", "{" + mode + "} is not supported"}.Error(), http.StatusBadRequest)
+	}
+}
+
+// receiveSubscriptionRequest accepts a subscription request, and verifies the subscriber's intent in the background
+func (hub *Hub) receiveSubscriptionRequest(w http.ResponseWriter, req *http.Request) {
+	subReq, err := hub.parseSubscriptionRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	hub.verifications.Add(1)
+	go func() {
+		defer hub.verifications.Done()
+		if err := hub.verify(subReq); err != nil {
+			log.Printf("Failed to %s callback {%v} to topic {%v}: %v\n", subReq.mode, subReq.callback, subReq.topic, err)
+		}
+	}()
+}
+
+// parseSubscriptionRequest validates the parameters of a subscription request, and settles the lease to grant
+func (hub *Hub) parseSubscriptionRequest(req *http.Request) (*subscriptionRequest, error) {
+	form := req.PostForm
+	subReq := &subscriptionRequest{
+		mode:     form.Get("hub.mode"),
+		topic:    form.Get("hub.topic"),
+		callback: form.Get("hub.callback"),
+		secret:   form.Get("hub.secret"),
+	}
+
+	if err := validateURL("hub.topic", subReq.topic); err != nil {
+		return nil, err
+	}
+	if err := validateURL("hub.callback", subReq.callback); err != nil {
+		return nil, err
+	}
+
+	// The spec only allows secrets that are short, and that weren't exposed on the way here
+	if subReq.secret != "" {
+		if len(subReq.secret) >= signature.MaxSecretLength {
+			return nil, ErrBadRequest{"hub.secret", fmt.Sprintf("must be less than %d bytes", signature.MaxSecretLength)}
+		}
+		if !hub.secure(req) {
+			return nil, ErrBadRequest{"hub.secret", "must only be sent over https"}
+		}
+	}
+
+	subReq.lease = hub.defaultLease
+	if leaseSeconds := form.Get("hub.lease_seconds"); leaseSeconds != "" {
+		seconds, err := strconv.ParseInt(leaseSeconds, 10, 64)
+		if err != nil || seconds <= 0 {
+			return nil, ErrBadRequest{"hub.lease_seconds", "must be a positive number of seconds"}
+		}
+		subReq.lease = hub.clampLease(time.Duration(seconds) * time.Second)
+	}
+
+	return subReq, nil
+}
+
+// secure reports whether the request reached the hub over https.
+// Hubs that trust X-Forwarded-Proto go by the protocol that the closest proxy saw, which it appends last.
+func (hub *Hub) secure(req *http.Request) bool {
+	if req.TLS != nil {
+		return true
+	}
+	if !hub.trustForwardedProto {
+		return false
+	}
+
+	protos := strings.Split(strings.Join(req.Header["X-Forwarded-Proto"], ","), ",")
+	return strings.EqualFold(strings.TrimSpace(protos[len(protos)-1]), "https")
+}
+
+// clampLease keeps the lease that a subscriber asked for between the hub's minimum and maximum
+func (hub *Hub) clampLease(lease time.Duration) time.Duration {
+	if hub.minLease > 0 && lease < hub.minLease {
+		return hub.minLease
+	}
+	if hub.maxLease > 0 && lease > hub.maxLease {
+		return hub.maxLease
+	}
+	return lease
+}
+
+// validateURL checks that the value of the named parameter is an absolute http(s) url
+func validateURL(param, value string) error {
+	if value == "" {
+		return ErrBadRequest{param, "is missing"}
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return ErrBadRequest{param, "{" + value + "} is not an absolute url"}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrBadRequest{param, "{" + value + "} is not an http(s) url"}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package hub
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/hub/storage"
+	"github.com/adamsanghera/go-websub/pkg/signature"
+)
+
+/*
+	# Test Cases
+
+	1. Subscription requests are accepted, verified, and recorded with a clamped lease and their secret
+	2. Renewals replace the lease
+	3. Unsubscription requests are verified, and remove the subscription
+	4. Malformed requests are rejected outright, including secrets that are too long or sent over plain http
+	   (which X-Forwarded-Proto only vouches for when the hub is configured to trust it)
+	5. Callbacks that fail to echo the challenge are not subscribed
+	6. Topics that don't advertise the hub are denied
+*/
+
+func TestHub_subscribe(t *testing.T) {
+	hub := newTestHub(t, "https://hub.example.com/")
+	topic := newTestTopic(t, "https://hub.example.com/")
+	callback, received := newTestCallback(t, echoChallenge)
+	ctx := context.Background()
+
+	// 1. Subscription requests are accepted, verified, and recorded with a clamped lease and their secret
+	rec := postSecureForm(hub, url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {topic.URL},
+		"hub.callback":      {callback.URL + "/cb?id=1"},
+		"hub.lease_seconds": {"1"},
+		"hub.secret":        {"secret"},
+	})
+	if rec.Code != 202 {
+		t.Fatalf("Expected code 202 but received %d", rec.Code)
+	}
+	hub.verifications.Wait()
+
+	query := <-received
+	if query.Get("hub.mode") != "subscribe" || query.Get("hub.topic") != topic.URL || query.Get("id") != "1" {
+		t.Fatalf("Expected a subscribe verification that keeps the callback's query, but got {%v}", query)
+	}
+	if query.Get("hub.lease_seconds") != "300" {
+		t.Fatalf("Expected the lease to be raised to the minimum of {300} seconds, but got {%v}", query.Get("hub.lease_seconds"))
+	}
+
+	sub, err := hub.storage.GetSubscription(ctx, topic.URL, callback.URL+"/cb?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.LeaseSeconds != 300 || sub.Secret != "secret" {
+		t.Fatalf("Expected a 300 second lease with a secret, but got {%v}", sub)
+	}
+
+	// 2. Renewals replace the lease
+	postForm(hub, url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {topic.URL},
+		"hub.callback": {callback.URL + "/cb?id=1"},
+	})
+	hub.verifications.Wait()
+	<-received
+
+	sub, err = hub.storage.GetSubscription(ctx, topic.URL, callback.URL+"/cb?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.LeaseSeconds != int64(hub.defaultLease/time.Second) {
+		t.Fatalf("Expected the default lease to be granted, but got {%v}", sub)
+	}
+
+	// 3. Unsubscription requests are verified, and remove the subscription
+	rec = postForm(hub, url.Values{
+		"hub.mode":     {"unsubscribe"},
+		"hub.topic":    {topic.URL},
+		"hub.callback": {callback.URL + "/cb?id=1"},
+	})
+	if rec.Code != 202 {
+		t.Fatalf("Expected code 202 but received %d", rec.Code)
+	}
+	hub.verifications.Wait()
+
+	query = <-received
+	if query.Get("hub.mode") != "unsubscribe" || query.Get("hub.lease_seconds") != "" {
+		t.Fatalf("Expected an unsubscribe verification without a lease, but got {%v}", query)
+	}
+	if _, err := hub.storage.GetSubscription(ctx, topic.URL, callback.URL+"/cb?id=1"); err != storage.ErrNotFound {
+		t.Fatalf("Expected the subscription to be removed, but got {%v}", err)
+	}
+}
+
+func TestHub_badRequests(t *testing.T) {
+	hub := newTestHub(t, "")
+
+	// 4. Malformed requests are rejected outright, including secrets that are too long or sent over plain http
+	for _, form := range []url.Values{
+		{"hub.mode": {"subscribe"}, "hub.topic": {"http://example.com/topic"}},
+		{"hub.mode": {"subscribe"}, "hub.topic": {"/topic"}, "hub.callback": {"http://example.com/cb"}},
+		{"hub.mode": {"subscribe"}, "hub.topic": {"ftp://example.com/topic"}, "hub.callback": {"http://example.com/cb"}},
+		{"hub.mode": {"subscribe"}, "hub.topic": {"http://example.com/topic"}, "hub.callback": {"http://example.com/cb"}, "hub.lease_seconds": {"-1"}},
+		{"hub.mode": {"listen"}, "hub.topic": {"http://example.com/topic"}, "hub.callback": {"http://example.com/cb"}},
+		{"hub.mode": {"subscribe"}, "hub.topic": {"http://example.com/topic"}, "hub.callback": {"http://example.com/cb"}, "hub.secret": {"secret"}},
+	} {
+		if rec := postForm(hub, form); rec.Code != 400 {
+			t.Fatalf("Expected code 400 for {%v} but received %d", form, rec.Code)
+		}
+	}
+
+	secure := newTestHub(t, "https://hub.example.com/")
+	topic := newTestTopic(t, "https://hub.example.com/")
+	callback, _ := newTestCallback(t, echoChallenge)
+	for secret, code := range map[string]int{
+		strings.Repeat("s", signature.MaxSecretLength-1): 202,
+		strings.Repeat("s", signature.MaxSecretLength):   400,
+	} {
+		form := url.Values{
+			"hub.mode":     {"subscribe"},
+			"hub.topic":    {topic.URL},
+			"hub.callback": {callback.URL},
+			"hub.secret":   {secret},
+		}
+		if rec := postSecureForm(secure, form); rec.Code != code {
+			t.Fatalf("Expected code %d for a %d byte secret but received %d", code, len(secret), rec.Code)
+		}
+	}
+	secure.verifications.Wait()
+
+	cfg := NewConfig()
+	cfg.URL = "https://hub.example.com/"
+	cfg.TrustForwardedProto = true
+	proxied := newTestHubFromConfig(t, cfg)
+	form := url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {topic.URL},
+		"hub.callback": {callback.URL},
+		"hub.secret":   {"secret"},
+	}
+	for _, test := range []struct {
+		name    string
+		hub     *Hub
+		headers map[string]string
+		code    int
+	}{
+		{"Plain http to an https hub", secure, nil, 400},
+		{"Untrusted X-Forwarded-Proto", secure, map[string]string{"X-Forwarded-Proto": "https"}, 400},
+		{"Trusted X-Forwarded-Proto of http", proxied, map[string]string{"X-Forwarded-Proto": "https, http"}, 400},
+		{"Trusted X-Forwarded-Proto of https", proxied, map[string]string{"X-Forwarded-Proto": "https"}, 202},
+	} {
+		if rec := postFormTo(test.hub, "/", test.headers, form); rec.Code != test.code {
+			t.Fatalf("%s: expected code %d but received %d", test.name, test.code, rec.Code)
+		}
+	}
+	proxied.verifications.Wait()
+
+	rec := httptest.NewRecorder()
+	hub.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != 405 {
+		t.Fatalf("Expected code 405 but received %d", rec.Code)
+	}
+}
+
+func TestHub_verificationFailures(t *testing.T) {
+	hub := newTestHub(t, "http://hub.example.com/")
+	topic := newTestTopic(t, "http://hub.example.com/")
+	elsewhere := newTestTopic(t, "http://elsewhere.example.com/")
+
+	// 5. Callbacks that fail to echo the challenge are not subscribed
+	callback, received := newTestCallback(t, func(query url.Values) (int, string) {
+		return 200, "not the challenge"
+	})
+	postForm(hub, url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {topic.URL},
+		"hub.callback": {callback.URL},
+	})
+	hub.verifications.Wait()
+	<-received
+
+	if subs, err := hub.storage.GetSubscribers(context.Background(), topic.URL); err != nil || len(subs) != 0 {
+		t.Fatalf("Expected no subscribers, but got {%v, %v}", subs, err)
+	}
+
+	// 6. Topics that don't advertise the hub are denied
+	callback, received = newTestCallback(t, echoChallenge)
+	postForm(hub, url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {elsewhere.URL},
+		"hub.callback": {callback.URL},
+	})
+	hub.verifications.Wait()
+
+	query := <-received
+	if query.Get("hub.mode") != "denied" || query.Get("hub.topic") != elsewhere.URL || query.Get("hub.reason") == "" {
+		t.Fatalf("Expected the subscription to be denied with a reason, but got {%v}", query)
+	}
+	if subs, err := hub.storage.GetSubscribers(context.Background(), elsewhere.URL); err != nil || len(subs) != 0 {
+		t.Fatalf("Expected no subscribers, but got {%v, %v}", subs, err)
+	}
+}
@@ -0,0 +1,42 @@
+package hub
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how failed deliveries of content are retried.
+// Network errors and non-2xx responses (other than 410 Gone) are retried with an exponential backoff,
+// until the delivery has been tried MaxAttempts times, after which it is dead-lettered.
+type RetryPolicy struct {
+	MaxAttempts int           // the number of tries a delivery gets, including the first one
+	BaseBackoff time.Duration // the wait after the first failed try, which doubles with every try after it
+	MaxBackoff  time.Duration // the longest wait between two tries
+	Jitter      float64       // the fraction of each wait that is randomized, between 0 and 1
+}
+
+// NewRetryPolicy returns the default RetryPolicy
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 8,
+		BaseBackoff: 5 * time.Second,
+		MaxBackoff:  time.Hour,
+		Jitter:      0.2,
+	}
+}
+
+// backoff returns the time to wait after the given (1-indexed) failed attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.BaseBackoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+
+	if jitter := int64(float64(wait) * p.Jitter); jitter > 0 {
+		wait += time.Duration(rand.Int63n(2*jitter) - jitter)
+	}
+	return wait
+}
@@ -0,0 +1,13 @@
+package sql
+
+// Config is the configuration for the storage object
+type Config struct {
+	DSN string // the 'data source name', which the sqlite3 client uses to connect
+}
+
+// NewConfig returns the default Config (foreign keys on, database in-memory only)
+func NewConfig() *Config {
+	return &Config{
+		DSN: ":memory:?_fk=yes",
+	}
+}
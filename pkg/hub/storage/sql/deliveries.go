@@ -0,0 +1,347 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/hub/storage"
+)
+
+// EnqueueContent queues the content for delivery to every subscriber of the topic, and returns how many
+// deliveries were queued. The content is stored once, however many subscribers it goes to.
+func (sqlStor *SQL) EnqueueContent(ctx context.Context, topic, contentType string, body []byte) (queued int, err error) {
+	tx, err := sqlStor.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return 0, err
+	}
+
+	// Defer a rollback, if an error is encountered
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO contents (topic_url, content_type, body)
+		VALUES (?,?,?);`,
+		topic,
+		contentType,
+		body,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	contentID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err = tx.ExecContext(ctx, `
+		INSERT INTO deliveries (content_id, callback_url, next_attempt)
+		SELECT ?, callback_url, ?
+		FROM active_subscriptions
+		WHERE topic_url=?;`,
+		contentID,
+		time.Now().UnixNano(),
+		topic,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// Content that nobody subscribes to isn't worth keeping
+	if n == 0 {
+		if err = deleteOrphanedContent(ctx, tx, contentID); err != nil {
+			return 0, err
+		}
+	}
+
+	return int(n), tx.Commit()
+}
+
+// ClaimDeliveries returns at most 'limit' deliveries that are due, oldest first, and pushes each back by 'claimFor',
+// so that a delivery which is claimed but never settled (say, by a crash) comes due again.
+// Each delivery carries the current secret of its subscription, so that renewals which change the secret take effect.
+// Due deliveries whose subscription's lease has run out are dead-lettered instead, since nobody asked for them anymore.
+func (sqlStor *SQL) ClaimDeliveries(ctx context.Context, limit int, claimFor time.Duration) (deliveries []*storage.Delivery, err error) {
+	tx, err := sqlStor.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return nil, err
+	}
+
+	// Defer a rollback, if an error is encountered
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE deliveries
+		SET last_error=?, dead_at=datetime('now')
+		WHERE dead_at IS NULL AND next_attempt <= ? AND NOT EXISTS (
+			SELECT 1
+			FROM contents
+			JOIN active_subscriptions USING (topic_url)
+			WHERE contents.content_id=deliveries.content_id AND active_subscriptions.callback_url=deliveries.callback_url);`,
+		ErrLeaseExpired.Error(),
+		now.UnixNano(),
+	); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT delivery_id, topic_url, callback_url, secret, content_type, body, attempts, last_error, next_attempt
+		FROM deliveries
+		JOIN contents USING (content_id)
+		JOIN active_subscriptions USING (topic_url, callback_url)
+		WHERE dead_at IS NULL AND next_attempt <= ?
+		ORDER BY next_attempt, delivery_id
+		LIMIT ?;`,
+		now.UnixNano(),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err = scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, delivery := range deliveries {
+		if _, err = tx.ExecContext(ctx, `
+			UPDATE deliveries
+			SET next_attempt=?
+			WHERE delivery_id=?;`,
+			now.Add(claimFor).UnixNano(),
+			delivery.ID,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	return deliveries, tx.Commit()
+}
+
+// CompleteDelivery removes a delivery that succeeded, along with its content once no other delivery needs it
+func (sqlStor *SQL) CompleteDelivery(ctx context.Context, id int64) (err error) {
+	tx, err := sqlStor.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+
+	// Defer a rollback, if an error is encountered
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var contentID int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT content_id
+		FROM deliveries
+		WHERE delivery_id=? AND dead_at IS NULL;`,
+		id,
+	).Scan(&contentID)
+	if err == sql.ErrNoRows {
+		return ErrUpdateFailed{0}
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+		DELETE FROM deliveries
+		WHERE delivery_id=?;`,
+		id,
+	); err != nil {
+		return err
+	}
+
+	if err = deleteOrphanedContent(ctx, tx, contentID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RetryDelivery records a failed try of a delivery, and when it is next due
+func (sqlStor *SQL) RetryDelivery(ctx context.Context, id int64, lastError string, nextAttempt time.Time) error {
+	res, err := sqlStor.db.ExecContext(ctx, `
+		UPDATE deliveries
+		SET attempts=attempts+1, last_error=?, next_attempt=?
+		WHERE delivery_id=? AND dead_at IS NULL;`,
+		lastError,
+		nextAttempt.UnixNano(),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	return exactlyOne(res)
+}
+
+// DeadLetterDelivery records the last failed try of a delivery, which is never tried again
+func (sqlStor *SQL) DeadLetterDelivery(ctx context.Context, id int64, lastError string) error {
+	res, err := sqlStor.db.ExecContext(ctx, `
+		UPDATE deliveries
+		SET attempts=attempts+1, last_error=?, dead_at=datetime('now')
+		WHERE delivery_id=? AND dead_at IS NULL;`,
+		lastError,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	return exactlyOne(res)
+}
+
+// GetDeadLetters returns the deliveries to the topic's subscribers that were given up on, oldest first
+func (sqlStor *SQL) GetDeadLetters(ctx context.Context, topic string) ([]*storage.Delivery, error) {
+	rows, err := sqlStor.db.QueryContext(ctx, `
+		SELECT delivery_id, topic_url, callback_url, secret, content_type, body, attempts, last_error, next_attempt
+		FROM deliveries
+		JOIN contents USING (content_id)
+		LEFT JOIN subscriptions USING (topic_url, callback_url)
+		WHERE dead_at IS NOT NULL AND topic_url=?
+		ORDER BY delivery_id;`,
+		topic,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDeliveries(rows)
+}
+
+// scanDeliveries reads every delivery out of the rows, and closes them
+func scanDeliveries(rows *sql.Rows) ([]*storage.Delivery, error) {
+	defer rows.Close()
+
+	var deliveries []*storage.Delivery
+	for rows.Next() {
+		var delivery storage.Delivery
+		var secret, lastError sql.NullString
+		var nextAttempt int64
+		err := rows.Scan(
+			&delivery.ID, &delivery.Topic, &delivery.Callback, &secret, &delivery.ContentType, &delivery.Body,
+			&delivery.Attempts, &lastError, &nextAttempt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.Secret = secret.String
+		delivery.LastError = lastError.String
+		delivery.NextAttempt = time.Unix(0, nextAttempt)
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// PurgeDeadLetters removes the deliveries that were given up on before the given time, along with their content
+// once no other delivery needs it. It returns the number of dead letters removed.
+func (sqlStor *SQL) PurgeDeadLetters(ctx context.Context, before time.Time) (purged int, err error) {
+	tx, err := sqlStor.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return 0, err
+	}
+
+	// Defer a rollback, if an error is encountered
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	cutoff := before.UTC().Format(sqliteTimeFmt)
+	contentIDs, err := queryContentIDs(ctx, tx, `
+		SELECT DISTINCT content_id
+		FROM deliveries
+		WHERE dead_at IS NOT NULL AND dead_at < ?;`,
+		cutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM deliveries
+		WHERE dead_at IS NOT NULL AND dead_at < ?;`,
+		cutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, contentID := range contentIDs {
+		if err = deleteOrphanedContent(ctx, tx, contentID); err != nil {
+			return 0, err
+		}
+	}
+
+	return int(n), tx.Commit()
+}
+
+// queryContentIDs runs a query that selects content_ids, and returns them
+func queryContentIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contentIDs []int64
+	for rows.Next() {
+		var contentID int64
+		if err := rows.Scan(&contentID); err != nil {
+			return nil, err
+		}
+		contentIDs = append(contentIDs, contentID)
+	}
+
+	return contentIDs, rows.Err()
+}
+
+// deleteOrphanedContent removes the content, unless a delivery still refers to it
+func deleteOrphanedContent(ctx context.Context, tx *sql.Tx, contentID int64) error {
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM contents
+		WHERE content_id=? AND NOT EXISTS (
+			SELECT 1
+			FROM deliveries
+			WHERE content_id=?);`,
+		contentID,
+		contentID,
+	)
+	return err
+}
+
+// exactlyOne returns ErrUpdateFailed, unless the statement touched exactly one row
+func exactlyOne(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrUpdateFailed{n}
+	}
+	return nil
+}
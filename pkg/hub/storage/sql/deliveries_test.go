@@ -0,0 +1,214 @@
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/*
+	# Test Cases
+
+	1. Content is queued for every active subscriber of its topic, and nobody else
+	2. Claimed deliveries carry their subscription's secret, and aren't claimed again until their claim runs out
+	3. Failed deliveries are retried once they're due, and dead letters never are
+	4. Completed deliveries are removed, along with their content once nobody needs it
+	5. Unsubscribing drops the callback's queued deliveries, but keeps its dead letters
+	6. Deliveries whose subscription's lease has run out are dead-lettered, rather than claimed
+	7. Dead letters given up on before the cutoff are purged, along with their content
+	8. The queue survives a restart, when it is kept in a file
+*/
+
+func TestSQL_Deliveries(t *testing.T) {
+	sqlStor, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = sqlStor.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ctx := context.Background()
+
+	for cb, secret := range map[string]string{"cbA": "secret", "cbB": ""} {
+		if err := sqlStor.Subscribe(ctx, "topic", cb, secret, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sqlStor.Subscribe(ctx, "other", "cbC", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1. Content is queued for every active subscriber of its topic, and nobody else
+	queued, err := sqlStor.EnqueueContent(ctx, "topic", "text/plain", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued != 2 {
+		t.Fatalf("Expected 2 deliveries to be queued, but %d were", queued)
+	}
+	if queued, err := sqlStor.EnqueueContent(ctx, "unsubscribed", "text/plain", []byte("hello")); err != nil || queued != 0 {
+		t.Fatalf("Expected no deliveries to be queued, but got {%v, %v}", queued, err)
+	}
+
+	// 2. Claimed deliveries carry their subscription's secret, and aren't claimed again until their claim runs out
+	deliveries, err := sqlStor.ClaimDeliveries(ctx, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("Expected 2 deliveries to be claimed, but got %v", deliveries)
+	}
+	for _, delivery := range deliveries {
+		if delivery.Topic != "topic" || delivery.ContentType != "text/plain" || string(delivery.Body) != "hello" {
+			t.Fatalf("Expected a text/plain delivery of {hello} to topic, but got {%v}", delivery)
+		}
+		if (delivery.Callback == "cbA") != (delivery.Secret == "secret") {
+			t.Fatalf("Expected only cbA's delivery to carry its secret, but got {%v}", delivery)
+		}
+	}
+	if again, err := sqlStor.ClaimDeliveries(ctx, 10, time.Hour); err != nil || len(again) != 0 {
+		t.Fatalf("Expected claimed deliveries not to be claimed again, but got {%v, %v}", again, err)
+	}
+
+	// 3. Failed deliveries are retried once they're due, and dead letters never are
+	a, b := deliveries[0], deliveries[1]
+	if err := sqlStor.RetryDelivery(ctx, a.ID, "timed out", time.Now().Add(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.DeadLetterDelivery(ctx, b.ID, "gave up"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.RetryDelivery(ctx, b.ID, "gave up", time.Now()); err != (ErrUpdateFailed{0}) {
+		t.Fatalf("Expected a dead letter not to be retried, but got {%v}", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	retried, err := sqlStor.ClaimDeliveries(ctx, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retried) != 1 || retried[0].ID != a.ID || retried[0].Attempts != 1 || retried[0].LastError != "timed out" {
+		t.Fatalf("Expected only the retried delivery to come due, but got %v", retried)
+	}
+
+	dead, err := sqlStor.GetDeadLetters(ctx, "topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].ID != b.ID || dead[0].LastError != "gave up" {
+		t.Fatalf("Expected one dead letter, but got %v", dead)
+	}
+
+	// 4. Completed deliveries are removed, along with their content once nobody needs it
+	if err := sqlStor.CompleteDelivery(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.CompleteDelivery(ctx, a.ID); err != (ErrUpdateFailed{0}) {
+		t.Fatalf("Expected a completed delivery to be gone, but got {%v}", err)
+	}
+	var contents int
+	if err := sqlStor.db.QueryRow(`SELECT COUNT(*) FROM contents;`).Scan(&contents); err != nil {
+		t.Fatal(err)
+	}
+	if contents != 1 {
+		t.Fatalf("Expected the dead letter's content to be kept, but %d contents are stored", contents)
+	}
+
+	// 5. Unsubscribing drops the callback's queued deliveries, but keeps its dead letters
+	if _, err := sqlStor.EnqueueContent(ctx, "topic", "text/plain", []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.Unsubscribe(ctx, "topic", "cbA"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.Unsubscribe(ctx, "topic", "cbB"); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := sqlStor.ClaimDeliveries(ctx, 10, time.Hour); err != nil || len(pending) != 0 {
+		t.Fatalf("Expected no deliveries to unsubscribed callbacks, but got {%v, %v}", pending, err)
+	}
+	if dead, err := sqlStor.GetDeadLetters(ctx, "topic"); err != nil || len(dead) != 1 {
+		t.Fatalf("Expected the dead letter to be kept, but got {%v, %v}", dead, err)
+	}
+
+	// 6. Deliveries whose subscription's lease has run out are dead-lettered, rather than claimed
+	if _, err := sqlStor.EnqueueContent(ctx, "other", "text/plain", []byte("late")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlStor.db.Exec(`
+		UPDATE subscriptions
+		SET lease_expiration=?
+		WHERE callback_url='cbC';`,
+		time.Now().Add(-time.Minute).UTC().Format(sqliteTimeFmt),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if expired, err := sqlStor.ClaimDeliveries(ctx, 10, time.Hour); err != nil || len(expired) != 0 {
+		t.Fatalf("Expected no deliveries to an expired subscription, but got {%v, %v}", expired, err)
+	}
+	if dead, err := sqlStor.GetDeadLetters(ctx, "other"); err != nil || len(dead) != 1 || dead[0].LastError != ErrLeaseExpired.Error() {
+		t.Fatalf("Expected the expired delivery to be dead-lettered, but got {%v, %v}", dead, err)
+	}
+
+	// 7. Dead letters given up on before the cutoff are purged, along with their content
+	countContents := func() int {
+		var contents int
+		if err := sqlStor.db.QueryRow(`SELECT COUNT(*) FROM contents;`).Scan(&contents); err != nil {
+			t.Fatal(err)
+		}
+		return contents
+	}
+	if contents := countContents(); contents != 2 {
+		t.Fatalf("Expected only the dead letters' contents to be kept, but %d contents are stored", contents)
+	}
+	if purged, err := sqlStor.PurgeDeadLetters(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Fatalf("Expected no dead letters to be purged before the cutoff, but got {%v, %v}", purged, err)
+	}
+	if purged, err := sqlStor.PurgeDeadLetters(ctx, time.Now().Add(time.Minute)); err != nil || purged != 2 {
+		t.Fatalf("Expected both dead letters to be purged, but got {%v, %v}", purged, err)
+	}
+	if contents := countContents(); contents != 0 {
+		t.Fatalf("Expected the purged dead letters' contents to be removed, but %d contents are stored", contents)
+	}
+}
+
+func TestSQL_Deliveries_durable(t *testing.T) {
+	cfg := &Config{DSN: "file:" + filepath.Join(t.TempDir(), "hub.db") + "?_fk=yes"}
+	ctx := context.Background()
+
+	sqlStor, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.Subscribe(ctx, "topic", "cb", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlStor.EnqueueContent(ctx, "topic", "text/plain", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 8. The queue survives a restart, when it is kept in a file
+	sqlStor, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = sqlStor.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	deliveries, err := sqlStor.ClaimDeliveries(ctx, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || string(deliveries[0].Body) != "hello" {
+		t.Fatalf("Expected the queued delivery to survive the restart, but got %v", deliveries)
+	}
+}
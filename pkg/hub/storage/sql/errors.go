@@ -0,0 +1,38 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMalformedTopic is returned when a topic fails to validate
+	ErrMalformedTopic = errors.New("Hub SQL storage: topic provided is invalid, subscription was not recorded")
+
+	// ErrMalformedCallback is returned when a callback fails to validate
+	ErrMalformedCallback = errors.New("Hub SQL storage: callback provided is invalid, subscription was not recorded")
+
+	// ErrMalformedLease is returned when a subscription is granted a lease that isn't positive
+	ErrMalformedLease = errors.New("Hub SQL storage: lease provided is not positive, subscription was not recorded")
+
+	// ErrLeaseExpired is recorded as the last error of a delivery whose subscription's lease ran out before it was delivered
+	ErrLeaseExpired = errors.New("Hub SQL storage: subscription's lease ran out before the content was delivered")
+)
+
+// ErrUpdateFailed is returned when an update fails to touch exactly one row
+type ErrUpdateFailed struct {
+	numTouched int64
+}
+
+func (e ErrUpdateFailed) Error() string {
+	return fmt.Sprintf("Hub SQL storage: update touched %d rows instead of 1", e.numTouched)
+}
+
+// ErrMalformedTime is returned when a stored timestamp could not be parsed
+type ErrMalformedTime struct {
+	badTime string
+}
+
+func (e ErrMalformedTime) Error() string {
+	return fmt.Sprintf("Hub SQL storage: Stored time value {%s} could not be parsed", e.badTime)
+}
@@ -0,0 +1,71 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/hub/storage"
+)
+
+// GetSubscription returns the callback's subscription to the topic, as long as its lease hasn't run out
+func (sqlStor *SQL) GetSubscription(ctx context.Context, topic, callback string) (*storage.Subscription, error) {
+	row := sqlStor.db.QueryRowContext(ctx, `
+		SELECT callback_url, secret, lease_seconds, lease_expiration
+		FROM active_subscriptions
+		WHERE topic_url=? AND callback_url=?;`,
+		topic,
+		callback,
+	)
+
+	return scanSubscription(topic, row)
+}
+
+// GetSubscribers returns every subscription to the topic whose lease hasn't run out, ordered by callback
+func (sqlStor *SQL) GetSubscribers(ctx context.Context, topic string) ([]*storage.Subscription, error) {
+	rows, err := sqlStor.db.QueryContext(ctx, `
+		SELECT callback_url, secret, lease_seconds, lease_expiration
+		FROM active_subscriptions
+		WHERE topic_url=?
+		ORDER BY callback_url;`,
+		topic,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*storage.Subscription
+	for rows.Next() {
+		sub, err := scanSubscription(topic, rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+// scanSubscription reads a subscription to the topic out of a row of the active_subscriptions view
+func scanSubscription(topic string, row interface{ Scan(...interface{}) error }) (*storage.Subscription, error) {
+	var callback, expiration string
+	var secret sql.NullString
+	var seconds int64
+	if err := row.Scan(&callback, &secret, &seconds, &expiration); err != nil {
+		return nil, err
+	}
+
+	exp, err := time.Parse(sqliteTimeFmt, expiration)
+	if err != nil {
+		return nil, ErrMalformedTime{expiration}
+	}
+
+	return &storage.Subscription{
+		Topic:           topic,
+		Callback:        callback,
+		Secret:          secret.String,
+		LeaseSeconds:    seconds,
+		LeaseExpiration: exp,
+	}, nil
+}
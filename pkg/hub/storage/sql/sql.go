@@ -0,0 +1,62 @@
+package sql
+
+import (
+	"database/sql"
+
+	"github.com/adamsanghera/go-websub/pkg/hub/storage"
+	_ "github.com/mattn/go-sqlite3" // Implementation of sqlite3 driver
+)
+
+// SQL must implement the hub's Storage interface
+var _ storage.Storage = (*SQL)(nil)
+
+// SQL is a sqlite3 implementation of the hub's Storage interface
+type SQL struct {
+	db *sql.DB
+}
+
+// New creates a new sqlite3 storage object, and returns it
+func New(cfg *Config) (*SQL, error) {
+	db, err := sql.Open("sqlite3", cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every connection to an in-memory database gets a database of its own,
+	// and concurrent writers to a file trip over each other's locks, so we stick to one connection.
+	db.SetMaxOpenConns(1)
+
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Create tables, views
+	for _, stmt := range []string{
+		subscriptionTable,
+		contentTable,
+		deliveryTable,
+		deliveryIndex,
+		deliveryContentIndex,
+		activeView,
+	} {
+		if _, err = tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			db.Close()
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQL{db: db}, nil
+}
+
+// Shutdown closes the database
+func (sqlStor *SQL) Shutdown() error {
+	return sqlStor.db.Close()
+}
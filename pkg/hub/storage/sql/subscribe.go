@@ -0,0 +1,102 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Subscribe records a subscription whose intent was verified, granting it the given lease.
+// Subscribing the same callback to the same topic again renews its lease, and replaces its secret.
+func (sqlStor *SQL) Subscribe(ctx context.Context, topic, callback, secret string, lease time.Duration) error {
+	if topic == "" {
+		return ErrMalformedTopic
+	}
+	if callback == "" {
+		return ErrMalformedCallback
+	}
+	seconds := int64(lease / time.Second)
+	if seconds <= 0 {
+		return ErrMalformedLease
+	}
+
+	_, err := sqlStor.db.ExecContext(ctx, `
+		INSERT INTO subscriptions
+		(topic_url, callback_url, secret, lease_seconds, lease_expiration) VALUES
+		(?,?,?,?,?)
+		ON CONFLICT (topic_url, callback_url) DO UPDATE
+		SET
+			secret=excluded.secret,
+			lease_seconds=excluded.lease_seconds,
+			lease_expiration=excluded.lease_expiration;`,
+		topic,
+		callback,
+		sql.NullString{String: secret, Valid: secret != ""},
+		seconds,
+		time.Now().Add(time.Duration(seconds)*time.Second).UTC().Format(sqliteTimeFmt),
+	)
+	return err
+}
+
+// Unsubscribe removes the callback's subscription to the topic, along with the deliveries queued for it.
+// Removing a subscription that doesn't exist returns a handleable error, ErrUpdateFailed.
+func (sqlStor *SQL) Unsubscribe(ctx context.Context, topic, callback string) (err error) {
+	tx, err := sqlStor.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+
+	// Defer a rollback, if an error is encountered
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM subscriptions
+		WHERE topic_url=? AND callback_url=?;`,
+		topic,
+		callback,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrUpdateFailed{n}
+	}
+
+	// Dead letters are kept, so that they can still be inspected
+	contentIDs, err := queryContentIDs(ctx, tx, `
+		SELECT content_id
+		FROM deliveries
+		JOIN contents USING (content_id)
+		WHERE callback_url=? AND topic_url=? AND dead_at IS NULL;`,
+		callback,
+		topic,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, contentID := range contentIDs {
+		if _, err = tx.ExecContext(ctx, `
+			DELETE FROM deliveries
+			WHERE content_id=? AND callback_url=? AND dead_at IS NULL;`,
+			contentID,
+			callback,
+		); err != nil {
+			return err
+		}
+		if err = deleteOrphanedContent(ctx, tx, contentID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,100 @@
+package sql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/hub/storage"
+)
+
+/*
+	# Test Cases
+
+	1. Subscriptions are recorded with their lease and secret
+	2. Subscribing again renews the lease, and replaces the secret
+	3. Subscriptions whose lease ran out are not returned
+	4. Unsubscribing removes the subscription, once
+	5. Malformed subscriptions are rejected
+*/
+
+func TestSQL_Subscribe(t *testing.T) {
+	sqlStor, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = sqlStor.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ctx := context.Background()
+
+	// 1. Subscriptions are recorded with their lease and secret
+	if err := sqlStor.Subscribe(ctx, "topic", "cbB", "secret", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.Subscribe(ctx, "topic", "cbA", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.Subscribe(ctx, "other", "cbA", "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	subs, err := sqlStor.GetSubscribers(ctx, "topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 2 || subs[0].Callback != "cbA" || subs[1].Callback != "cbB" {
+		t.Fatalf("Expected subscribers [cbA cbB] but got %v", subs)
+	}
+	if subs[1].Secret != "secret" || subs[1].LeaseSeconds != 3600 || time.Until(subs[1].LeaseExpiration) < 59*time.Minute {
+		t.Fatalf("Expected a secret and an hour's lease, but got {%v}", subs[1])
+	}
+
+	// 2. Subscribing again renews the lease, and replaces the secret
+	if err := sqlStor.Subscribe(ctx, "topic", "cbB", "", 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := sqlStor.GetSubscription(ctx, "topic", "cbB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Secret != "" || sub.LeaseSeconds != 7200 {
+		t.Fatalf("Expected the renewal to replace the secret and lease, but got {%v}", sub)
+	}
+
+	// 3. Subscriptions whose lease ran out are not returned
+	if err := sqlStor.Subscribe(ctx, "topic", "cbA", "", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second + 100*time.Millisecond)
+	if _, err := sqlStor.GetSubscription(ctx, "topic", "cbA"); err != storage.ErrNotFound {
+		t.Fatalf("Expected an expired subscription not to be returned, but got {%v}", err)
+	}
+	if subs, err := sqlStor.GetSubscribers(ctx, "topic"); err != nil || len(subs) != 1 {
+		t.Fatalf("Expected only cbB to be subscribed, but got {%v, %v}", subs, err)
+	}
+
+	// 4. Unsubscribing removes the subscription, once
+	if err := sqlStor.Unsubscribe(ctx, "topic", "cbB"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.Unsubscribe(ctx, "topic", "cbB"); err != (ErrUpdateFailed{0}) {
+		t.Fatalf("Expected a repeated unsubscription to fail, but got {%v}", err)
+	}
+	if _, err := sqlStor.GetSubscription(ctx, "topic", "cbB"); err != storage.ErrNotFound {
+		t.Fatalf("Expected the unsubscribed callback not to be returned, but got {%v}", err)
+	}
+
+	// 5. Malformed subscriptions are rejected
+	if err := sqlStor.Subscribe(ctx, "", "cb", "", time.Hour); err != ErrMalformedTopic {
+		t.Fatalf("Expected ErrMalformedTopic but got {%v}", err)
+	}
+	if err := sqlStor.Subscribe(ctx, "topic", "", "", time.Hour); err != ErrMalformedCallback {
+		t.Fatalf("Expected ErrMalformedCallback but got {%v}", err)
+	}
+	if err := sqlStor.Subscribe(ctx, "topic", "cb", "", 0); err != ErrMalformedLease {
+		t.Fatalf("Expected ErrMalformedLease but got {%v}", err)
+	}
+}
@@ -0,0 +1,54 @@
+package sql
+
+const (
+	sqliteTimeFmt = "2006-01-02 15:04:05"
+
+	subscriptionTable = `
+		CREATE TABLE IF NOT EXISTS subscriptions (
+			topic_url TEXT NOT NULL,
+			callback_url TEXT NOT NULL,
+			secret TEXT DEFAULT NULL,
+			lease_seconds INTEGER NOT NULL,
+			lease_expiration TEXT NOT NULL,
+			created_at TEXT NOT NULL DEFAULT (datetime('now')),
+
+			CHECK (lease_seconds > 0),
+			PRIMARY KEY (topic_url, callback_url));`
+
+	// Content is stored once per publish, and referenced by each of its deliveries.
+	// Times that the delivery queue is ordered by are unix nanoseconds, since retries may be due within the second.
+	contentTable = `
+		CREATE TABLE IF NOT EXISTS contents (
+			content_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			topic_url TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			body BLOB NOT NULL,
+			published_at TEXT NOT NULL DEFAULT (datetime('now')));`
+
+	deliveryTable = `
+		CREATE TABLE IF NOT EXISTS deliveries (
+			delivery_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			content_id INTEGER NOT NULL,
+			callback_url TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT DEFAULT NULL,
+			next_attempt INTEGER NOT NULL,
+			dead_at TEXT DEFAULT NULL,
+
+			FOREIGN KEY (content_id) REFERENCES contents (content_id));`
+
+	deliveryIndex = `
+		CREATE INDEX IF NOT EXISTS pending_deliveries ON deliveries (dead_at, next_attempt);`
+
+	// Settling a delivery checks whether any other delivery still needs its content
+	deliveryContentIndex = `
+		CREATE INDEX IF NOT EXISTS delivery_contents ON deliveries (content_id);`
+
+	activeView = `
+		CREATE VIEW IF NOT EXISTS active_subscriptions (
+			topic_url, callback_url, secret, lease_seconds, lease_expiration
+		) AS
+		SELECT topic_url, callback_url, secret, lease_seconds, lease_expiration
+		FROM subscriptions
+		WHERE datetime('now') < datetime(lease_expiration);`
+)
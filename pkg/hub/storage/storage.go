@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ErrNotFound is returned by queries that match nothing.
+// It is database/sql's ErrNoRows, so that backends built on database/sql can pass it straight through.
+var ErrNotFound = sql.ErrNoRows
+
+// Subscription is a subscriber's verified interest in a topic
+type Subscription struct {
+	Topic    string
+	Callback string
+	Secret   string // the hub.secret that the subscriber sent, or empty if it sent none
+
+	LeaseSeconds    int64     // the lease that the hub granted
+	LeaseExpiration time.Time // when the lease runs out, unless the subscriber renews it
+}
+
+// Delivery is a piece of a topic's content, queued for delivery to one of the topic's subscribers
+type Delivery struct {
+	ID       int64
+	Topic    string
+	Callback string
+	Secret   string // the hub.secret of the subscription, which the content is signed with (if not empty)
+
+	ContentType string
+	Body        []byte
+
+	Attempts    int       // the number of failed tries so far
+	LastError   string    // the error that the last failed try ended with
+	NextAttempt time.Time // when the delivery is next due
+}
+
+// Storage is the root interface for this package, and manages the hub's subscriptions.
+// Every method takes a context, which bounds the time spent talking to the backend.
+// Implementations must be safe for concurrent use. For more information, see README.md
+type Storage interface {
+	/* Commands */
+
+	// Subscribe records a subscription whose intent was verified, granting it the given lease.
+	// Subscribing the same callback to the same topic again renews its lease, and replaces its secret.
+	Subscribe(ctx context.Context, topic, callback, secret string, lease time.Duration) error
+
+	// Unsubscribe removes the callback's subscription to the topic, along with the deliveries queued for it.
+	Unsubscribe(ctx context.Context, topic, callback string) error
+
+	// EnqueueContent queues the content for delivery to every subscriber of the topic, and returns how many
+	// deliveries were queued. The content is stored once, however many subscribers it goes to.
+	EnqueueContent(ctx context.Context, topic, contentType string, body []byte) (int, error)
+
+	// ClaimDeliveries returns at most 'limit' deliveries that are due, oldest first, and pushes each back by 'claimFor',
+	// so that a delivery which is claimed but never settled (say, by a crash) comes due again.
+	// Due deliveries to subscriptions whose lease has run out are dead-lettered, rather than claimed.
+	ClaimDeliveries(ctx context.Context, limit int, claimFor time.Duration) ([]*Delivery, error)
+
+	// CompleteDelivery removes a delivery that succeeded
+	CompleteDelivery(ctx context.Context, id int64) error
+
+	// RetryDelivery records a failed try of a delivery, and when it is next due
+	RetryDelivery(ctx context.Context, id int64, lastError string, nextAttempt time.Time) error
+
+	// DeadLetterDelivery records the last failed try of a delivery, which is never tried again
+	DeadLetterDelivery(ctx context.Context, id int64, lastError string) error
+
+	// PurgeDeadLetters removes the deliveries that were given up on before the given time, and returns how many it removed
+	PurgeDeadLetters(ctx context.Context, before time.Time) (int, error)
+
+	/* Queries */
+
+	// GetSubscription returns the callback's subscription to the topic, as long as its lease hasn't run out
+	GetSubscription(ctx context.Context, topic, callback string) (*Subscription, error)
+
+	// GetSubscribers returns every subscription to the topic whose lease hasn't run out, ordered by callback
+	GetSubscribers(ctx context.Context, topic string) ([]*Subscription, error)
+
+	// GetDeadLetters returns the deliveries to the topic's subscribers that were given up on, oldest first
+	GetDeadLetters(ctx context.Context, topic string) ([]*Delivery, error)
+
+	/* Lifecycle */
+
+	// Shutdown releases the backend's resources
+	Shutdown() error
+}
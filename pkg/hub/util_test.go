@@ -0,0 +1,103 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newTestHub creates a hub that is reached at the given url, and shuts it down when the test ends
+func newTestHub(t *testing.T, hubURL string) *Hub {
+	t.Helper()
+
+	cfg := NewConfig()
+	cfg.URL = hubURL
+	return newTestHubFromConfig(t, cfg)
+}
+
+// newTestHubFromConfig creates a hub from the given config, and shuts it down when the test ends
+func newTestHubFromConfig(t *testing.T, cfg *Config) *Hub {
+	t.Helper()
+
+	hub, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := hub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return hub
+}
+
+// newTestTopic serves a topic that advertises the given hubs in its Link header
+func newTestTopic(t *testing.T, hubs ...string) *httptest.Server {
+	t.Helper()
+
+	var topic *httptest.Server
+	topic = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for _, hub := range hubs {
+			w.Header().Add("Link", "<"+hub+">; rel=\"hub\"")
+		}
+		w.Header().Add("Link", "<"+topic.URL+">; rel=\"self\"")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("content"))
+	}))
+	t.Cleanup(topic.Close)
+	return topic
+}
+
+// newTestCallback serves a subscriber's callback, which answers the hub with the given function,
+// and passes along the query of every request that it receives
+func newTestCallback(t *testing.T, answer func(query url.Values) (int, string)) (*httptest.Server, chan url.Values) {
+	t.Helper()
+
+	received := make(chan url.Values, 10)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		received <- query
+		code, body := answer(query)
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(callback.Close)
+	return callback, received
+}
+
+// echoChallenge is a callback that confirms every request, by echoing its challenge
+func echoChallenge(query url.Values) (int, string) {
+	return 200, query.Get("hub.challenge")
+}
+
+// postForm sends a form-encoded request to the hub, and returns the hub's response
+func postForm(hub *Hub, form url.Values) *httptest.ResponseRecorder {
+	return postFormTo(hub, "/", nil, form)
+}
+
+// postSecureForm sends a form-encoded request to the hub over https, and returns the hub's response
+func postSecureForm(hub *Hub, form url.Values) *httptest.ResponseRecorder {
+	return postFormTo(hub, "https://hub.example.com/", nil, form)
+}
+
+// postFormTo sends a form-encoded request to the hub at the given target, with the given headers,
+// and returns the hub's response. Targets that are https urls arrive over TLS.
+func postFormTo(hub *Hub, target string, headers map[string]string, form url.Values) *httptest.ResponseRecorder {
+	return postTo(hub, target, "application/x-www-form-urlencoded", form.Encode(), headers)
+}
+
+// postTo sends a request with the given body to the hub at the given target, with the given headers,
+// and returns the hub's response
+func postTo(hub *Hub, target, contentType, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	hub.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,131 @@
+package hub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// verify validates the topic of a subscription request, and verifies the subscriber's intent.
+// Verified subscriptions are recorded, and verified unsubscriptions are removed.
+func (hub *Hub) verify(subReq *subscriptionRequest) error {
+	ctx := hub.verifyCtx
+	if hub.verificationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hub.verificationTimeout)
+		defer cancel()
+	}
+
+	if subReq.mode == "subscribe" && hub.validateTopics {
+		if err := hub.validateTopic(ctx, subReq.topic); err != nil {
+			hub.deny(ctx, subReq, err.Error())
+			return err
+		}
+	}
+
+	if err := hub.verifyIntent(ctx, subReq); err != nil {
+		return err
+	}
+
+	if subReq.mode == "unsubscribe" {
+		return hub.storage.Unsubscribe(ctx, subReq.topic, subReq.callback)
+	}
+	return hub.storage.Subscribe(ctx, subReq.topic, subReq.callback, subReq.secret, subReq.lease)
+}
+
+// validateTopic discovers the topic, and checks that it advertises this hub (if the hub knows its own url)
+func (hub *Hub) validateTopic(ctx context.Context, topic string) error {
+	res, err := hub.discoveries.Discover(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	if hub.url == "" {
+		return nil
+	}
+	for _, advertised := range res.Hubs {
+		if advertised.URL == hub.url {
+			return nil
+		}
+	}
+	return ErrHubNotAdvertised{topic}
+}
+
+// verifyIntent sends the subscriber's callback a challenge, which it must echo back to confirm the request
+func (hub *Hub) verifyIntent(ctx context.Context, subReq *subscriptionRequest) error {
+	challenge := generateChallenge()
+
+	params := url.Values{}
+	params.Set("hub.mode", subReq.mode)
+	params.Set("hub.topic", subReq.topic)
+	params.Set("hub.challenge", challenge)
+	if subReq.mode == "subscribe" {
+		params.Set("hub.lease_seconds", strconv.FormatInt(int64(subReq.lease/time.Second), 10))
+	}
+
+	resp, err := hub.callCallback(ctx, subReq.callback, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return ErrCallbackStatus{resp.StatusCode}
+	}
+
+	// Callbacks that echo more than the challenge don't echo the challenge
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(len(challenge))+1))
+	if err != nil {
+		return err
+	}
+	if string(body) != challenge {
+		return ErrChallengeMismatch
+	}
+
+	return nil
+}
+
+// deny tells the subscriber's callback that its subscription was refused, and why
+func (hub *Hub) deny(ctx context.Context, subReq *subscriptionRequest, reason string) {
+	params := url.Values{}
+	params.Set("hub.mode", "denied")
+	params.Set("hub.topic", subReq.topic)
+	params.Set("hub.reason", reason)
+
+	if resp, err := hub.callCallback(ctx, subReq.callback, params); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// callCallback sends a GET to the callback, with the given parameters added to its query string
+func (hub *Hub) callCallback(ctx context.Context, callback string, params url.Values) (*http.Response, error) {
+	u, err := url.Parse(callback)
+	if err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+	for param, values := range params {
+		query[param] = values
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return hub.client.Do(req.WithContext(ctx))
+}
+
+// helper function to generate a 16-byte (32 chars) string, used as a hub.challenge
+func generateChallenge() string {
+	challenge := make([]byte, 16)
+	rand.Read(challenge)
+	return hex.EncodeToString(challenge)
+}
@@ -0,0 +1,102 @@
+/*
+Package signature signs and verifies the X-Hub-Signature header of WebSub content distribution requests
+(https://www.w3.org/TR/websub/#authenticated-content-distribution).
+
+Hubs sign the body of each delivery with the hub.secret of the subscription, and subscribers verify it,
+so both halves of the protocol share this package to stay byte-compatible.
+*/
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// MaxSecretLength is the length (in bytes) that a hub.secret must stay under, as the spec demands
+const MaxSecretLength = 200
+
+var (
+	// ErrMissingSignature is returned when content arrives without a signature, for a subscription that has a secret
+	ErrMissingSignature = errors.New("Signature: content delivery lacked an X-Hub-Signature header")
+
+	// ErrSignatureMismatch is returned when the signature of a content delivery does not match its body
+	ErrSignatureMismatch = errors.New("Signature: X-Hub-Signature does not match the delivered content")
+)
+
+// ErrUnsupportedMethod is returned for signature methods that the spec doesn't permit
+type ErrUnsupportedMethod struct {
+	Method string
+}
+
+func (e ErrUnsupportedMethod) Error() string {
+	return fmt.Sprintf("Signature: X-Hub-Signature method {%s} is not supported", e.Method)
+}
+
+// hashes maps the methods permitted by the spec to their hash constructors
+var hashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// Supported reports whether the method (e.g. "sha256") is one that the spec permits
+func Supported(method string) bool {
+	_, ok := hashes[strings.ToLower(method)]
+	return ok
+}
+
+// Sign returns the X-Hub-Signature header value (of the form method=signature) for the body,
+// computed with the given method and secret.
+func Sign(method, secret string, body []byte) (string, error) {
+	method = strings.ToLower(method)
+	newHash, ok := hashes[method]
+	if !ok {
+		return "", ErrUnsupportedMethod{method}
+	}
+
+	return method + "=" + hex.EncodeToString(digest(newHash, secret, body)), nil
+}
+
+// Verify checks an X-Hub-Signature header value (of the form method=signature),
+// against the HMAC of the body computed with the given secret.
+func Verify(secret, header string, body []byte) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+
+	parts := strings.SplitN(header, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("Signature: X-Hub-Signature {%s} is malformed", header)
+	}
+
+	newHash, ok := hashes[strings.ToLower(parts[0])]
+	if !ok {
+		return ErrUnsupportedMethod{parts[0]}
+	}
+
+	signature, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return ErrSignatureMismatch
+	}
+
+	if !hmac.Equal(signature, digest(newHash, secret, body)) {
+		return ErrSignatureMismatch
+	}
+
+	return nil
+}
+
+// digest returns the HMAC of the body, keyed by the secret
+func digest(newHash func() hash.Hash, secret string, body []byte) []byte {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,88 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+func sign(newHash func() hash.Hash, secret string, body []byte) string {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSign(t *testing.T) {
+	secret := "kitties"
+	body := []byte("<feed></feed>")
+
+	tests := []struct {
+		method  string
+		newHash func() hash.Hash
+	}{
+		{"sha1", sha1.New},
+		{"sha256", sha256.New},
+		{"sha384", sha512.New384},
+		{"sha512", sha512.New},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.method, func(t *testing.T) {
+			header, err := Sign(test.method, secret, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := test.method + "=" + sign(test.newHash, secret, body); header != expected {
+				t.Fatalf("Expected {%v} but got {%v}", expected, header)
+			}
+			if err := Verify(secret, header, body); err != nil {
+				t.Fatalf("Expected the signature to verify, but got {%v}", err)
+			}
+		})
+	}
+
+	if _, err := Sign("md5", secret, body); err != (ErrUnsupportedMethod{"md5"}) {
+		t.Fatalf("Expected md5 to be unsupported, but got {%v}", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := "kitties"
+	body := []byte("<feed></feed>")
+
+	tests := []struct {
+		name   string
+		header string
+		valid  bool
+	}{
+		{"sha1", "sha1=" + sign(sha1.New, secret, body), true},
+		{"sha256", "sha256=" + sign(sha256.New, secret, body), true},
+		{"sha384", "sha384=" + sign(sha512.New384, secret, body), true},
+		{"sha512", "sha512=" + sign(sha512.New, secret, body), true},
+		{"Upper case method", "SHA256=" + sign(sha256.New, secret, body), true},
+		{"Missing header", "", false},
+		{"Malformed header", sign(sha1.New, secret, body), false},
+		{"Unsupported method", "md5=" + sign(sha1.New, secret, body), false},
+		{"Wrong secret", "sha1=" + sign(sha1.New, "puppies", body), false},
+		{"Wrong method", "sha256=" + sign(sha1.New, secret, body), false},
+		{"Non-hex signature", "sha1=kitties", false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := Verify(secret, test.header, body)
+			if test.valid && err != nil {
+				t.Fatalf("Expected a valid signature, but got {%v}", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("Expected an invalid signature, but it was accepted")
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
@@ -14,8 +15,23 @@ import (
 // callbackSwitch is the branching point between the various types of callback responses.
 func (sub *Subscriber) callbackSwitch(w http.ResponseWriter, req *http.Request) {
 	endpoint := strings.Split(req.URL.Path, "/callback/")[1]
-	reqBody, _ := ioutil.ReadAll(req.Body)
-	query, _ := url.ParseQuery(string(reqBody))
+
+	var query url.Values
+	if req.Method == http.MethodGet {
+		// Hubs verify intent with a GET, carrying their parameters in the query string
+		query = req.URL.Query()
+	} else {
+		reqBody, _ := ioutil.ReadAll(req.Body)
+
+		// Older PubSubHubbub hubs POST their verification messages, which are form-encoded and always carry a hub.mode.
+		// Anything else is content, being distributed by the hub.
+		var isVerification bool
+		query, isVerification = parseVerification(req, reqBody)
+		if !isVerification {
+			sub.receiveContent(w, req, endpoint, reqBody)
+			return
+		}
+	}
 
 	err := sub.updateSubscription(query, endpoint)
 	if err != nil {
@@ -31,7 +47,32 @@ func (sub *Subscriber) callbackSwitch(w http.ResponseWriter, req *http.Request)
 	w.Write([]byte(challenge))
 }
 
+// parseVerification parses the hub's parameters from a callback request.
+// The second return value reports whether the request is a verification message at all.
+func parseVerification(req *http.Request, reqBody []byte) (url.Values, bool) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if req.Header.Get("Content-Type") != "" && mediaType != "application/x-www-form-urlencoded" {
+		return nil, false
+	}
+
+	query, err := url.ParseQuery(string(reqBody))
+	if err != nil || query.Get("hub.mode") == "" {
+		return nil, false
+	}
+
+	return query, true
+}
+
 func (sub *Subscriber) updateSubscription(query url.Values, endpoint string) error {
+	// Hubs may only verify callbacks that we handed out, for the topic that we handed them out for
+	subscription, err := sub.storage.GetLiveSubscription(context.Background(), endpoint)
+	if err != nil {
+		return fmt.Errorf("request on /callback {%s} does not match a subscription that we requested: %v", endpoint, err)
+	}
+	if topic := query.Get("hub.topic"); topic != subscription.Topic {
+		return fmt.Errorf("request on /callback {%s} was for topic {%s} instead of {%s}", endpoint, topic, subscription.Topic)
+	}
+
 	action := query.Get("hub.mode")
 	if action == "subscribe" {
 		seconds, err := time.ParseDuration(query.Get("hub.lease_seconds") + "s")
@@ -43,8 +84,17 @@ func (sub *Subscriber) updateSubscription(query url.Values, endpoint string) err
 		if err != nil {
 			return err
 		}
-		sub.launchRenewal(endpoint, seconds)
-	} else if action == "unsubscribe" || action == "denied" {
+		sub.renewals.schedule(endpoint, time.Now().Add(seconds))
+		sub.resolveVerification(endpoint, nil)
+	} else if action == "unsubscribe" {
+		// Only unsubscriptions that we asked for are confirmed
+		if !sub.takePendingUnsubscription(endpoint) {
+			return fmt.Errorf("request on /callback {%s} verified an unsubscription that was never requested", endpoint)
+		}
+		return sub.storage.Invalidate(context.Background(), endpoint, action+": "+query.Get("hub.reason"))
+	} else if action == "denied" {
+		sub.renewals.cancel(endpoint)
+		sub.resolveVerification(endpoint, ErrSubscriptionDenied{query.Get("hub.reason")})
 		return sub.storage.Invalidate(context.Background(), endpoint, action+": "+query.Get("hub.reason"))
 	} else {
 		return fmt.Errorf("request on /callback {%s} lacked an appropriate hub.mode parameter", endpoint)
@@ -53,18 +103,31 @@ func (sub *Subscriber) updateSubscription(query url.Values, endpoint string) err
 	return nil
 }
 
-// Tries to renew a subscription after 1/3 of the lease duration has expired.
-func (sub *Subscriber) launchRenewal(callback string, leaseSeconds time.Duration) {
-	renewalContext, cancel := context.WithTimeout(context.Background(), leaseSeconds)
+// renew is called by the renewal scheduler, when the lease of the given callback is due for renewal.
+// Renewals that fail while the lease still has time left on it are rescheduled, closer to its expiration.
+func (sub *Subscriber) renew(ctx context.Context, callback string) {
+	err := sub.renewSubscription(ctx, callback)
+	if err == nil {
+		return
+	}
 
-	go time.AfterFunc(leaseSeconds*1/3, func() {
-		defer cancel()
-		err := sub.renewSubscription(renewalContext, callback)
-		if err == context.Canceled {
-			// TODO(adam):
-		}
-		if err == context.DeadlineExceeded {
-			// TODO(adam):
-		}
-	})
+	switch ctx.Err() {
+	case context.Canceled:
+		// The subscription was unsubscribed from, or the Subscriber is shutting down
+		return
+	case context.DeadlineExceeded:
+		log.Printf("Lease of callback {%v} ran out before it could be renewed: %v\n", callback, err)
+		return
+	}
+
+	log.Printf("Failed to renew the lease of callback {%v}: %v\n", callback, err)
+
+	subscription, err := sub.storage.GetSubscription(context.Background(), callback)
+	if err != nil {
+		// The lease is no longer active, so there is nothing left to renew
+		return
+	}
+	if expiration := time.Unix(subscription.LeaseExpiration, 0); time.Until(expiration) > minRenewalRetry {
+		sub.renewals.schedule(callback, expiration)
+	}
 }
@@ -23,11 +23,7 @@ func TestSuccessfulSubscription(t *testing.T) {
 	}
 	httpmock.ActivateNonDefault(sub.client)
 
-	go func(t *testing.T) {
-		if err := sub.Run(); err != http.ErrServerClosed {
-			t.Fatal(err)
-		}
-	}(t)
+	runSubscriber(t, sub)
 
 	var callback string
 
@@ -41,8 +37,8 @@ func TestSuccessfulSubscription(t *testing.T) {
 			}, nil
 		})
 
-	err = sub.storage.IndexOffer(map[string]string{
-		topicURLTest: hubURLTest,
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -57,8 +53,10 @@ func TestSuccessfulSubscription(t *testing.T) {
 		t.Fatalf("Expected callback to not be empty")
 	}
 
+	// Verify with a form-encoded POST, as older PubSubHubbub hubs do
 	data := make(url.Values)
 	data.Set("hub.mode", "subscribe")
+	data.Set("hub.topic", topicURLTest)
 	data.Set("hub.lease_seconds", "2")
 	data.Set("hub.challenge", "121412")
 
@@ -81,7 +79,7 @@ func TestSuccessfulSubscription(t *testing.T) {
 		t.Fatalf("Expected response body {%v} but received {%v}", data.Get("hub.challenge"), string(respBody))
 	}
 
-	cb, err := sub.storage.GetActiveCallback(topicURLTest, hubURLTest)
+	cb, err := sub.storage.GetActiveCallback(context.Background(), topicURLTest, hubURLTest)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -90,10 +88,95 @@ func TestSuccessfulSubscription(t *testing.T) {
 	}
 
 	// Cancel the subscription, wait for the lease to expire, check to see that it's no longer active
-	sub.stickySubscriptions[cb]()
+	sub.renewals.cancel(cb)
 	time.Sleep(3 * time.Second)
-	_, err = sub.storage.GetActiveCallback(topicURLTest, hubURLTest)
+	_, err = sub.storage.GetActiveCallback(context.Background(), topicURLTest, hubURLTest)
 	if err != sql.ErrNoRows {
 		t.Fatal(err)
 	}
+
+	err = sub.Shutdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpmock.DeactivateAndReset()
+}
+
+func TestSubscriber_rejectedVerification(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	var callback string
+	httpmock.RegisterResponder("POST", hubURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			bdy, _ := ioutil.ReadAll(req.Body)
+			vals, _ := url.ParseQuery(string(bdy))
+			callback = vals.Get("hub.callback")
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testClient := &http.Client{Transport: &http.Transport{}}
+	tests := []struct {
+		name     string
+		callback string
+		topic    string
+	}{
+		{"Topic mismatch", callback, "http://example.com/other_topic"},
+		{"Missing topic", callback, ""},
+		{"Callback never requested", "unknown", topicURLTest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := make(url.Values)
+			data.Set("hub.mode", "subscribe")
+			data.Set("hub.topic", test.topic)
+			data.Set("hub.challenge", "kitties")
+			data.Set("hub.lease_seconds", "3")
+
+			resp, err := testClient.Get("http://localhost:4000/callback/" + test.callback + "?" + data.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != 404 {
+				t.Fatalf("Expected code 404 but received %d", resp.StatusCode)
+			}
+			if respBody, _ := ioutil.ReadAll(resp.Body); string(respBody) == "kitties" {
+				t.Fatal("Challenge was echoed for a rejected verification")
+			}
+		})
+	}
+
+	// None of the rejected verifications activated the subscription
+	_, err = sub.storage.GetActiveCallback(context.Background(), topicURLTest, hubURLTest)
+	if err != sql.ErrNoRows {
+		t.Fatalf("Expected {%v} but got {%v}", sql.ErrNoRows, err)
+	}
+
+	// The real verification still goes through
+	verifyCallback(t, topicURLTest, callback, "subscribe")
 }
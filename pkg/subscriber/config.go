@@ -1,13 +1,58 @@
 package subscriber
 
+import (
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/discovery"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+)
+
 // Config is the configuration information for a Subscriber
 type Config struct {
 	port string
+
+	// Backend is where the Subscriber keeps its subscriptions. Nil keeps them in memory, for as long as the
+	// Subscriber lives. A persisted sql.SQL backend lets a restarted Subscriber resume the subscriptions of its
+	// previous run, and replicas that share a postgres.Postgres backend share their subscriptions.
+	// The Subscriber takes ownership of the backend, and shuts it down along with itself.
+	Backend storage.Storage
+
+	// LeaseDuration is the lease requested (as hub.lease_seconds) for every subscription.
+	// Hubs are free to grant a different lease. Zero leaves the lease up to the hub.
+	LeaseDuration time.Duration
+
+	// MaxConcurrentRenewals bounds the number of renewal requests that are sent to hubs at once
+	MaxConcurrentRenewals int
+
+	// Discoverer discovers the hubs of the topics passed to Subscribe. Nil uses the default Discoverer.
+	// Discoveries are cached for as long as the topic allows, and then refreshed with conditional requests.
+	Discoverer *discovery.Discoverer
+
+	// RediscoveryInterval is how often every offered topic is discovered again, to pick up the hubs that it adds
+	// and removes. Zero disables rediscovery.
+	RediscoveryInterval time.Duration
+
+	// DedupeWindow is how long delivered content is remembered for, so that the same content arriving
+	// through another of the topic's hubs isn't delivered twice. Content sent twice by the same hub is
+	// always delivered. Zero disables deduplication.
+	DedupeWindow time.Duration
+
+	// VerificationTimeout is how long a Handle returned by Subscribe waits for its hub to verify or deny
+	// the subscription, before it resolves with ErrVerificationTimeout. Zero waits forever.
+	VerificationTimeout time.Duration
+
+	// RetryPolicy determines how failed requests to hubs are retried. Nil disables retries.
+	RetryPolicy *RetryPolicy
 }
 
 // NewConfig returns the default config for Subscriber
 func NewConfig() *Config {
 	return &Config{
-		"4000",
+		port:                  "4000",
+		MaxConcurrentRenewals: 16,
+		RediscoveryInterval:   24 * time.Hour,
+		DedupeWindow:          10 * time.Minute,
+		VerificationTimeout:   10 * time.Minute,
+		RetryPolicy:           NewRetryPolicy(),
 	}
 }
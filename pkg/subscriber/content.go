@@ -0,0 +1,104 @@
+package subscriber
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/adamsanghera/go-websub/pkg/signature"
+	"github.com/peterhellberg/link"
+)
+
+// Content is a single content distribution request, delivered by a hub to one of our callbacks
+type Content struct {
+	Callback    string // the callback endpoint that the content was delivered to
+	Topic       string // the topic url, taken from the rel=self Link header (falls back to the subscribed topic)
+	Hub         string // the hub url, taken from the rel=hub Link header (falls back to the subscribed hub)
+	ContentType string // the Content-Type of the distributed payload
+	Body        []byte // the payload itself
+}
+
+// ContentHandler is implemented by application code that wants to receive content from hubs
+type ContentHandler interface {
+	HandleContent(content *Content)
+}
+
+// ContentHandlerFunc is an adapter that allows ordinary functions to be used as ContentHandlers
+type ContentHandlerFunc func(content *Content)
+
+// HandleContent calls f(content)
+func (f ContentHandlerFunc) HandleContent(content *Content) {
+	f(content)
+}
+
+// HandleContent registers the handler that is given all content delivered to the Subscriber's callbacks.
+// Content received while no handler is registered is acknowledged, and dropped.
+func (sub *Subscriber) HandleContent(handler ContentHandler) {
+	sub.handlerMut.Lock()
+	defer sub.handlerMut.Unlock()
+
+	sub.contentHandler = handler
+}
+
+// receiveContent processes a content distribution request, made by a hub to the given callback.
+// Content for callbacks without an active subscription is refused with a 410,
+// which tells the hub to drop the subscription on its end.
+// Content that fails signature verification is silently discarded, as is content that was already delivered
+// for the same topic through another hub.
+func (sub *Subscriber) receiveContent(w http.ResponseWriter, req *http.Request, callback string, body []byte) {
+	subscription, err := sub.storage.GetSubscription(req.Context(), callback)
+	if err != nil {
+		log.Printf("Received content on callback %v, which has no active subscription: %v\n", callback, err)
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	// Content for subscriptions with a secret must be signed with it.
+	// Mismatched payloads are acknowledged (so the hub doesn't retry them), but never delivered.
+	secret, err := sub.storage.GetSecret(req.Context(), callback)
+	if err != nil {
+		log.Printf("Failed to look up the secret for callback %v: %v\n", callback, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if secret != "" {
+		if err := signature.Verify(secret, req.Header.Get("X-Hub-Signature"), body); err != nil {
+			log.Printf("Discarding content received on callback %v: %v\n", callback, err)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	if sub.deduper.duplicate(subscription.Topic, callback, body) {
+		log.Printf("Discarding content received on callback %v, which was already delivered through another hub\n", callback)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	content := &Content{
+		Callback:    callback,
+		Topic:       subscription.Topic,
+		Hub:         subscription.Hub,
+		ContentType: req.Header.Get("Content-Type"),
+		Body:        body,
+	}
+
+	// Hubs are expected to advertise the topic and hub of the content in its Link headers
+	for _, l := range link.ParseHeader(req.Header) {
+		switch l.Rel {
+		case "self":
+			content.Topic = l.URI
+		case "hub":
+			content.Hub = l.URI
+		}
+	}
+
+	sub.handlerMut.RLock()
+	handler := sub.contentHandler
+	sub.handlerMut.RUnlock()
+
+	if handler != nil {
+		handler.HandleContent(content)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
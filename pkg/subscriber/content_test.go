@@ -0,0 +1,295 @@
+package subscriber
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/signature"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestSubscriber_receiveContent(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	received := make(chan *Content, 1)
+	sub.HandleContent(ContentHandlerFunc(func(content *Content) {
+		received <- content
+	}))
+
+	runSubscriber(t, sub)
+
+	var callback string
+	httpmock.RegisterResponder("POST", hubURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			bdy, _ := ioutil.ReadAll(req.Body)
+			vals, _ := url.ParseQuery(string(bdy))
+			callback = vals.Get("hub.callback")
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, topicURLTest, callback, "subscribe")
+
+	testClient := &http.Client{Transport: &http.Transport{}}
+
+	// Content is distributed to an active callback
+	req, _ := http.NewRequest("POST", "http://localhost:4000/callback/"+callback, strings.NewReader("<feed></feed>"))
+	req.Header.Set("Content-Type", "application/atom+xml")
+	req.Header.Set("Link", "<"+hubURLTest+">; rel=\"hub\", <"+topicURLTest+">; rel=\"self\"")
+
+	resp, err := testClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected code 200 but received %d", resp.StatusCode)
+	}
+
+	content := <-received
+	if content.Callback != callback {
+		t.Fatalf("Expected callback {%v} but received {%v}", callback, content.Callback)
+	}
+	if content.Topic != topicURLTest || content.Hub != hubURLTest {
+		t.Fatalf("Expected topic/hub {%v, %v} but received {%v, %v}", topicURLTest, hubURLTest, content.Topic, content.Hub)
+	}
+	if content.ContentType != "application/atom+xml" {
+		t.Fatalf("Expected content type {application/atom+xml} but received {%v}", content.ContentType)
+	}
+	if string(content.Body) != "<feed></feed>" {
+		t.Fatalf("Expected body {<feed></feed>} but received {%s}", content.Body)
+	}
+
+	// The hub sending the same content again is delivered again, since only other hubs' copies are duplicates
+	req, _ = http.NewRequest("POST", "http://localhost:4000/callback/"+callback, strings.NewReader("<feed></feed>"))
+	req.Header.Set("Content-Type", "application/atom+xml")
+
+	resp, err = testClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected code 200 but received %d", resp.StatusCode)
+	}
+	select {
+	case content = <-received:
+		if string(content.Body) != "<feed></feed>" {
+			t.Fatalf("Expected body {<feed></feed>} but received {%s}", content.Body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Content sent again by the same hub was not delivered")
+	}
+
+	// Content is distributed to a callback that we know nothing about
+	req, _ = http.NewRequest("POST", "http://localhost:4000/callback/unknown", strings.NewReader("<feed></feed>"))
+	req.Header.Set("Content-Type", "application/atom+xml")
+
+	resp, err = testClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 410 {
+		t.Fatalf("Expected code 410 but received %d", resp.StatusCode)
+	}
+}
+
+func TestSubscriber_receiveContent_signed(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	received := make(chan *Content, 1)
+	sub.HandleContent(ContentHandlerFunc(func(content *Content) {
+		received <- content
+	}))
+
+	runSubscriber(t, sub)
+
+	// Secrets are only shared with https hubs
+	secureHub := "https://example.com/hub"
+
+	var callback, secret string
+	httpmock.RegisterResponder("POST", secureHub,
+		func(req *http.Request) (*http.Response, error) {
+			bdy, _ := ioutil.ReadAll(req.Body)
+			vals, _ := url.ParseQuery(string(bdy))
+			callback = vals.Get("hub.callback")
+			secret = vals.Get("hub.secret")
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {secureHub},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sub.initiateSubscription(context.Background(), topicURLTest, secureHub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == "" {
+		t.Fatal("Expected a hub.secret to be sent to an https hub")
+	}
+	verifyCallback(t, topicURLTest, callback, "subscribe")
+
+	testClient := &http.Client{Transport: &http.Transport{}}
+	body := "<feed></feed>"
+
+	// Content with a mismatched signature is acknowledged, but not delivered
+	req, _ := http.NewRequest("POST", "http://localhost:4000/callback/"+callback, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/atom+xml")
+	wrong, _ := signature.Sign("sha256", "wrong secret", []byte(body))
+	req.Header.Set("X-Hub-Signature", wrong)
+
+	resp, err := testClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected code 200 but received %d", resp.StatusCode)
+	}
+	if len(received) != 0 {
+		t.Fatal("Content with a mismatched signature was delivered")
+	}
+
+	// Content with a matching signature is delivered
+	req, _ = http.NewRequest("POST", "http://localhost:4000/callback/"+callback, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/atom+xml")
+	signed, _ := signature.Sign("sha256", secret, []byte(body))
+	req.Header.Set("X-Hub-Signature", signed)
+
+	resp, err = testClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected code 200 but received %d", resp.StatusCode)
+	}
+	if content := <-received; string(content.Body) != body {
+		t.Fatalf("Expected body {%s} but received {%s}", body, content.Body)
+	}
+}
+
+func TestSubscriber_receiveContent_redundantHubs(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpmock.Activate()
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	received := make(chan *Content, 3)
+	sub.HandleContent(ContentHandlerFunc(func(content *Content) {
+		received <- content
+	}))
+
+	runSubscriber(t, sub)
+
+	hubs := []string{hubURLTest, "http://example.com/hub2", "http://example.com/hub3"}
+	httpmock.RegisterResponder("GET", topicURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			resp := httpmock.NewStringResponse(200, "")
+			for _, hub := range hubs {
+				resp.Header.Add("Link", "<"+hub+">; rel=\"hub\"")
+			}
+			resp.Header.Add("Link", "<"+topicURLTest+">; rel=\"self\"")
+			return resp, nil
+		})
+
+	requested := make(map[string]bool)
+	for _, hub := range hubs {
+		hub := hub
+		httpmock.RegisterResponder("POST", hub,
+			func(req *http.Request) (*http.Response, error) {
+				requested[hub] = true
+				return httpmock.NewStringResponse(202, ""), nil
+			})
+	}
+
+	// Two of the three hubs are subscribed to, for redundancy
+	handles, err := sub.Subscribe(context.Background(), topicURLTest, &SubscribeOptions{Hubs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handles) != 2 || !requested[hubs[0]] || !requested[hubs[1]] || requested[hubs[2]] {
+		t.Fatalf("Expected to subscribe to the first two hubs, but subscribed to %v", requested)
+	}
+
+	offered, err := sub.storage.GetOfferedHubs(context.Background(), topicURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offered) != 3 {
+		t.Fatalf("Expected all three hubs to be indexed, but got %v", offered)
+	}
+
+	testClient := &http.Client{Transport: &http.Transport{}}
+	distribute := func(callback, body string) {
+		req, _ := http.NewRequest("POST", "http://localhost:4000/callback/"+callback, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/atom+xml")
+		resp, err := testClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("Expected code 200 but received %d", resp.StatusCode)
+		}
+	}
+
+	for _, handle := range handles {
+		verifyCallback(t, topicURLTest, handle.Callback, "subscribe")
+	}
+
+	// The same content arrives through both hubs, but is only delivered once
+	distribute(handles[0].Callback, "<feed>1</feed>")
+	distribute(handles[1].Callback, "<feed>1</feed>")
+	distribute(handles[1].Callback, "<feed>2</feed>")
+
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 deliveries but got %d", len(received))
+	}
+	if first, second := <-received, <-received; string(first.Body) != "<feed>1</feed>" || string(second.Body) != "<feed>2</feed>" {
+		t.Fatalf("Expected each piece of content once, but got {%s} and {%s}", first.Body, second.Body)
+	}
+}
@@ -0,0 +1,64 @@
+package subscriber
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// contentDeduper remembers the content recently delivered for each topic, so that content arriving through
+// several hubs (when a topic is subscribed to through more than one of them) is only delivered once.
+// Content is only ever a duplicate when it arrives through another callback than it first did, since a hub
+// is free to send the same content twice (say, a status that flips back to what it was).
+type contentDeduper struct {
+	window time.Duration // how long delivered content is remembered for
+
+	mut   sync.Mutex
+	seen  map[[sha256.Size]byte]string // the callback that each piece of content was first delivered through
+	order []seenContent                // when each piece of content was first delivered, oldest first, so that expired content is cheap to forget
+}
+
+type seenContent struct {
+	key [sha256.Size]byte
+	at  time.Time
+}
+
+func newContentDeduper(window time.Duration) *contentDeduper {
+	return &contentDeduper{
+		window: window,
+		seen:   make(map[[sha256.Size]byte]string),
+	}
+}
+
+// duplicate reports whether the same content was already delivered for the topic, through another callback,
+// within the window. Content that hasn't been delivered yet is remembered from now on.
+func (d *contentDeduper) duplicate(topic, callback string, body []byte) bool {
+	if d.window <= 0 {
+		return false
+	}
+
+	h := sha256.New()
+	h.Write([]byte(topic))
+	h.Write([]byte{0})
+	h.Write(body)
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+
+	now := time.Now()
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	// Forget whatever has fallen out of the window
+	for len(d.order) > 0 && now.Sub(d.order[0].at) >= d.window {
+		delete(d.seen, d.order[0].key)
+		d.order = d.order[1:]
+	}
+
+	if first, ok := d.seen[key]; ok {
+		return first != callback
+	}
+	d.seen[key] = callback
+	d.order = append(d.order, seenContent{key: key, at: now})
+	return false
+}
@@ -1,21 +1,21 @@
 package subscriber
 
 import (
-	"github.com/adamsanghera/go-websub/pkg/discovery"
+	"context"
 )
 
-// DiscoverTopic runs the common discovery algorithm, and indexes the results
+// DiscoverTopic runs the common discovery algorithm, and indexes the results.
+// The topic is only fetched again once its last discovery goes stale, and then conditionally.
 func (sc *Subscriber) DiscoverTopic(topic string) error {
-	hubs, self, err := discovery.DiscoverTopic(topic)
+	ctx := context.Background()
+	res, err := sc.discoveries.Discover(ctx, topic)
 	if err != nil {
 		return err
 	}
 
-	// NOTE(adam) consider rewriting DiscoverTopic or SubscriptionAddOffer
-	topicsToHubs := make(map[string]string)
-	for h := range hubs {
-		topicsToHubs[self] = h
+	hubs := make([]string, 0, len(res.Hubs))
+	for _, hub := range res.Hubs {
+		hubs = append(hubs, hub.URL)
 	}
-
-	return sc.storage.IndexOffer(topicsToHubs)
+	return sc.storage.IndexOffer(ctx, map[string][]string{res.Self.URL: hubs})
 }
@@ -0,0 +1,108 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrVerificationTimeout is returned by a Handle when its hub neither verifies nor denies the subscription in time
+var ErrVerificationTimeout = errors.New("Subscriber: hub did not verify the subscription in time")
+
+// ErrSubscriptionDenied is returned by a Handle when the hub denies its subscription
+type ErrSubscriptionDenied struct {
+	Reason string
+}
+
+func (e ErrSubscriptionDenied) Error() string {
+	return fmt.Sprintf("Subscriber: hub denied the subscription, reason: {%s}", e.Reason)
+}
+
+// Handle tracks a subscription request made with Subscribe, until its hub verifies or denies it
+type Handle struct {
+	Topic    string // the topic subscribed to, as advertised by its rel=self link
+	Hub      string // the hub that the request was sent to
+	Callback string // the callback that the hub will verify, and deliver content to
+
+	done    chan struct{}
+	err     error
+	timeout *time.Timer // resolves the handle, if the hub takes too long to verify
+}
+
+func newHandle(topic, hub, callback string) *Handle {
+	return &Handle{
+		Topic:    topic,
+		Hub:      hub,
+		Callback: callback,
+		done:     make(chan struct{}),
+	}
+}
+
+// Done returns a channel that is closed once the hub has verified or denied the subscription
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Err returns nil if the hub verified the subscription, ErrSubscriptionDenied if it was denied,
+// and ErrVerificationTimeout if the hub took too long to do either.
+// It must only be called after Done is closed.
+func (h *Handle) Err() error {
+	return h.err
+}
+
+// Wait blocks until the hub verifies or denies the subscription, or until the context is done.
+func (h *Handle) Wait(ctx context.Context) error {
+	select {
+	case <-h.done:
+		return h.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trackVerification registers a handle, to be resolved when its callback is verified or denied.
+// Handles that are still pending after the Subscriber's verification timeout are resolved with ErrVerificationTimeout.
+func (sub *Subscriber) trackVerification(handle *Handle) {
+	sub.handleMut.Lock()
+	defer sub.handleMut.Unlock()
+
+	sub.pendingHandles[handle.Callback] = handle
+	if sub.verificationTimeout > 0 {
+		handle.timeout = time.AfterFunc(sub.verificationTimeout, func() {
+			sub.resolveVerification(handle.Callback, ErrVerificationTimeout)
+		})
+	}
+}
+
+// untrackVerification forgets about the handle of the given callback, without resolving it
+func (sub *Subscriber) untrackVerification(callback string) {
+	sub.handleMut.Lock()
+	defer sub.handleMut.Unlock()
+
+	if handle, ok := sub.pendingHandles[callback]; ok {
+		handle.stopTimeout()
+		delete(sub.pendingHandles, callback)
+	}
+}
+
+// resolveVerification resolves the handle of the given callback, if one is still pending
+func (sub *Subscriber) resolveVerification(callback string, err error) {
+	sub.handleMut.Lock()
+	handle, ok := sub.pendingHandles[callback]
+	delete(sub.pendingHandles, callback)
+	sub.handleMut.Unlock()
+
+	if ok {
+		handle.stopTimeout()
+		handle.err = err
+		close(handle.done)
+	}
+}
+
+// stopTimeout disarms the handle's verification timeout, if it has one
+func (h *Handle) stopTimeout() {
+	if h.timeout != nil {
+		h.timeout.Stop()
+	}
+}
@@ -0,0 +1,85 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// maxRedirects is the longest chain of redirects that a single request to a hub will follow
+const maxRedirects = 10
+
+var (
+	// ErrTooManyRedirects is returned when a hub request is redirected more than maxRedirects times
+	ErrTooManyRedirects = errors.New("Subscriber: hub request was redirected too many times")
+
+	// ErrRedirectLoop is returned when a hub request is redirected back to a hub that it already visited
+	ErrRedirectLoop = errors.New("Subscriber: hub request was redirected in a loop")
+
+	// ErrMissingLocation is returned when a hub redirects a request without saying where to
+	ErrMissingLocation = errors.New("Subscriber: hub redirected without a Location")
+
+	// ErrInsecureRedirect is returned when a subscription request that shares a secret is redirected to a plain http hub
+	ErrInsecureRedirect = errors.New("Subscriber: hub redirected a request with a secret to a plain http hub")
+)
+
+// redirectChain tracks the hubs visited while following the redirects of a single request
+type redirectChain struct {
+	visited []string
+	stored  string // the hub that storage knows the topic by, which moves along with permanent redirects
+}
+
+func newRedirectChain(hub string) *redirectChain {
+	return &redirectChain{
+		visited: []string{hub},
+		stored:  hub,
+	}
+}
+
+// followRedirect returns the hub that a 307 or 308 response (to a request made to the given hub) redirects to.
+// Temporary redirects leave storage alone, since the topic is still offered by the hub that redirected.
+// Permanent redirects away from the hub in storage migrate the topic's offer and subscription to the new hub,
+// so that later requests go straight there.
+func (sub *Subscriber) followRedirect(ctx context.Context, topic, hub string, resp *http.Response, chain *redirectChain) (string, error) {
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", ErrMissingLocation
+	}
+
+	// Locations may be relative to the hub that sent them
+	base, err := url.Parse(hub)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	newHub := base.ResolveReference(ref).String()
+
+	if len(chain.visited) > maxRedirects {
+		return "", ErrTooManyRedirects
+	}
+	for _, visited := range chain.visited {
+		if visited == newHub {
+			return "", ErrRedirectLoop
+		}
+	}
+	chain.visited = append(chain.visited, newHub)
+
+	if resp.StatusCode == http.StatusTemporaryRedirect {
+		log.Printf("Temporary redirect response, to new address {%v}", newHub)
+		return newHub, nil
+	}
+
+	log.Printf("Permanent redirect response, to new address {%v}", newHub)
+	if hub == chain.stored {
+		if err := sub.storage.MigrateHub(ctx, topic, hub, newHub); err != nil {
+			return "", err
+		}
+		chain.stored = newHub
+	}
+	return newHub, nil
+}
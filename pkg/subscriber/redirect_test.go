@@ -0,0 +1,216 @@
+package subscriber
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"testing"
+
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestSubscriber_permanentRedirect(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	// The hub has moved for good, and says so with a relative Location
+	redirectDest := "http://example.com/new_hub"
+	oldHubRequests, newHubRequests := 0, 0
+	httpmock.RegisterResponder("POST", hubURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			oldHubRequests++
+			resp := httpmock.NewStringResponse(308, "")
+			resp.Header.Set("Location", "/new_hub")
+			return resp, nil
+		})
+	httpmock.RegisterResponder("POST", redirectDest,
+		func(req *http.Request) (*http.Response, error) {
+			newHubRequests++
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handle := newHandle(topicURLTest, hubURLTest, generateCallback())
+	err = sub.requestSubscription(context.Background(), topicURLTest, hubURLTest, handle.Callback, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, topicURLTest, handle.Callback, "subscribe")
+
+	// The topic is now offered, and subscribed to, through the new hub only
+	if _, _, err := sub.storage.GetAttempts(context.Background(), topicURLTest, hubURLTest); err != sql.ErrNoRows {
+		t.Fatalf("Expected {%v} but got {%v}", sql.ErrNoRows, err)
+	}
+	cb, err := sub.storage.GetActiveCallback(context.Background(), topicURLTest, redirectDest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cb != handle.Callback {
+		t.Fatalf("Expected callback {%v} but got {%v}", handle.Callback, cb)
+	}
+
+	// Renewals go straight to the new hub
+	err = sub.renewSubscription(context.Background(), handle.Callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldHubRequests != 1 || newHubRequests != 2 {
+		t.Fatalf("Expected 1 request to the old hub and 2 to the new one, but got %d and %d", oldHubRequests, newHubRequests)
+	}
+}
+
+func TestSubscriber_renewalRedirect(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	// Subscriptions to an https hub share a secret with it
+	secureHub := "https://example.com/hub"
+	httpmock.RegisterResponder("POST", secureHub, httpmock.NewStringResponder(202, ""))
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {secureHub},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handle := newHandle(topicURLTest, secureHub, generateCallback())
+	err = sub.requestSubscription(context.Background(), topicURLTest, secureHub, handle.Callback, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, topicURLTest, handle.Callback, "subscribe")
+
+	redirectTo := func(location string) {
+		httpmock.RegisterResponder("POST", secureHub,
+			func(req *http.Request) (*http.Response, error) {
+				resp := httpmock.NewStringResponse(307, "")
+				resp.Header.Set("Location", location)
+				return resp, nil
+			})
+	}
+
+	t.Run("Renewals follow redirects with their secret", func(t *testing.T) {
+		movedHub := "https://example.com/moved_hub"
+		var secret string
+		httpmock.RegisterResponder("POST", movedHub,
+			func(req *http.Request) (*http.Response, error) {
+				req.ParseForm()
+				secret = req.PostForm.Get("hub.secret")
+				return httpmock.NewStringResponse(202, ""), nil
+			})
+		redirectTo(movedHub)
+
+		err := sub.renewSubscription(context.Background(), handle.Callback)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret == "" {
+			t.Fatal("Expected the renewal to reach the new hub with its secret")
+		}
+	})
+
+	t.Run("Renewals are not redirected to plain http hubs", func(t *testing.T) {
+		plainHub := "http://example.com/plain_hub"
+		plainRequests := 0
+		httpmock.RegisterResponder("POST", plainHub,
+			func(req *http.Request) (*http.Response, error) {
+				plainRequests++
+				return httpmock.NewStringResponse(202, ""), nil
+			})
+		redirectTo(plainHub)
+
+		err := sub.renewSubscription(context.Background(), handle.Callback)
+		if err != ErrInsecureRedirect {
+			t.Fatalf("Expected {%v} but got {%v}", ErrInsecureRedirect, err)
+		}
+		if plainRequests != 0 {
+			t.Fatalf("Expected no requests to the plain http hub, but got %d", plainRequests)
+		}
+	})
+}
+
+func TestSubscriber_redirectLimits(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redirectTo := func(hub, location string) {
+		httpmock.RegisterResponder("POST", hub,
+			func(req *http.Request) (*http.Response, error) {
+				resp := httpmock.NewStringResponse(307, "")
+				resp.Header.Set("Location", location)
+				return resp, nil
+			})
+	}
+
+	t.Run("Redirect loops are detected", func(t *testing.T) {
+		otherHub := "http://example.com/other_hub"
+		redirectTo(hubURLTest, otherHub)
+		redirectTo(otherHub, hubURLTest)
+
+		err := sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+		if err != ErrRedirectLoop {
+			t.Fatalf("Expected {%v} but got {%v}", ErrRedirectLoop, err)
+		}
+	})
+
+	t.Run("Redirect chains are bounded", func(t *testing.T) {
+		hub := hubURLTest
+		for hop := 0; hop <= maxRedirects; hop++ {
+			next := fmt.Sprintf("http://example.com/hub_%d", hop)
+			redirectTo(hub, next)
+			hub = next
+		}
+		setupDummyValidationAck(hub)
+
+		err := sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+		if err != ErrTooManyRedirects {
+			t.Fatalf("Expected {%v} but got {%v}", ErrTooManyRedirects, err)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package subscriber
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// rediscoverLoop rediscovers every offered topic once per interval, until the Subscriber shuts down
+func (sub *Subscriber) rediscoverLoop(ctx context.Context, interval time.Duration) {
+	defer close(sub.rediscoveryDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		topics, err := sub.storage.GetOfferedTopics(ctx)
+		if err != nil {
+			log.Printf("Failed to list topics for rediscovery: %v\n", err)
+			continue
+		}
+
+		for _, topic := range topics {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := sub.Rediscover(ctx, topic); err != nil {
+				log.Printf("Failed to rediscover topic {%v}: %v\n", topic, err)
+			}
+		}
+	}
+}
+
+// Rediscover runs discovery on the topic again, and reconciles the hubs that it advertises with the hubs in storage.
+// Hubs that the topic no longer advertises have their offers withdrawn, and our subscriptions with them ended.
+// Hubs that are newly advertised are indexed and, if we're subscribed to the topic, the advertised hubs we aren't
+// subscribed through are subscribed to, in the order that the topic lists them, until the HubPolicy chosen at
+// Subscribe time is met again. The new subscriptions ask for the same lease as the ones they join.
+// Every change is recorded in the topic's hub history.
+func (sub *Subscriber) Rediscover(ctx context.Context, topic string) error {
+	res, err := sub.discoveries.Discover(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	stored, err := sub.storage.GetOfferedHubs(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	offered := make(map[string]struct{}, len(stored))
+	for _, hub := range stored {
+		offered[hub] = struct{}{}
+	}
+
+	advertised := make(map[string]struct{}, len(res.Hubs))
+	var added []string
+	for _, hub := range res.Hubs {
+		advertised[hub.URL] = struct{}{}
+		if _, ok := offered[hub.URL]; !ok {
+			added = append(added, hub.URL)
+		}
+	}
+
+	// Remember which hubs we're subscribed through, and with which lease, before the removed ones are withdrawn
+	var removed []string
+	subscribed := make(map[string]struct{})
+	lease := time.Duration(-1)
+	for _, hub := range stored {
+		if _, ok := advertised[hub]; !ok {
+			removed = append(removed, hub)
+		}
+		callback, err := sub.storage.GetActiveCallback(ctx, topic, hub)
+		if err != nil {
+			continue
+		}
+		subscribed[hub] = struct{}{}
+		if s, err := sub.storage.GetSubscription(ctx, callback); err == nil && lease < 0 {
+			lease = time.Duration(s.RequestedLeaseSeconds) * time.Second
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	if err := sub.storage.ReconcileHubs(ctx, topic, added, removed); err != nil {
+		return err
+	}
+	log.Printf("Topic {%v} added hubs %v, and removed hubs %v\n", topic, added, removed)
+
+	for _, hub := range removed {
+		if _, ok := subscribed[hub]; !ok {
+			continue
+		}
+		if err := sub.Unsubscribe(ctx, topic, hub); err != nil {
+			log.Printf("Failed to unsubscribe from removed hub {%v} of topic {%v}: %v\n", hub, topic, err)
+		}
+	}
+
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	// Without the policy (say, we restarted since subscribing), keep subscribing through as many hubs as before
+	limit := len(subscribed)
+	if policy, ok := sub.hubPolicy(topic); ok {
+		limit = policy.limit(len(res.Hubs))
+	}
+
+	// The new subscriptions ask for the same lease as the ones they join
+	if lease <= 0 {
+		lease = sub.leaseDuration
+	}
+
+	// Hubs we're still subscribed through count towards the limit
+	var candidates []string
+	for _, hub := range res.Hubs {
+		if _, ok := subscribed[hub.URL]; ok {
+			limit--
+			continue
+		}
+		candidates = append(candidates, hub.URL)
+	}
+
+	for _, hub := range candidates {
+		if limit <= 0 {
+			break
+		}
+		if err := sub.requestSubscription(ctx, topic, hub, generateCallback(), lease); err != nil {
+			log.Printf("Failed to subscribe to hub {%v} of topic {%v}: %v\n", hub, topic, err)
+			continue
+		}
+		limit--
+	}
+
+	return nil
+}
+
+// hubPolicy returns the HubPolicy that the topic was last subscribed to with, if it was since we started
+func (sub *Subscriber) hubPolicy(topic string) (HubPolicy, bool) {
+	sub.policyMut.Lock()
+	defer sub.policyMut.Unlock()
+
+	policy, ok := sub.hubPolicies[topic]
+	return policy, ok
+}
@@ -0,0 +1,200 @@
+package subscriber
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+	"gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestSubscriber_Rediscover(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpmock.Activate()
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	// The topic moves from one hub to another, between discoveries
+	newHubURL := "http://example.com/new_hub"
+	advertised := hubURLTest
+	httpmock.RegisterResponder("GET", topicURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			resp := httpmock.NewStringResponse(200, "")
+			resp.Header.Set("Link", `<`+advertised+`>; rel="hub", <`+topicURLTest+`>; rel="self"`)
+			return resp, nil
+		})
+
+	modes := make(map[string]string)
+	for _, hub := range []string{hubURLTest, newHubURL} {
+		hub := hub
+		httpmock.RegisterResponder("POST", hub,
+			func(req *http.Request) (*http.Response, error) {
+				bdy, _ := ioutil.ReadAll(req.Body)
+				vals, _ := url.ParseQuery(string(bdy))
+				modes[hub] = vals.Get("hub.mode")
+				return httpmock.NewStringResponse(202, ""), nil
+			})
+	}
+
+	handles, err := sub.Subscribe(context.Background(), topicURLTest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, topicURLTest, handles[0].Callback, "subscribe")
+
+	// Nothing changed, so nothing happens
+	delete(modes, hubURLTest)
+	if err := sub.Rediscover(context.Background(), topicURLTest); err != nil {
+		t.Fatal(err)
+	}
+	if len(modes) != 0 {
+		t.Fatalf("Expected no requests to hubs, but got %v", modes)
+	}
+
+	// The old hub is unsubscribed from, and the new one subscribed to
+	advertised = newHubURL
+	if err := sub.Rediscover(context.Background(), topicURLTest); err != nil {
+		t.Fatal(err)
+	}
+	if modes[hubURLTest] != "unsubscribe" || modes[newHubURL] != "subscribe" {
+		t.Fatalf("Expected to unsubscribe from the old hub and subscribe to the new one, but got %v", modes)
+	}
+
+	hubs, err := sub.storage.GetOfferedHubs(context.Background(), topicURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hubs, []string{newHubURL}) {
+		t.Fatalf("Expected hubs [%v] but got %v", newHubURL, hubs)
+	}
+
+	changes, err := sub.storage.GetHubChanges(context.Background(), topicURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.HubChanges) != 2 ||
+		changes.HubChanges[0].Hub != newHubURL || changes.HubChanges[0].Kind != subscriberpb.HubChangeKind_HubAdded ||
+		changes.HubChanges[1].Hub != hubURLTest || changes.HubChanges[1].Kind != subscriberpb.HubChangeKind_HubRemoved {
+		t.Fatalf("Unexpected hub history %v", changes.HubChanges)
+	}
+
+	// The hub can still verify our unsubscription
+	verifyCallback(t, topicURLTest, handles[0].Callback, "unsubscribe")
+}
+
+/*
+	# Test Cases
+
+	1. A topic subscribed to through its first hub moves to two new hubs, and only the first of them is subscribed to
+	2. The new subscription asks for the lease that was requested at Subscribe time
+	3. A topic subscribed to through all of its hubs gains a hub, which is subscribed to as well
+*/
+func TestSubscriber_Rediscover_policy(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpmock.Activate()
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	firstTopic := topicURLTest
+	allTopic := topicURLTest + "/all"
+	hubA := "http://example.com/hub_a"
+	hubB := "http://example.com/hub_b"
+	hubC := "http://example.com/hub_c"
+
+	advertised := map[string][]string{
+		firstTopic: {hubA},
+		allTopic:   {hubA},
+	}
+	for topic := range advertised {
+		topic := topic
+		httpmock.RegisterResponder("GET", topic,
+			func(req *http.Request) (*http.Response, error) {
+				links := ""
+				for _, hub := range advertised[topic] {
+					links += `<` + hub + `>; rel="hub", `
+				}
+				resp := httpmock.NewStringResponse(200, "")
+				resp.Header.Set("Link", links+`<`+topic+`>; rel="self"`)
+				return resp, nil
+			})
+	}
+
+	// Subscription requests, by topic and hub
+	requests := make(map[string]url.Values)
+	for _, hub := range []string{hubA, hubB, hubC} {
+		hub := hub
+		httpmock.RegisterResponder("POST", hub,
+			func(req *http.Request) (*http.Response, error) {
+				bdy, _ := ioutil.ReadAll(req.Body)
+				vals, _ := url.ParseQuery(string(bdy))
+				if vals.Get("hub.mode") == "subscribe" {
+					requests[vals.Get("hub.topic")+" "+hub] = vals
+				}
+				return httpmock.NewStringResponse(202, ""), nil
+			})
+	}
+
+	handles, err := sub.Subscribe(context.Background(), firstTopic, &SubscribeOptions{LeaseDuration: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, firstTopic, handles[0].Callback, "subscribe")
+
+	handles, err = sub.Subscribe(context.Background(), allTopic, &SubscribeOptions{Hubs: AllHubs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, allTopic, handles[0].Callback, "subscribe")
+
+	// Case 1
+	requests = make(map[string]url.Values)
+	advertised[firstTopic] = []string{hubB, hubC}
+	if err := sub.Rediscover(context.Background(), firstTopic); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := requests[firstTopic+" "+hubB]; !ok || len(requests) != 1 {
+		t.Fatalf("Expected to only subscribe to {%v}, but subscribed to %v", hubB, requests)
+	}
+
+	// Case 2
+	if lease := requests[firstTopic+" "+hubB].Get("hub.lease_seconds"); lease != "3600" {
+		t.Fatalf("Expected the requested lease of {3600} seconds, but got {%v}", lease)
+	}
+
+	// Case 3
+	requests = make(map[string]url.Values)
+	advertised[allTopic] = []string{hubA, hubB}
+	if err := sub.Rediscover(context.Background(), allTopic); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := requests[allTopic+" "+hubB]; !ok || len(requests) != 1 {
+		t.Fatalf("Expected to subscribe to {%v}, but subscribed to %v", hubB, requests)
+	}
+}
@@ -0,0 +1,87 @@
+package subscriber
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// resumePageSize is the number of subscriptions read from storage at a time, while resuming
+const resumePageSize = 100
+
+// resume picks up the subscriptions left in storage by a previous run of the Subscriber.
+// Active subscriptions have their renewal re-armed, on the schedule of the lease that their hub granted.
+// Subscriptions whose lease expired while we were down are set aside, to be renewed with a fresh subscription
+// request once the callback server is up (see resumeExpired).
+func (sub *Subscriber) resume() error {
+	lastTopic, lastHub := "", ""
+	for {
+		subs, lastPage, err := sub.storage.GetActive(context.Background(), resumePageSize, lastTopic, lastHub)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range subs.Subscriptions {
+			expiration := time.Unix(s.LeaseExpiration, 0)
+			if s.GrantedLeaseSeconds > 0 {
+				sub.renewals.scheduleLease(s.Callback, expiration, time.Duration(s.GrantedLeaseSeconds)*time.Second)
+			} else {
+				sub.renewals.schedule(s.Callback, expiration)
+			}
+			lastTopic, lastHub = s.Topic, s.Hub
+		}
+
+		if lastPage {
+			break
+		}
+	}
+
+	// Leases that were invalidated have a reason, and offers that were never subscribed to have no callback.
+	// Everything else that is inactive simply ran out while no one was around to renew it.
+	var expired []*subscriberpb.Subscription
+	lastTopic, lastHub = "", ""
+	for {
+		subs, lastPage, err := sub.storage.GetInactive(context.Background(), resumePageSize, lastTopic, lastHub)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range subs.Subscriptions {
+			if s.Callback != "" && s.LeaseExpiration != 0 && s.InactiveReason == "" {
+				expired = append(expired, s)
+			}
+			lastTopic, lastHub = s.Topic, s.Hub
+		}
+
+		if lastPage {
+			break
+		}
+	}
+
+	sub.expired = expired
+	return nil
+}
+
+// resumeExpired re-subscribes, in the background, to the subscriptions that resume found expired.
+// It is called once the callback server is listening, so that hubs which verify right away can reach it.
+func (sub *Subscriber) resumeExpired() {
+	expired := sub.expired
+	sub.expired = nil
+
+	go func() {
+		for _, s := range expired {
+			// Ask for the same lease as the expired subscription did
+			lease := sub.leaseDuration
+			if s.RequestedLeaseSeconds != 0 {
+				lease = time.Duration(s.RequestedLeaseSeconds) * time.Second
+			}
+
+			err := sub.requestSubscription(context.Background(), s.Topic, s.Hub, generateCallback(), lease)
+			if err != nil {
+				log.Printf("Failed to resume expired subscription to topic {%v} at hub {%v}: %v\n", s.Topic, s.Hub, err)
+			}
+		}
+	}()
+}
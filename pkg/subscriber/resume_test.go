@@ -0,0 +1,217 @@
+package subscriber
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage/memory"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+/*
+	Test Cases
+
+	1. Active subscriptions have their renewals re-armed when the Subscriber is reborn
+	2. Subscriptions that expired while the Subscriber was down are re-subscribed once it is reborn and running
+	3. Resumed renewals are due on the schedule of the lease that the hub granted, not of what is left of it
+*/
+
+// survivingBackend is an in-memory storage backend that outlives the Subscribers it is handed to,
+// like a database would
+type survivingBackend struct {
+	*memory.Memory
+}
+
+func (b survivingBackend) Shutdown() error {
+	return nil
+}
+
+// persistedConfig returns a Subscriber config whose storage survives the Subscriber's shutdown
+func persistedConfig() *Config {
+	cfg := NewConfig()
+	cfg.Backend = survivingBackend{memory.New()}
+	return cfg
+}
+
+// subscribeAndDie runs a Subscriber with the given config, subscribes it to the test topic at the given hub,
+// and shuts it down. It returns the callback that the hub verified.
+func subscribeAndDie(t *testing.T, cfg *Config, hub string) string {
+	sub, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+
+	runSubscriber(t, sub)
+
+	var callback string
+	httpmock.RegisterResponder("POST", hub,
+		func(req *http.Request) (*http.Response, error) {
+			bdy, _ := ioutil.ReadAll(req.Body)
+			vals, _ := url.ParseQuery(string(bdy))
+			callback = vals.Get("hub.callback")
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hub},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sub.initiateSubscription(context.Background(), topicURLTest, hub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, topicURLTest, callback, "subscribe")
+
+	if err := sub.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	return callback
+}
+
+func TestSubscriber_resumeActive(t *testing.T) {
+	cfg := persistedConfig()
+
+	callback := subscribeAndDie(t, cfg, hubURLTest)
+
+	sub, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	_, err = sub.storage.GetActiveCallback(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !sub.renewals.scheduled(callback) {
+		t.Fatalf("Renewal of callback {%v} was not resumed", callback)
+	}
+}
+
+func TestSubscriber_resumeExpired(t *testing.T) {
+	cfg := persistedConfig()
+
+	// Resubscription begins as soon as the reborn Subscriber runs, so the hub must be reachable for real
+	resubscribed := make(chan url.Values, 1)
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		resubscribed <- req.PostForm
+		w.WriteHeader(202)
+	}))
+	defer hub.Close()
+
+	oldCallback := subscribeAndDie(t, cfg, hub.URL)
+
+	// Stay dead for longer than the lease that verifyCallback grants
+	time.Sleep(3500 * time.Millisecond)
+
+	sub, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Nothing is re-subscribed until the callbacks can be verified
+	select {
+	case <-resubscribed:
+		t.Fatal("Expired subscription was resubscribed before the callback server was running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	runSubscriber(t, sub)
+
+	select {
+	case vals := <-resubscribed:
+		if vals.Get("hub.mode") != "subscribe" {
+			t.Fatalf("Bad mode %v instead of %v", vals.Get("hub.mode"), "subscribe")
+		}
+		if vals.Get("hub.topic") != topicURLTest {
+			t.Fatalf("Bad topic %v instead of %v", vals.Get("hub.topic"), topicURLTest)
+		}
+		if vals.Get("hub.callback") == oldCallback {
+			t.Fatalf("Resubscribed with the expired callback {%v}", oldCallback)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expired subscription was never resubscribed")
+	}
+}
+
+func TestSubscriber_resumeGrantedLease(t *testing.T) {
+	cfg := persistedConfig()
+
+	// Renewals go out as soon as they are due, so the hub must be reachable for real
+	renewed := make(chan url.Values, 1)
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		select {
+		case renewed <- req.PostForm:
+		default:
+		}
+		w.WriteHeader(202)
+	}))
+	defer hub.Close()
+
+	callback := subscribeAndDie(t, cfg, hub.URL)
+
+	// Stay dead for longer than half of the lease that verifyCallback grants, but not for all of it
+	time.Sleep(1600 * time.Millisecond)
+
+	sub, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	subs, _, err := sub.storage.GetActive(context.Background(), resumePageSize, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs.Subscriptions) != 1 {
+		t.Fatalf("Expected 1 active subscription, but there are %d", len(subs.Subscriptions))
+	}
+	if granted := subs.Subscriptions[0].GrantedLeaseSeconds; granted != 3 {
+		t.Fatalf("Granted lease is {%v} instead of {3}", granted)
+	}
+
+	// The whole lease is past its renewal window, while what is left of it is not
+	sub.renewals.mut.Lock()
+	r, queued := sub.renewals.queued[callback]
+	if queued && r.at.After(time.Now()) {
+		sub.renewals.mut.Unlock()
+		t.Fatalf("Expected the renewal to be due right away, but it is due in %v", time.Until(r.at))
+	}
+	sub.renewals.mut.Unlock()
+
+	select {
+	case vals := <-renewed:
+		if vals.Get("hub.callback") != callback {
+			t.Fatalf("Renewed callback {%v} instead of {%v}", vals.Get("hub.callback"), callback)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Resumed subscription was never renewed")
+	}
+}
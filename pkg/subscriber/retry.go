@@ -0,0 +1,131 @@
+package subscriber
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes how requests to hubs (subscriptions, renewals, and unsubscriptions) are retried.
+// Network errors, 5xx responses, and 429 responses are retried with an exponential backoff;
+// any other response or error is final.
+type RetryPolicy struct {
+	MaxAttempts int           // the number of tries a request gets, including the first one
+	BaseBackoff time.Duration // the wait after the first failed try, which doubles with every try after it
+	MaxBackoff  time.Duration // the longest wait between two tries
+	Jitter      float64       // the fraction of each wait that is randomized, between 0 and 1
+}
+
+// NewRetryPolicy returns the default RetryPolicy
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 5,
+		BaseBackoff: time.Second,
+		MaxBackoff:  time.Minute,
+		Jitter:      0.2,
+	}
+}
+
+// backoff returns the time to wait after the given (1-indexed) failed attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.BaseBackoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+
+	if jitter := int64(float64(wait) * p.Jitter); jitter > 0 {
+		wait += time.Duration(rand.Int63n(2*jitter) - jitter)
+	}
+	return wait
+}
+
+// ErrHubStatus is returned when a hub answers a request with an unexpected status code
+type ErrHubStatus struct {
+	StatusCode int
+	RetryAfter time.Duration // how long the hub asked us to wait before trying again, if it did
+}
+
+func (e ErrHubStatus) Error() string {
+	return fmt.Sprintf("Subscriber: hub responded with unexpected status code %d", e.StatusCode)
+}
+
+// newErrHubStatus builds the error for an unexpected response, honoring its Retry-After header on 429s and 503s
+func newErrHubStatus(resp *http.Response) ErrHubStatus {
+	err := ErrHubStatus{StatusCode: resp.StatusCode}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return err
+	}
+
+	retryAfter := resp.Header.Get("Retry-After")
+	if seconds, parseErr := strconv.Atoi(retryAfter); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	} else if date, parseErr := http.ParseTime(retryAfter); parseErr == nil {
+		err.RetryAfter = time.Until(date)
+	}
+	return err
+}
+
+// retryable reports whether a request that failed with the given error is worth trying again.
+// Only failures on the way to the hub, and responses that ask us to come back later, are retried;
+// anything else (say, a redirect loop, or a failure to record the outcome in storage) would just fail again.
+func retryable(err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+
+	switch e := err.(type) {
+	case ErrHubStatus:
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+	case *url.Error, net.Error:
+		return true
+	}
+	return false
+}
+
+// withRetries makes a request to the hub about the topic, retrying it according to the Subscriber's RetryPolicy.
+// Each try is given the hub to send the request to, and a fresh chain to follow its redirects with.
+// Tries after a permanent redirect go straight to the new hub.
+// The outcome of every try is recorded in storage, against the topic <-> hub offer.
+func (sub *Subscriber) withRetries(ctx context.Context, topic, hub string, request func(hub string, chain *redirectChain) error) error {
+	for attempt := 1; ; attempt++ {
+		chain := newRedirectChain(hub)
+		err := request(hub, chain)
+		hub = chain.stored
+
+		lastError := ""
+		if err != nil {
+			lastError = err.Error()
+		}
+		if recErr := sub.storage.RecordAttempt(ctx, topic, hub, attempt, lastError); recErr != nil {
+			log.Printf("Failed to record attempt %d at topic {%v} with hub {%v}: %v\n", attempt, topic, hub, recErr)
+		}
+
+		if err == nil || attempt >= sub.retryPolicy.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		// Hubs that tell us when to come back are taken at their word, if that is later than we'd have come back anyway
+		wait := sub.retryPolicy.backoff(attempt)
+		if statusErr, ok := err.(ErrHubStatus); ok && statusErr.RetryAfter > wait {
+			wait = statusErr.RetryAfter
+		}
+		log.Printf("Attempt %d at topic {%v} with hub {%v} failed, retrying in %v: %v\n", attempt, topic, hub, wait, err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts: 10,
+		BaseBackoff: time.Second,
+		MaxBackoff:  10 * time.Second,
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for idx, exp := range expected {
+		if wait := policy.backoff(idx + 1); wait != exp {
+			t.Fatalf("Expected backoff {%v} after attempt %d, but got {%v}", exp, idx+1, wait)
+		}
+	}
+
+	// Jittered waits stay within the jitter's fraction of the backoff
+	policy.Jitter = 0.5
+	for attempt := 1; attempt < 100; attempt++ {
+		if wait := policy.backoff(2); wait < time.Second || wait > 3*time.Second {
+			t.Fatalf("Expected jittered backoff within {1s, 3s}, but got {%v}", wait)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"Transport error", &url.Error{Op: "Post", URL: hubURLTest, Err: errors.New("connection refused")}, true},
+		{"Server error", ErrHubStatus{StatusCode: 503}, true},
+		{"Too many requests", ErrHubStatus{StatusCode: 429}, true},
+		{"Client error", ErrHubStatus{StatusCode: 400}, false},
+		{"Cancelled", context.Canceled, false},
+		{"Redirect loop", ErrRedirectLoop, false},
+		{"Storage error", errors.New("SQL storage: topic is malformed"), false},
+	}
+
+	for _, test := range tests {
+		if retryable(test.err) != test.retryable {
+			t.Fatalf("%s: expected retryable to be %v", test.name, test.retryable)
+		}
+	}
+}
+
+func TestSubscriber_requestSubscription_retries(t *testing.T) {
+	cfg := NewConfig()
+	cfg.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 3,
+		BaseBackoff: 10 * time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}
+	sub, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Transient failures are retried", func(t *testing.T) {
+		// The hub is busy, then broken, then back on its feet
+		responses := []int{503, 500, 202}
+		tries := 0
+		httpmock.RegisterResponder("POST", hubURLTest,
+			func(req *http.Request) (*http.Response, error) {
+				resp := httpmock.NewStringResponse(responses[tries], "")
+				if responses[tries] == 503 {
+					resp.Header.Set("Retry-After", "1")
+				}
+				tries++
+				return resp, nil
+			})
+
+		start := time.Now()
+		err := sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tries != 3 {
+			t.Fatalf("Expected 3 tries, but got %d", tries)
+		}
+		if elapsed := time.Since(start); elapsed < time.Second {
+			t.Fatalf("Expected the hub's Retry-After to be honored, but retried after {%v}", elapsed)
+		}
+
+		attempts, lastError, err := sub.storage.GetAttempts(context.Background(), topicURLTest, hubURLTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != 3 || lastError != "" {
+			t.Fatalf("Expected 3 attempts without an error, but got {%d} attempts with error {%s}", attempts, lastError)
+		}
+	})
+
+	t.Run("Client errors are not retried", func(t *testing.T) {
+		tries := 0
+		httpmock.RegisterResponder("POST", hubURLTest,
+			func(req *http.Request) (*http.Response, error) {
+				tries++
+				return httpmock.NewStringResponse(400, ""), nil
+			})
+
+		err := sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+		if statusErr, ok := err.(ErrHubStatus); !ok || statusErr.StatusCode != 400 {
+			t.Fatalf("Expected a 400 ErrHubStatus, but got {%v}", err)
+		}
+		if tries != 1 {
+			t.Fatalf("Expected 1 try, but got %d", tries)
+		}
+
+		attempts, lastError, err := sub.storage.GetAttempts(context.Background(), topicURLTest, hubURLTest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != 1 || lastError == "" {
+			t.Fatalf("Expected 1 attempt with an error, but got {%d} attempts with error {%s}", attempts, lastError)
+		}
+	})
+
+	t.Run("Attempts are bounded", func(t *testing.T) {
+		tries := 0
+		httpmock.RegisterResponder("POST", hubURLTest,
+			func(req *http.Request) (*http.Response, error) {
+				tries++
+				return httpmock.NewStringResponse(502, ""), nil
+			})
+
+		err := sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+		if statusErr, ok := err.(ErrHubStatus); !ok || statusErr.StatusCode != 502 {
+			t.Fatalf("Expected a 502 ErrHubStatus, but got {%v}", err)
+		}
+		if tries != 3 {
+			t.Fatalf("Expected 3 tries, but got %d", tries)
+		}
+	})
+}
@@ -0,0 +1,245 @@
+package subscriber
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// minRenewalRetry is the least amount of lease that must be left, for a failed renewal to be tried again
+const minRenewalRetry = time.Second
+
+// renewFunc renews the subscription of a callback.
+// The context it is given expires along with the lease being renewed.
+type renewFunc func(ctx context.Context, callback string)
+
+// renewal is a single renewal waiting in the scheduler's queue
+type renewal struct {
+	callback   string
+	at         time.Time // when the renewal request should be sent
+	expiration time.Time // when the lease being renewed runs out
+	index      int       // position in the heap
+}
+
+// flight is a renewal that has been dispatched, and can still be aborted
+type flight struct {
+	cancel context.CancelFunc
+}
+
+// renewalQueue is a min-heap of renewals, ordered by the time that they are due
+type renewalQueue []*renewal
+
+func (q renewalQueue) Len() int           { return len(q) }
+func (q renewalQueue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+
+func (q renewalQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *renewalQueue) Push(x interface{}) {
+	r := x.(*renewal)
+	r.index = len(*q)
+	*q = append(*q, r)
+}
+
+func (q *renewalQueue) Pop() interface{} {
+	old := *q
+	r := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return r
+}
+
+// renewalScheduler renews every sticky subscription from a single routine, instead of one timer per subscription.
+// Renewals are due somewhere between a third and a half of the way through the remaining lease (the jitter keeps
+// subscriptions that were made together from being renewed together), and at most maxInFlight renewal requests
+// are sent at once.
+type renewalScheduler struct {
+	renew renewFunc
+
+	mut      sync.Mutex
+	queue    renewalQueue
+	queued   map[string]*renewal // renewals waiting to be due, by callback
+	inFlight map[string]*flight  // renewals being sent, by callback
+
+	slots   chan struct{}  // bounds the number of renewals in flight
+	wake    chan struct{}  // nudges the scheduling routine when the head of the queue changes
+	flights sync.WaitGroup // tracks the routines of dispatched renewals
+
+	ctx  context.Context
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// newRenewalScheduler creates a scheduler, and launches its scheduling routine
+func newRenewalScheduler(maxInFlight int, renew renewFunc) *renewalScheduler {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	s := &renewalScheduler{
+		renew:    renew,
+		queued:   make(map[string]*renewal),
+		inFlight: make(map[string]*flight),
+		slots:    make(chan struct{}, maxInFlight),
+		wake:     make(chan struct{}, 1),
+		ctx:      ctx,
+		stop:     stop,
+		done:     make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+// schedule (re)schedules the renewal of the given callback, whose lease runs out at the given expiration.
+// Any renewal already queued for the callback is replaced.
+func (s *renewalScheduler) schedule(callback string, expiration time.Time) {
+	s.scheduleLease(callback, expiration, time.Until(expiration))
+}
+
+// scheduleLease (re)schedules the renewal of the given callback, whose lease of the given length runs out at the
+// given expiration. The renewal is due a third to a half of the way through the whole lease, which is right away
+// if the lease has been running for longer than that.
+func (s *renewalScheduler) scheduleLease(callback string, expiration time.Time, lease time.Duration) {
+	if lease < 0 {
+		lease = 0
+	}
+
+	at := expiration.Add(-lease).Add(lease / 3)
+	if jitter := int64(lease / 6); jitter > 0 {
+		at = at.Add(time.Duration(rand.Int63n(jitter)))
+	}
+
+	s.mut.Lock()
+	if r, ok := s.queued[callback]; ok {
+		r.at, r.expiration = at, expiration
+		heap.Fix(&s.queue, r.index)
+	} else {
+		r := &renewal{callback: callback, at: at, expiration: expiration}
+		heap.Push(&s.queue, r)
+		s.queued[callback] = r
+	}
+	s.mut.Unlock()
+
+	s.nudge()
+}
+
+// cancel forgets about the renewal of the given callback, aborting it if it is in flight
+func (s *renewalScheduler) cancel(callback string) {
+	s.mut.Lock()
+	if r, ok := s.queued[callback]; ok {
+		heap.Remove(&s.queue, r.index)
+		delete(s.queued, callback)
+	}
+	if f, ok := s.inFlight[callback]; ok {
+		f.cancel()
+		delete(s.inFlight, callback)
+	}
+	s.mut.Unlock()
+
+	s.nudge()
+}
+
+// scheduled reports whether the given callback has a renewal queued or in flight
+func (s *renewalScheduler) scheduled(callback string) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	_, queued := s.queued[callback]
+	_, inFlight := s.inFlight[callback]
+	return queued || inFlight
+}
+
+// shutdown stops the scheduling routine, and aborts every renewal in flight (waiting for them to wrap up)
+func (s *renewalScheduler) shutdown() {
+	s.stop()
+	<-s.done
+	s.flights.Wait()
+}
+
+func (s *renewalScheduler) nudge() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run dispatches renewals as they come due, until the scheduler is shut down
+func (s *renewalScheduler) run() {
+	defer close(s.done)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mut.Lock()
+		now := time.Now()
+		for len(s.queue) > 0 && !s.queue[0].at.After(now) {
+			r := heap.Pop(&s.queue).(*renewal)
+			delete(s.queued, r.callback)
+			s.dispatch(r)
+		}
+
+		wait := time.Hour
+		if len(s.queue) > 0 {
+			wait = s.queue[0].at.Sub(now)
+		}
+		s.mut.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// dispatch sends the renewal in the background, once a slot frees up.
+// It must be called with the lock held.
+func (s *renewalScheduler) dispatch(r *renewal) {
+	ctx, cancel := context.WithDeadline(s.ctx, r.expiration)
+	f := &flight{cancel: cancel}
+	s.inFlight[r.callback] = f
+
+	s.flights.Add(1)
+	go func() {
+		defer s.flights.Done()
+		defer s.finish(r.callback, f)
+
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-s.slots }()
+
+		s.renew(ctx, r.callback)
+	}()
+}
+
+// finish clears the in-flight record of a renewal, unless it has been replaced by a newer one
+func (s *renewalScheduler) finish(callback string, f *flight) {
+	f.cancel()
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.inFlight[callback] == f {
+		delete(s.inFlight, callback)
+	}
+}
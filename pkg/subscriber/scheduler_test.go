@@ -0,0 +1,107 @@
+package subscriber
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+	Test Cases
+
+	1. Every scheduled renewal is sent, before its lease expires
+	2. No more than the maximum number of renewals are in flight at once
+	3. Cancelled renewals are never sent
+	4. Rescheduling a callback replaces its queued renewal
+	5. Renewals of a lease that has been running for a while are due a third to a half of the way through the whole lease
+*/
+
+func TestRenewalScheduler(t *testing.T) {
+	const maxInFlight = 3
+
+	var mut sync.Mutex
+	inFlight, peak := 0, 0
+	renewed := make(map[string]int)
+
+	s := newRenewalScheduler(maxInFlight, func(ctx context.Context, callback string) {
+		mut.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mut.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mut.Lock()
+		inFlight--
+		if ctx.Err() == nil {
+			renewed[callback]++
+		}
+		mut.Unlock()
+	})
+	defer s.shutdown()
+
+	expiration := time.Now().Add(600 * time.Millisecond)
+	for idx := 0; idx < 20; idx++ {
+		s.schedule(fmt.Sprintf("cb_num%d", idx), expiration)
+	}
+
+	// 3. Cancelled renewals are never sent
+	s.cancel("cb_num0")
+	if s.scheduled("cb_num0") {
+		t.Fatal("Cancelled renewal is still scheduled")
+	}
+
+	// 4. Rescheduling a callback replaces its queued renewal
+	s.schedule("cb_num1", time.Now().Add(time.Hour))
+
+	time.Sleep(time.Until(expiration))
+
+	mut.Lock()
+	defer mut.Unlock()
+
+	// 1. Every scheduled renewal is sent, before its lease expires
+	for idx := 2; idx < 20; idx++ {
+		if cb := fmt.Sprintf("cb_num%d", idx); renewed[cb] != 1 {
+			t.Fatalf("Expected callback {%v} to be renewed once, but it was renewed %d times", cb, renewed[cb])
+		}
+	}
+	if renewed["cb_num0"] != 0 {
+		t.Fatal("Cancelled renewal was sent")
+	}
+	if renewed["cb_num1"] != 0 || !s.scheduled("cb_num1") {
+		t.Fatal("Rescheduled renewal was sent at its old time")
+	}
+
+	// 2. No more than the maximum number of renewals are in flight at once
+	if peak > maxInFlight {
+		t.Fatalf("Expected at most %d renewals in flight, but saw %d", maxInFlight, peak)
+	}
+}
+
+func TestRenewalScheduler_scheduleLease(t *testing.T) {
+	s := newRenewalScheduler(1, func(ctx context.Context, callback string) {})
+	defer s.shutdown()
+
+	// 5. Renewals of a lease that has been running for a while are due a third to a half of the way through the whole lease
+	now := time.Now()
+	s.scheduleLease("fresh", now.Add(59*time.Minute), time.Hour)
+	s.scheduleLease("stale", now.Add(40*time.Minute), 2*time.Hour)
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	fresh := s.queued["fresh"]
+	if fresh == nil {
+		t.Fatal("Expected the renewal of the fresh lease to be queued")
+	}
+	if due := fresh.at.Sub(now); due < 19*time.Minute || due > 29*time.Minute {
+		t.Fatalf("Expected the fresh lease to be renewed 19 to 29 minutes from now, but it is due in %v", due)
+	}
+	if stale, queued := s.queued["stale"]; queued && stale.at.After(now) {
+		t.Fatalf("Expected the stale lease to be renewed right away, but it is due in %v", stale.at.Sub(now))
+	}
+}
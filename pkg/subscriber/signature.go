@@ -0,0 +1,11 @@
+package subscriber
+
+import "github.com/adamsanghera/go-websub/pkg/signature"
+
+var (
+	// ErrMissingSignature is returned when content arrives without a signature, for a subscription that has a secret
+	ErrMissingSignature = signature.ErrMissingSignature
+
+	// ErrSignatureMismatch is returned when the signature of a content delivery does not match its body
+	ErrSignatureMismatch = signature.ErrSignatureMismatch
+)
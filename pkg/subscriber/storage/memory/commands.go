@@ -0,0 +1,264 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// IndexOffer indexes the topic <-> hub relationships, which are observed in the discovery phase.
+// Each topic may be offered by any number of hubs. Offers that were withdrawn are offered once again.
+func (mem *Memory) IndexOffer(ctx context.Context, topicsToHubs map[string][]string) error {
+	// Validate everything up front, so that a bad offer leaves the rest unindexed
+	for topic, hubs := range topicsToHubs {
+		if topic == "" {
+			return ErrMalformedTopic
+		}
+		for _, hub := range hubs {
+			if hub == "" {
+				return ErrMalformedHub
+			}
+		}
+	}
+
+	if err := mem.lock(ctx); err != nil {
+		return err
+	}
+	defer mem.mut.Unlock()
+
+	for topic, hubs := range topicsToHubs {
+		for _, hub := range hubs {
+			mem.index(offerKey{topic, hub})
+		}
+	}
+	return nil
+}
+
+// index adds the offer if it's new, or offers it once again if it was withdrawn
+func (mem *Memory) index(key offerKey) {
+	if off, ok := mem.offers[key]; ok {
+		off.withdrawnAt = time.Time{}
+		return
+	}
+	mem.offers[key] = &offer{}
+}
+
+// NewCallback implies that the client is waiting for reply to a sub request on the given callback.
+// The secret is the hub.secret sent along with the request, or empty if none was sent.
+// The requestedLease is the hub.lease_seconds sent along with the request, or zero if none was sent.
+// Any earlier subscription with the same topic and hub is replaced.
+func (mem *Memory) NewCallback(ctx context.Context, topic, hub, callback, secret string, requestedLease time.Duration) error {
+	if topic == "" {
+		return ErrMalformedTopic
+	}
+	if hub == "" {
+		return ErrMalformedHub
+	}
+
+	if err := mem.lock(ctx); err != nil {
+		return err
+	}
+	defer mem.mut.Unlock()
+
+	key := offerKey{topic, hub}
+	if _, ok := mem.offers[key]; !ok {
+		return ErrNotOffered
+	}
+	if _, ok := mem.subscriptions[callback]; ok {
+		return ErrDuplicateCallback
+	}
+
+	if old, ok := mem.callbacks[key]; ok {
+		delete(mem.subscriptions, old)
+	}
+
+	var requestedSeconds int64
+	if requestedLease > 0 {
+		requestedSeconds = int64(requestedLease / time.Second)
+	}
+
+	mem.callbacks[key] = callback
+	mem.subscriptions[callback] = &subscription{
+		topic:    topic,
+		hub:      hub,
+		callback: callback,
+		secret:   secret,
+
+		requestedLeaseSeconds: requestedSeconds,
+	}
+	return nil
+}
+
+// Invalidate is called when a hub no longer views the subscription as active.
+// Invalidating is an idempotent action, it is OK to do it more than once.
+// Repeated invalidations will return a handleable error, ErrUpdateFailed.
+func (mem *Memory) Invalidate(ctx context.Context, callback, inactiveReason string) error {
+	if inactiveReason == "" {
+		return ErrMalformedInactiveReason
+	}
+
+	if err := mem.lock(ctx); err != nil {
+		return err
+	}
+	defer mem.mut.Unlock()
+
+	now := time.Now()
+	sub, ok := mem.subscriptions[callback]
+	if !ok || !sub.live(now) {
+		return ErrUpdateFailed{0}
+	}
+
+	sub.leaseInitiated = time.Time{}
+	sub.leaseExpiration = now
+	sub.inactiveReason = inactiveReason
+	return nil
+}
+
+// ExtendLease provides a subscription lease to a given callback.  Implicitly, this means that the subscription is active.
+// This occurs when a subscription is first ACK'd, and also upon subsequent lease renewals.
+// The time remaining until newExpiration is recorded as the lease that the hub granted.
+func (mem *Memory) ExtendLease(ctx context.Context, callback string, newExpiration time.Time) error {
+	now := time.Now()
+	if newExpiration.Before(now) {
+		return ErrNewLeaseInPast{newExpiration}
+	}
+
+	if err := mem.lock(ctx); err != nil {
+		return err
+	}
+	defer mem.mut.Unlock()
+
+	sub, ok := mem.subscriptions[callback]
+	if !ok || !sub.live(now) {
+		return ErrUpdateFailed{0}
+	}
+
+	sub.leaseExpiration = newExpiration
+	sub.grantedLeaseSeconds = int64(newExpiration.Sub(now).Round(time.Second) / time.Second)
+	if sub.leaseInitiated.IsZero() {
+		sub.leaseInitiated = now
+	}
+	return nil
+}
+
+// MigrateHub moves the topic's offer, and any subscription made through it, from the old hub to the new one.
+// This occurs when the old hub permanently redirects a request about the topic.
+// If the topic was already offered by the new hub, the old hub's subscription replaces the new hub's.
+func (mem *Memory) MigrateHub(ctx context.Context, topic, oldHub, newHub string) error {
+	if newHub == "" {
+		return ErrMalformedHub
+	}
+
+	if err := mem.lock(ctx); err != nil {
+		return err
+	}
+	defer mem.mut.Unlock()
+
+	oldKey, newKey := offerKey{topic, oldHub}, offerKey{topic, newHub}
+	off, ok := mem.offers[oldKey]
+	if !ok {
+		return ErrUpdateFailed{0}
+	}
+	if oldKey == newKey {
+		return nil
+	}
+
+	if _, ok := mem.offers[newKey]; !ok {
+		mem.offers[newKey] = &offer{
+			attempts:    off.attempts,
+			lastError:   off.lastError,
+			lastAttempt: off.lastAttempt,
+		}
+	}
+
+	if callback, ok := mem.callbacks[oldKey]; ok {
+		if replaced, ok := mem.callbacks[newKey]; ok {
+			delete(mem.subscriptions, replaced)
+		}
+		mem.subscriptions[callback].hub = newHub
+		mem.callbacks[newKey] = callback
+		delete(mem.callbacks, oldKey)
+	}
+
+	delete(mem.offers, oldKey)
+	return nil
+}
+
+// RecordAttempt records the outcome of the latest request made to the hub about the topic.
+// The attempt is the number of consecutive tries that the request has taken so far,
+// and lastError is empty if the latest try succeeded.
+func (mem *Memory) RecordAttempt(ctx context.Context, topic, hub string, attempt int, lastError string) error {
+	if err := mem.lock(ctx); err != nil {
+		return err
+	}
+	defer mem.mut.Unlock()
+
+	off, ok := mem.offers[offerKey{topic, hub}]
+	if !ok {
+		return ErrUpdateFailed{0}
+	}
+
+	off.attempts = attempt
+	off.lastError = lastError
+	off.lastAttempt = time.Now()
+	return nil
+}
+
+// ReconcileHubs brings the hubs that offer the topic in line with a fresh discovery of it, and records the change.
+// Added hubs are indexed as offers (or offered once again, if they were withdrawn), and removed hubs have their
+// offers withdrawn. Withdrawn offers keep their subscriptions, so that the hubs can still verify our unsubscriptions.
+// Like the sqlite3 implementation, either every change is made, or none is.
+func (mem *Memory) ReconcileHubs(ctx context.Context, topic string, added, removed []string) error {
+	if topic == "" {
+		return ErrMalformedTopic
+	}
+	for _, hub := range added {
+		if hub == "" {
+			return ErrMalformedHub
+		}
+	}
+
+	if err := mem.lock(ctx); err != nil {
+		return err
+	}
+	defer mem.mut.Unlock()
+
+	// Every removed hub must offer the topic (once the added hubs have been indexed), and may only be removed once
+	standing := make(map[string]bool, len(added))
+	for _, hub := range added {
+		standing[hub] = true
+	}
+	for _, hub := range removed {
+		off, ok := mem.offers[offerKey{topic, hub}]
+		offered, seen := standing[hub]
+		if !seen {
+			offered = ok && off.withdrawnAt.IsZero()
+		}
+		if !offered {
+			return ErrUpdateFailed{0}
+		}
+		standing[hub] = false
+	}
+
+	now := time.Now()
+	for _, hub := range added {
+		mem.index(offerKey{topic, hub})
+		mem.recordHubChange(topic, hub, subscriberpb.HubChangeKind_HubAdded, now)
+	}
+	for _, hub := range removed {
+		mem.offers[offerKey{topic, hub}].withdrawnAt = now
+		mem.recordHubChange(topic, hub, subscriberpb.HubChangeKind_HubRemoved, now)
+	}
+	return nil
+}
+
+// recordHubChange adds a change to the topic's hub history
+func (mem *Memory) recordHubChange(topic, hub string, kind subscriberpb.HubChangeKind, at time.Time) {
+	mem.changes[topic] = append(mem.changes[topic], &subscriberpb.HubChange{
+		Topic:     topic,
+		Hub:       hub,
+		Kind:      kind,
+		ChangedAt: at.Unix(),
+	})
+}
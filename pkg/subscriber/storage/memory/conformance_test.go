@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage/storagetest"
+)
+
+func TestMemory_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New()
+	})
+}
@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrMalformedHub is returned when a hub fails to validate
+	ErrMalformedHub = errors.New("Memory storage: hub provided is invalid, new subscription was not launched")
+
+	// ErrMalformedTopic is returned when a topic fails to validate
+	ErrMalformedTopic = errors.New("Memory storage: topic provided is invalid, new subscription was not launched")
+
+	// ErrMalformedInactiveReason is returned when a subscription is ended without a reason
+	ErrMalformedInactiveReason = errors.New("Memory storage: inactive reason provided is invalid, subscription was not killed")
+
+	// ErrNotOffered is returned when a subscription is made through a hub that was never indexed as offering the topic
+	ErrNotOffered = errors.New("Memory storage: topic is not offered by the hub, new subscription was not launched")
+
+	// ErrDuplicateCallback is returned when a new subscription reuses the callback of another subscription
+	ErrDuplicateCallback = errors.New("Memory storage: callback is already in use, new subscription was not launched")
+)
+
+// ErrUpdateFailed is returned when an update fails to touch exactly one subscription or offer
+type ErrUpdateFailed struct {
+	numTouched int64
+}
+
+func (e ErrUpdateFailed) Error() string {
+	return fmt.Sprintf("Memory storage: update touched %d rows instead of 1", e.numTouched)
+}
+
+// ErrNewLeaseInPast is returned when a lease extended, but the provided time is in the past.
+type ErrNewLeaseInPast struct {
+	badTime time.Time
+}
+
+func (e ErrNewLeaseInPast) Error() string {
+	return fmt.Sprintf("Memory storage: New lease time provided {%v} was in the past", e.badTime)
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// Memory must implement the subscriber's Storage interface
+var _ storage.Storage = (*Memory)(nil)
+
+// Memory is an in-memory implementation of the subscriber's Storage interface, which doesn't need cgo.
+// It mirrors the tables, views and constraints of the sqlite3 implementation with maps, guarded by a single lock.
+// Like a database, it gives up on calls whose context is already done.
+// Its state is lost on shutdown.
+type Memory struct {
+	mut sync.RWMutex
+
+	offers        map[offerKey]*offer                  // offered_subscriptions, by topic and hub
+	subscriptions map[string]*subscription             // subscriptions, by callback
+	callbacks     map[offerKey]string                  // the callback of the subscription made through each offer
+	changes       map[string][]*subscriberpb.HubChange // hub_changes, by topic
+}
+
+// offerKey identifies an offer (and the subscription made through it) by its topic and hub
+type offerKey struct {
+	topic, hub string
+}
+
+// less orders keys alphabetically, by topic and then by hub
+func (k offerKey) less(other offerKey) bool {
+	if k.topic != other.topic {
+		return k.topic < other.topic
+	}
+	return k.hub < other.hub
+}
+
+// offer is a hub's offer of a topic, along with the outcome of the latest request made about it
+type offer struct {
+	attempts    int
+	lastError   string
+	lastAttempt time.Time
+	withdrawnAt time.Time // zero while the offer stands
+}
+
+// subscription is a subscription made through an offer.  Zero times stand in for NULLs.
+type subscription struct {
+	topic, hub, callback string
+
+	leaseExpiration time.Time
+	leaseInitiated  time.Time
+	inactiveReason  string
+	secret          string
+
+	requestedLeaseSeconds int64
+	grantedLeaseSeconds   int64
+}
+
+// active reports whether the hub has granted the subscription a lease that hasn't expired yet
+func (s *subscription) active(now time.Time) bool {
+	return !s.leaseExpiration.IsZero() && now.Before(s.leaseExpiration)
+}
+
+// live reports whether the subscription is either awaiting verification by its hub, or active
+func (s *subscription) live(now time.Time) bool {
+	return s.leaseExpiration.IsZero() || now.Before(s.leaseExpiration)
+}
+
+// New creates an empty in-memory storage object
+func New() *Memory {
+	return &Memory{
+		offers:        make(map[offerKey]*offer),
+		subscriptions: make(map[string]*subscription),
+		callbacks:     make(map[offerKey]string),
+		changes:       make(map[string][]*subscriberpb.HubChange),
+	}
+}
+
+// lock takes the write lock, unless the context is already done
+func (mem *Memory) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mem.mut.Lock()
+	return nil
+}
+
+// rlock takes the read lock, unless the context is already done
+func (mem *Memory) rlock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mem.mut.RLock()
+	return nil
+}
+
+// Shutdown drops everything that was stored
+func (mem *Memory) Shutdown() error {
+	mem.mut.Lock()
+	defer mem.mut.Unlock()
+
+	mem.offers = make(map[offerKey]*offer)
+	mem.subscriptions = make(map[string]*subscription)
+	mem.callbacks = make(map[offerKey]string)
+	mem.changes = make(map[string][]*subscriberpb.HubChange)
+	return nil
+}
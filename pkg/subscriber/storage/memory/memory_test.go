@@ -0,0 +1,253 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+)
+
+/*
+	# Test Cases
+
+	1. Subscription lifecycle: born, active, renewed, invalidated
+	2. Leases in the past, repeated invalidations, and unknown callbacks are rejected with the sqlite3 errors
+	3. Subscriptions need an offer, and replace earlier subscriptions through the same offer
+	4. Keyset pagination over active and inactive subscriptions
+	5. A failed reconciliation changes nothing
+	6. Concurrent renewals and invalidations
+*/
+
+func TestMemory_lifecycle(t *testing.T) {
+	mem := New()
+	defer func() {
+		if err := mem.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ctx := context.Background()
+
+	if err := mem.IndexOffer(ctx, map[string][]string{"topic": {"hub"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1. Subscription lifecycle: born, active, renewed, invalidated
+	if err := mem.NewCallback(ctx, "topic", "hub", "callback", "secret", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.GetLiveSubscription(ctx, "callback"); err != nil {
+		t.Fatalf("Expected a subscription awaiting verification to be live, but got {%v}", err)
+	}
+	if _, err := mem.GetActiveCallback(ctx, "topic", "hub"); err != storage.ErrNotFound {
+		t.Fatalf("Expected a subscription awaiting verification to be inactive, but got {%v}", err)
+	}
+
+	if err := mem.ExtendLease(ctx, "callback", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	first, err := mem.GetSubscription(ctx, "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.RequestedLeaseSeconds != 3600 || first.GrantedLeaseSeconds != 60 {
+		t.Fatalf("Expected requested/granted leases {3600, 60} but got {%v, %v}", first.RequestedLeaseSeconds, first.GrantedLeaseSeconds)
+	}
+
+	// Renewals keep the time at which the lease was first initiated
+	if err := mem.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := mem.GetSubscription(ctx, "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.LeaseInitiated != first.LeaseInitiated || renewed.LeaseExpiration <= first.LeaseExpiration {
+		t.Fatalf("Expected the lease to be extended from the same start, but got {%v} then {%v}", first, renewed)
+	}
+
+	if err := mem.Invalidate(ctx, "callback", "denied"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.GetLiveSubscription(ctx, "callback"); err != storage.ErrNotFound {
+		t.Fatalf("Expected an invalidated subscription not to be live, but got {%v}", err)
+	}
+	if secret, err := mem.GetSecret(ctx, "callback"); err != nil || secret != "secret" {
+		t.Fatalf("Expected the secret to outlive the subscription, but got {%v, %v}", secret, err)
+	}
+
+	inactive, lastPage, err := mem.GetInactive(ctx, 10, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lastPage || len(inactive.Subscriptions) != 1 || inactive.Subscriptions[0].InactiveReason != "denied" {
+		t.Fatalf("Expected the invalidated subscription to be inactive, but got %v", inactive.Subscriptions)
+	}
+
+	// 2. Leases in the past, repeated invalidations, and unknown callbacks are rejected with the sqlite3 errors
+	if err := mem.ExtendLease(ctx, "callback", time.Now().Add(-time.Minute)); err == nil {
+		t.Fatal("Expected a lease in the past to be rejected")
+	} else if _, ok := err.(ErrNewLeaseInPast); !ok {
+		t.Fatalf("Expected ErrNewLeaseInPast but got {%v}", err)
+	}
+	if err := mem.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err != (ErrUpdateFailed{0}) {
+		t.Fatalf("Expected an invalidated subscription not to be renewed, but got {%v}", err)
+	}
+	if err := mem.Invalidate(ctx, "callback", "denied"); err != (ErrUpdateFailed{0}) {
+		t.Fatalf("Expected a repeated invalidation to fail, but got {%v}", err)
+	}
+	if err := mem.Invalidate(ctx, "unknown", "denied"); err != (ErrUpdateFailed{0}) {
+		t.Fatalf("Expected invalidating an unknown callback to fail, but got {%v}", err)
+	}
+	if err := mem.Invalidate(ctx, "callback", ""); err != ErrMalformedInactiveReason {
+		t.Fatalf("Expected ErrMalformedInactiveReason but got {%v}", err)
+	}
+}
+
+func TestMemory_NewCallback(t *testing.T) {
+	mem := New()
+	ctx := context.Background()
+
+	if err := mem.IndexOffer(ctx, map[string][]string{"topic": {"hub"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 3. Subscriptions need an offer, and replace earlier subscriptions through the same offer
+	if err := mem.NewCallback(ctx, "topic", "unoffered", "callback", "", 0); err != ErrNotOffered {
+		t.Fatalf("Expected ErrNotOffered but got {%v}", err)
+	}
+	if err := mem.NewCallback(ctx, "topic", "hub", "first", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.NewCallback(ctx, "topic", "hub", "first", "", 0); err != ErrDuplicateCallback {
+		t.Fatalf("Expected ErrDuplicateCallback but got {%v}", err)
+	}
+	if err := mem.NewCallback(ctx, "topic", "hub", "second", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.GetSecret(ctx, "first"); err != storage.ErrNotFound {
+		t.Fatalf("Expected the first subscription to be replaced, but got {%v}", err)
+	}
+}
+
+func TestMemory_pagination(t *testing.T) {
+	mem := New()
+	ctx := context.Background()
+
+	// 4. Keyset pagination over active and inactive subscriptions
+	topics := map[string][]string{
+		"a": {"hub1", "hub2"},
+		"b": {"hub1"},
+		"c": {"hub1", "hub2"},
+	}
+	if err := mem.IndexOffer(ctx, topics); err != nil {
+		t.Fatal(err)
+	}
+	for _, hub := range []string{"hub1", "hub2"} {
+		if err := mem.NewCallback(ctx, "a", hub, "a"+hub, "", 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := mem.ExtendLease(ctx, "a"+hub, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var active []string
+	lastTopic, lastHub := "", ""
+	for {
+		subs, lastPage, err := mem.GetActive(ctx, 1, lastTopic, lastHub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range subs.Subscriptions {
+			active = append(active, sub.Topic+" "+sub.Hub)
+			lastTopic, lastHub = sub.Topic, sub.Hub
+		}
+		if lastPage {
+			break
+		}
+	}
+	if !reflect.DeepEqual(active, []string{"a hub1", "a hub2"}) {
+		t.Fatalf("Expected active subscriptions [a hub1, a hub2] but got %v", active)
+	}
+
+	subs, lastPage, err := mem.GetInactive(ctx, 2, "b", "hub1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastPage || len(subs.Subscriptions) != 2 || subs.Subscriptions[0].Topic != "c" || subs.Subscriptions[1].Hub != "hub2" {
+		t.Fatalf("Expected a full page of inactive subscriptions after {b hub1}, but got {%v, %v}", subs.Subscriptions, lastPage)
+	}
+}
+
+func TestMemory_ReconcileHubs(t *testing.T) {
+	mem := New()
+	ctx := context.Background()
+
+	if err := mem.IndexOffer(ctx, map[string][]string{"topic": {"hubA"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 5. A failed reconciliation changes nothing
+	if err := mem.ReconcileHubs(ctx, "topic", []string{"hubB"}, []string{"hubA", "hubC"}); err != (ErrUpdateFailed{0}) {
+		t.Fatalf("Expected removing an unoffered hub to fail, but got {%v}", err)
+	}
+	if hubs, _ := mem.GetOfferedHubs(ctx, "topic"); !reflect.DeepEqual(hubs, []string{"hubA"}) {
+		t.Fatalf("Expected hubs {[hubA]} but got {%v}", hubs)
+	}
+	if changes, _ := mem.GetHubChanges(ctx, "topic"); len(changes.HubChanges) != 0 {
+		t.Fatalf("Expected no changes to be recorded, but got %v", changes.HubChanges)
+	}
+
+	if err := mem.ReconcileHubs(ctx, "topic", []string{"hubB"}, []string{"hubA"}); err != nil {
+		t.Fatal(err)
+	}
+	if hubs, _ := mem.GetOfferedHubs(ctx, "topic"); !reflect.DeepEqual(hubs, []string{"hubB"}) {
+		t.Fatalf("Expected hubs {[hubB]} but got {%v}", hubs)
+	}
+	if changes, _ := mem.GetHubChanges(ctx, "topic"); len(changes.HubChanges) != 2 {
+		t.Fatalf("Expected 2 changes to be recorded, but got %v", changes.HubChanges)
+	}
+}
+
+func TestMemory_concurrency(t *testing.T) {
+	mem := New()
+	ctx := context.Background()
+
+	if err := mem.IndexOffer(ctx, map[string][]string{"topic": {"hub"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.NewCallback(ctx, "topic", "hub", "callback", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 6. Concurrent renewals and invalidations
+	var wg sync.WaitGroup
+	invalidated := make(chan struct{}, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			mem.ExtendLease(ctx, "callback", time.Now().Add(time.Hour))
+		}()
+		go func() {
+			defer wg.Done()
+			if mem.Invalidate(ctx, "callback", "cancelled") == nil {
+				invalidated <- struct{}{}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(invalidated) != 1 {
+		t.Fatalf("Expected exactly one invalidation to succeed, but %d did", len(invalidated))
+	}
+	if _, err := mem.GetActiveCallback(ctx, "topic", "hub"); err != storage.ErrNotFound {
+		t.Fatalf("Expected the subscription to end up inactive, but got {%v}", err)
+	}
+}
@@ -0,0 +1,257 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// GetActiveCallback returns the callback of the active subscription for the given topic+hub combination
+func (mem *Memory) GetActiveCallback(ctx context.Context, topic, hub string) (string, error) {
+	if err := mem.rlock(ctx); err != nil {
+		return "", err
+	}
+	defer mem.mut.RUnlock()
+
+	callback, ok := mem.callbacks[offerKey{topic, hub}]
+	if !ok || !mem.subscriptions[callback].active(time.Now()) {
+		return "", storage.ErrNotFound
+	}
+	return callback, nil
+}
+
+// GetSubscription returns the active subscription associated with the given callback.
+// Leases that were not requested are reported as zero seconds.
+func (mem *Memory) GetSubscription(ctx context.Context, callback string) (*subscriberpb.Subscription, error) {
+	if err := mem.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer mem.mut.RUnlock()
+
+	sub, ok := mem.subscriptions[callback]
+	if !ok || !sub.active(time.Now()) {
+		return nil, storage.ErrNotFound
+	}
+
+	return &subscriberpb.Subscription{
+		Topic:           sub.topic,
+		Hub:             sub.hub,
+		Callback:        sub.callback,
+		LeaseExpiration: sub.leaseExpiration.Unix(),
+		LeaseInitiated:  sub.leaseInitiated.Unix(),
+		InactiveReason:  sub.inactiveReason,
+
+		RequestedLeaseSeconds: sub.requestedLeaseSeconds,
+		GrantedLeaseSeconds:   sub.grantedLeaseSeconds,
+	}, nil
+}
+
+// GetLiveSubscription returns the subscription associated with the given callback,
+// as long as it is either awaiting verification by its hub, or active.
+// Callbacks that have been invalidated, or whose lease has expired, are not returned.
+func (mem *Memory) GetLiveSubscription(ctx context.Context, callback string) (*subscriberpb.Subscription, error) {
+	if err := mem.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer mem.mut.RUnlock()
+
+	sub, ok := mem.subscriptions[callback]
+	if !ok || !sub.live(time.Now()) {
+		return nil, storage.ErrNotFound
+	}
+
+	return &subscriberpb.Subscription{
+		Topic:    sub.topic,
+		Hub:      sub.hub,
+		Callback: sub.callback,
+	}, nil
+}
+
+// GetSecret returns the hub.secret associated with the given callback.
+// An empty string is returned for callbacks whose subscription request did not include a secret.
+func (mem *Memory) GetSecret(ctx context.Context, callback string) (string, error) {
+	if err := mem.rlock(ctx); err != nil {
+		return "", err
+	}
+	defer mem.mut.RUnlock()
+
+	sub, ok := mem.subscriptions[callback]
+	if !ok {
+		return "", storage.ErrNotFound
+	}
+	return sub.secret, nil
+}
+
+// GetActive returns at most 'pageSize' active subscriptions in alphabetical order.
+// If there are more than 'pageSize', the caller can use 'lastTopic' and 'lastHub' to ask for the next page.
+func (mem *Memory) GetActive(ctx context.Context, pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error) {
+	if err := mem.rlock(ctx); err != nil {
+		return nil, false, err
+	}
+	defer mem.mut.RUnlock()
+
+	now := time.Now()
+	after := offerKey{lastTopic, lastHub}
+
+	var keys []offerKey
+	for key, callback := range mem.callbacks {
+		if after.less(key) && mem.subscriptions[callback].active(now) {
+			keys = append(keys, key)
+		}
+	}
+	keys = page(keys, pageSize)
+
+	subs = &subscriberpb.Subscriptions{}
+	for _, key := range keys {
+		sub := mem.subscriptions[mem.callbacks[key]]
+		subs.Subscriptions = append(subs.Subscriptions, &subscriberpb.Subscription{
+			Callback:        sub.callback,
+			Topic:           sub.topic,
+			Hub:             sub.hub,
+			LeaseExpiration: sub.leaseExpiration.Unix(),
+			LeaseInitiated:  sub.leaseInitiated.Unix(),
+
+			RequestedLeaseSeconds: sub.requestedLeaseSeconds,
+			GrantedLeaseSeconds:   sub.grantedLeaseSeconds,
+		})
+	}
+
+	return subs, len(keys) < pageSize, nil
+}
+
+// GetInactive returns at most 'pageSize' inactive topic/hub tuples in alphabetical order.
+// If there are more than 'pageSize', the caller can use 'lastTopic' and 'lastHub' to ask for the next page.
+func (mem *Memory) GetInactive(ctx context.Context, pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error) {
+	if err := mem.rlock(ctx); err != nil {
+		return nil, false, err
+	}
+	defer mem.mut.RUnlock()
+
+	now := time.Now()
+	after := offerKey{lastTopic, lastHub}
+
+	var keys []offerKey
+	for key, off := range mem.offers {
+		if !after.less(key) || !off.withdrawnAt.IsZero() {
+			continue
+		}
+		if callback, ok := mem.callbacks[key]; ok && mem.subscriptions[callback].active(now) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	keys = page(keys, pageSize)
+
+	subs = &subscriberpb.Subscriptions{}
+	for _, key := range keys {
+		inactive := &subscriberpb.Subscription{
+			Topic: key.topic,
+			Hub:   key.hub,
+		}
+
+		// Offers that were never subscribed through have nothing more to report
+		if callback, ok := mem.callbacks[key]; ok {
+			sub := mem.subscriptions[callback]
+			inactive.Callback = sub.callback
+			inactive.InactiveReason = sub.inactiveReason
+			inactive.RequestedLeaseSeconds = sub.requestedLeaseSeconds
+
+			// Subscriptions that were never ACK'd have no expiration
+			if !sub.leaseExpiration.IsZero() {
+				inactive.LeaseExpiration = sub.leaseExpiration.Unix()
+			}
+		}
+
+		subs.Subscriptions = append(subs.Subscriptions, inactive)
+	}
+
+	return subs, len(keys) < pageSize, nil
+}
+
+// page sorts the keys, and keeps the first 'pageSize' of them
+func page(keys []offerKey, pageSize int) []offerKey {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+	if pageSize >= 0 && len(keys) > pageSize {
+		keys = keys[:pageSize]
+	}
+	return keys
+}
+
+// GetAttempts returns the number of tries taken by the latest request made to the hub about the topic,
+// and the error that its last try ended with (if any).
+func (mem *Memory) GetAttempts(ctx context.Context, topic, hub string) (attempts int, lastError string, err error) {
+	if err := mem.rlock(ctx); err != nil {
+		return 0, "", err
+	}
+	defer mem.mut.RUnlock()
+
+	off, ok := mem.offers[offerKey{topic, hub}]
+	if !ok {
+		return 0, "", storage.ErrNotFound
+	}
+	return off.attempts, off.lastError, nil
+}
+
+// GetOfferedTopics returns every topic that is offered by at least one hub, in alphabetical order
+func (mem *Memory) GetOfferedTopics(ctx context.Context) ([]string, error) {
+	if err := mem.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer mem.mut.RUnlock()
+
+	seen := make(map[string]struct{})
+	var topics []string
+	for key, off := range mem.offers {
+		if _, ok := seen[key.topic]; ok || !off.withdrawnAt.IsZero() {
+			continue
+		}
+		seen[key.topic] = struct{}{}
+		topics = append(topics, key.topic)
+	}
+
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// GetOfferedHubs returns the hubs that offer the topic, in alphabetical order.
+// Hubs whose offers were withdrawn are left out.
+func (mem *Memory) GetOfferedHubs(ctx context.Context, topic string) ([]string, error) {
+	if err := mem.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer mem.mut.RUnlock()
+
+	var hubs []string
+	for key, off := range mem.offers {
+		if key.topic == topic && off.withdrawnAt.IsZero() {
+			hubs = append(hubs, key.hub)
+		}
+	}
+
+	sort.Strings(hubs)
+	return hubs, nil
+}
+
+// GetHubChanges returns the history of hubs being added to and removed from the topic, oldest first
+func (mem *Memory) GetHubChanges(ctx context.Context, topic string) (*subscriberpb.HubChanges, error) {
+	if err := mem.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer mem.mut.RUnlock()
+
+	changes := &subscriberpb.HubChanges{}
+	for _, change := range mem.changes[topic] {
+		changes.HubChanges = append(changes.HubChanges, &subscriberpb.HubChange{
+			Topic:     change.Topic,
+			Hub:       change.Hub,
+			Kind:      change.Kind,
+			ChangedAt: change.ChangedAt,
+		})
+	}
+	return changes, nil
+}
@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrMalformedHub is returned when a hub fails to validate
+	ErrMalformedHub = errors.New("Postgres storage: hub provided is invalid, new subscription was not launched")
+
+	// ErrMalformedTopic is returned when a topic fails to validate
+	ErrMalformedTopic = errors.New("Postgres storage: topic provided is invalid, new subscription was not launched")
+
+	// ErrMalformedInactiveReason is returned when a subscription is ended without a reason
+	ErrMalformedInactiveReason = errors.New("Postgres storage: inactive reason provided is invalid, subscription was not killed")
+
+	// ErrMissingDSN is returned when the config doesn't say which postgres server to connect to
+	ErrMissingDSN = errors.New("Postgres storage: a DSN is required, storage was not created")
+)
+
+// ErrUpdateFailed is returned when an update fails to touch exactly one row
+type ErrUpdateFailed struct {
+	numTouched int64
+}
+
+func (e ErrUpdateFailed) Error() string {
+	return fmt.Sprintf("Postgres storage: update touched %d rows instead of 1", e.numTouched)
+}
+
+// ErrNewLeaseInPast is returned when a lease extended, but the provided time is in the past.
+type ErrNewLeaseInPast struct {
+	badTime time.Time
+}
+
+func (e ErrNewLeaseInPast) Error() string {
+	return fmt.Sprintf("Postgres storage: New lease time provided {%v} was in the past", e.badTime)
+}
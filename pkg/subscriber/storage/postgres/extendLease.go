@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+// ExtendLease provides a subscription lease to a given callback.  Implicitly, this means that the subscription is active.
+// This occurs when a subscription is first ACK'd, and also upon subsequent lease renewals.
+// The time remaining until newExpiration is recorded as the lease that the hub granted.
+func (pgStor *Postgres) ExtendLease(ctx context.Context, callback string, newExpiration time.Time) error {
+	if newExpiration.Before(time.Now()) {
+		return ErrNewLeaseInPast{newExpiration}
+	}
+
+	// As in Invalidate, the clock is read when the row is (re)checked, so that a lease ended concurrently stays ended
+	res, err := pgStor.db.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET
+			lease_expiration=$1,
+			granted_lease_seconds=$2,
+			lease_initiated=COALESCE(lease_initiated, $3)
+		WHERE
+			callback_url=$4
+			AND (
+				lease_expiration IS NULL
+				OR clock_timestamp() < lease_expiration);`,
+		newExpiration.UTC(),
+		int64(time.Until(newExpiration).Round(time.Second)/time.Second),
+		time.Now().UTC(),
+		callback,
+	)
+	if err != nil {
+		return err
+	}
+
+	return exactlyOne(res)
+}
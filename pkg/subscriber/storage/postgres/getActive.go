@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// GetActive returns at most 'pageSize' active subscriptions in alphabetical order.
+// If there are more than 'pageSize', the caller can use 'lastTopic' and 'lastHub' to ask for the next page.
+// Leases that were not requested are reported as zero seconds.
+func (pgStor *Postgres) GetActive(ctx context.Context, pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error) {
+	rows, err := pgStor.db.QueryContext(ctx, `
+		SELECT topic_url, hub_url, callback_url, lease_expiration, lease_initiated,
+			requested_lease_seconds, granted_lease_seconds
+		FROM active_subscriptions
+		WHERE (topic_url, hub_url) > ($1, $2)
+		ORDER BY topic_url, hub_url
+		LIMIT $3;`,
+		lastTopic,
+		lastHub,
+		pageSize,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	subs = &subscriberpb.Subscriptions{}
+
+	rowCtr := 0
+	for rows.Next() {
+		var topic, hub, callback string
+		var expiration, initiated time.Time
+		var requested, granted sql.NullInt64
+		if err := rows.Scan(&topic, &hub, &callback, &expiration, &initiated, &requested, &granted); err != nil {
+			return nil, false, err
+		}
+
+		// Add info to list
+		subs.Subscriptions = append(
+			subs.Subscriptions,
+			&subscriberpb.Subscription{
+				Callback:        callback,
+				Topic:           topic,
+				Hub:             hub,
+				LeaseExpiration: expiration.Unix(),
+				LeaseInitiated:  initiated.Unix(),
+
+				RequestedLeaseSeconds: requested.Int64,
+				GrantedLeaseSeconds:   granted.Int64,
+			},
+		)
+		rowCtr++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	return subs, rowCtr < pageSize, nil
+}
@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"context"
+)
+
+// GetActiveCallback returns the callback of the active subscription for the given topic+hub combination
+func (pgStor *Postgres) GetActiveCallback(ctx context.Context, topic, hub string) (string, error) {
+	row := pgStor.db.QueryRowContext(ctx, `
+		SELECT callback_url
+		FROM active_subscriptions
+		WHERE topic_url=$1 AND hub_url=$2;`,
+		topic,
+		hub,
+	)
+
+	var callback string
+	if err := row.Scan(&callback); err != nil {
+		return "", err
+	}
+
+	return callback, nil
+}
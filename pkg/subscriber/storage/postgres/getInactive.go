@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// GetInactive returns at most 'pageSize' inactive topic/hub tuples in alphabetical order.
+// If there are more than 'pageSize', the caller can use 'lastTopic' and 'lastHub' to ask for the next page.
+func (pgStor *Postgres) GetInactive(ctx context.Context, pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error) {
+	rows, err := pgStor.db.QueryContext(ctx, `
+		SELECT topic_url, hub_url, callback_url, lease_expiration, inactive_reason, requested_lease_seconds
+		FROM inactive_subscriptions
+		WHERE (topic_url, hub_url) > ($1, $2)
+		ORDER BY topic_url, hub_url
+		LIMIT $3;`,
+		lastTopic,
+		lastHub,
+		pageSize,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	subs = &subscriberpb.Subscriptions{}
+
+	// Read our rows
+	rowCtr := 0
+	for rows.Next() {
+		var topic, hub string
+		var callback, inactiveReason sql.NullString
+		var leaseExpiration sql.NullTime
+		var requestedLease sql.NullInt64
+		err := rows.Scan(&topic, &hub, &callback, &leaseExpiration, &inactiveReason, &requestedLease)
+		if err != nil {
+			return nil, false, err
+		}
+
+		// Subscriptions that were never ACK'd have no expiration
+		var expiration int64
+		if leaseExpiration.Valid {
+			expiration = leaseExpiration.Time.Unix()
+		}
+
+		// Add info to list
+		subs.Subscriptions = append(
+			subs.Subscriptions,
+			&subscriberpb.Subscription{
+				Topic:           topic,
+				Hub:             hub,
+				Callback:        callback.String,
+				LeaseExpiration: expiration,
+				InactiveReason:  inactiveReason.String,
+
+				RequestedLeaseSeconds: requestedLease.Int64,
+			},
+		)
+		rowCtr++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	return subs, rowCtr < pageSize, nil
+}
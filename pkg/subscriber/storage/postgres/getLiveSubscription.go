@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// GetLiveSubscription returns the subscription associated with the given callback,
+// as long as it is either awaiting verification by its hub, or active.
+// Callbacks that have been invalidated, or whose lease has expired, are not returned.
+func (pgStor *Postgres) GetLiveSubscription(ctx context.Context, callback string) (*subscriberpb.Subscription, error) {
+	row := pgStor.db.QueryRowContext(ctx, `
+		SELECT topic_url, hub_url, callback_url
+		FROM subscriptions
+		WHERE
+			callback_url=$1
+			AND (
+				lease_expiration IS NULL
+				OR now() < lease_expiration);`,
+		callback,
+	)
+
+	var tp, hb, cb string
+	if err := row.Scan(&tp, &hb, &cb); err != nil {
+		return nil, err
+	}
+
+	return &subscriberpb.Subscription{
+		Topic:    tp,
+		Hub:      hb,
+		Callback: cb,
+	}, nil
+}
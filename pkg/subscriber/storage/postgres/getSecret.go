@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// GetSecret returns the hub.secret associated with the given callback.
+// An empty string is returned for callbacks whose subscription request did not include a secret.
+func (pgStor *Postgres) GetSecret(ctx context.Context, callback string) (string, error) {
+	row := pgStor.db.QueryRowContext(ctx, `
+		SELECT secret
+		FROM subscriptions
+		WHERE callback_url=$1;`,
+		callback,
+	)
+
+	var secret sql.NullString
+	if err := row.Scan(&secret); err != nil {
+		return "", err
+	}
+
+	return secret.String, nil
+}
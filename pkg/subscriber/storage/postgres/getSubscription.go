@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// GetSubscription returns any subscription associated with the given callback.
+// Leases that were not requested (or that the hub has not granted yet) are reported as zero seconds.
+func (pgStor *Postgres) GetSubscription(ctx context.Context, callback string) (*subscriberpb.Subscription, error) {
+	row := pgStor.db.QueryRowContext(ctx, `
+		SELECT topic_url, hub_url, callback_url, lease_expiration, lease_initiated, inactive_reason,
+			requested_lease_seconds, granted_lease_seconds
+		FROM active_subscriptions
+		WHERE callback_url=$1;`,
+		callback,
+	)
+
+	var tp, hb, cb string
+	var exp, init time.Time
+	var rea sql.NullString
+	var req, gra sql.NullInt64
+	if err := row.Scan(&tp, &hb, &cb, &exp, &init, &rea, &req, &gra); err != nil {
+		return nil, err
+	}
+
+	return &subscriberpb.Subscription{
+		Topic:           tp,
+		Hub:             hb,
+		Callback:        cb,
+		LeaseExpiration: exp.Unix(),
+		LeaseInitiated:  init.Unix(),
+		InactiveReason:  rea.String,
+
+		RequestedLeaseSeconds: req.Int64,
+		GrantedLeaseSeconds:   gra.Int64,
+	}, nil
+}
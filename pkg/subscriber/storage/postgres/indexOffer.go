@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// IndexOffer indexes the topic <-> hub relationships, which are observed in the discovery phase.
+// Each topic may be offered by any number of hubs. Offers that were withdrawn are offered once again.
+func (pgStor *Postgres) IndexOffer(ctx context.Context, topicsToHubs map[string][]string) (err error) {
+	tx, err := pgStor.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+
+	// Defer a rollback, if an error is encountered
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO offered_subscriptions (topic_url, hub_url)
+		VALUES ($1, $2)
+		ON CONFLICT (topic_url, hub_url) DO UPDATE
+		SET withdrawn_at=NULL;`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for topic, hubs := range topicsToHubs {
+		if topic == "" {
+			return ErrMalformedTopic
+		}
+		for _, hub := range hubs {
+			if hub == "" {
+				return ErrMalformedHub
+			}
+			if _, err = stmt.ExecContext(ctx, topic, hub); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Invalidate is called when a hub no longer views the subscription as active.
+// Invalidating is an idempotent action, it is OK to do it more than once.
+// Repeated invalidations will return a handleable error, ErrUpdateFailed.
+func (pgStor *Postgres) Invalidate(ctx context.Context, callback, inactiveReason string) error {
+	if inactiveReason == "" {
+		return ErrMalformedInactiveReason
+	}
+
+	// The clock is read when the row is (re)checked, rather than when the transaction began, so that an
+	// invalidation that waited on a concurrent one sees the lease that it ended.
+	res, err := pgStor.db.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET lease_initiated=NULL, lease_expiration=clock_timestamp(), inactive_reason=$1
+		WHERE callback_url=$2 AND (lease_expiration IS NULL OR lease_expiration > clock_timestamp());`,
+		inactiveReason, callback,
+	)
+	if err != nil {
+		return err
+	}
+
+	return exactlyOne(res)
+}
+
+// exactlyOne returns ErrUpdateFailed, unless the statement touched exactly one row
+func exactlyOne(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrUpdateFailed{n}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// MigrateHub moves the topic's offer, and any subscription made through it, from the old hub to the new one.
+// This occurs when the old hub permanently redirects a request about the topic.
+// If the topic was already offered by the new hub, the old hub's subscription replaces the new hub's.
+func (pgStor *Postgres) MigrateHub(ctx context.Context, topic, oldHub, newHub string) (err error) {
+	if newHub == "" {
+		return ErrMalformedHub
+	}
+
+	tx, err := pgStor.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+
+	// Defer a rollback, if an error is encountered
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `
+		INSERT INTO offered_subscriptions
+		(topic_url, hub_url, attempts, last_error, last_attempt)
+		SELECT topic_url, $1, attempts, last_error, last_attempt
+		FROM offered_subscriptions
+		WHERE topic_url=$2 AND hub_url=$3
+		ON CONFLICT (topic_url, hub_url) DO NOTHING;`,
+		newHub,
+		topic,
+		oldHub,
+	); err != nil {
+		return err
+	}
+
+	// Make room for the old hub's subscription, if it has one
+	if _, err = tx.ExecContext(ctx, `
+		DELETE FROM subscriptions
+		WHERE topic_url=$1 AND hub_url=$2 AND EXISTS (
+			SELECT 1
+			FROM subscriptions
+			WHERE topic_url=$1 AND hub_url=$3);`,
+		topic,
+		newHub,
+		oldHub,
+	); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET hub_url=$1
+		WHERE topic_url=$2 AND hub_url=$3;`,
+		newHub,
+		topic,
+		oldHub,
+	); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM offered_subscriptions
+		WHERE topic_url=$1 AND hub_url=$2;`,
+		topic,
+		oldHub,
+	)
+	if err != nil {
+		return err
+	}
+	if err = exactlyOne(res); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
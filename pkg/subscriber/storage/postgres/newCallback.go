@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// NewCallback implies that the client is waiting for reply to a sub request on the given callback.
+// The secret is the hub.secret sent along with the request, or empty if none was sent.
+// The requestedLease is the hub.lease_seconds sent along with the request, or zero if none was sent.
+// Any earlier subscription with the same topic and hub is replaced.
+func (pgStor *Postgres) NewCallback(ctx context.Context, topic, hub, callback, secret string, requestedLease time.Duration) error {
+	if topic == "" {
+		return ErrMalformedTopic
+	}
+	if hub == "" {
+		return ErrMalformedHub
+	}
+
+	_, err := pgStor.db.ExecContext(ctx, `
+		INSERT INTO subscriptions
+		(topic_url, hub_url, callback_url, secret, requested_lease_seconds) VALUES
+		($1, $2, $3, $4, $5)
+		ON CONFLICT (topic_url, hub_url) DO UPDATE
+		SET
+			callback_url=EXCLUDED.callback_url,
+			secret=EXCLUDED.secret,
+			requested_lease_seconds=EXCLUDED.requested_lease_seconds,
+			granted_lease_seconds=NULL,
+			lease_expiration=NULL,
+			lease_initiated=NULL,
+			inactive_reason=NULL;`,
+		topic, hub, callback,
+		sql.NullString{String: secret, Valid: secret != ""},
+		sql.NullInt64{Int64: int64(requestedLease / time.Second), Valid: requestedLease > 0},
+	)
+	return err
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+	_ "github.com/lib/pq" // Implementation of postgres driver
+)
+
+// Postgres must implement the subscriber's Storage interface
+var _ storage.Storage = (*Postgres)(nil)
+
+// Postgres is a PostgreSQL implementation of the subscriber's Storage interface.
+// Unlike the sqlite3 implementation, several Subscribers can share a single postgres database.
+type Postgres struct {
+	db *sql.DB
+}
+
+// New connects to the postgres server in the config, creates the schema if it doesn't exist yet, and returns
+// the storage object.
+func New(cfg *Config) (*Postgres, error) {
+	if cfg.DSN == "" {
+		return nil, ErrMissingDSN
+	}
+
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+
+	if err := createSchema(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Postgres{db: db}, nil
+}
+
+// createSchema creates the tables and views, in a single transaction
+func createSchema(ctx context.Context, db *sql.DB) (err error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+
+	// Defer a rollback, if an error is encountered
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1);`, int64(schemaLock)); err != nil {
+		return err
+	}
+
+	for _, stmt := range []string{
+		offeredSubscriptionsTable,
+		subscriptionTable,
+		hubChangesTable,
+		hubChangesIndex,
+		activeView,
+		inactiveView,
+	} {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Shutdown closes the connections to postgres. The database itself is left as it is.
+func (pgStor *Postgres) Shutdown() error {
+	return pgStor.db.Close()
+}
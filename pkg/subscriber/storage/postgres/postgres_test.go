@@ -0,0 +1,445 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage/storagetest"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+/*
+	# Test Cases
+
+	1. Subscription lifecycle: born, active, renewed, invalidated
+	2. Keyset pagination over active and inactive subscriptions
+	3. Leases that end before they begin are rejected by the CHECK constraint
+	4. Migrating a hub moves the offer and its subscription
+	5. Reconciling hubs withdraws and re-adds offers, and records the changes
+	6. Two replicas share state through the same database
+
+	Tests run against the server in $WEBSUB_POSTGRES_DSN if it is set.
+	Otherwise, a throwaway server is spawned from the initdb and pg_ctl binaries on the PATH (or in the usual
+	Debian location).  If neither is available, the tests are skipped.
+*/
+
+// testDSN points at the postgres server used by the tests, or is empty if there isn't one
+var testDSN string
+
+func TestMain(m *testing.M) {
+	testDSN = os.Getenv("WEBSUB_POSTGRES_DSN")
+
+	var stop func()
+	if testDSN == "" {
+		dsn, stopServer, err := spawnPostgres()
+		if err != nil {
+			log.Printf("Postgres tests will be skipped: %v\n", err)
+		} else {
+			testDSN, stop = dsn, stopServer
+		}
+	}
+
+	code := m.Run()
+	if stop != nil {
+		stop()
+	}
+	os.Exit(code)
+}
+
+// spawnPostgres initializes a database cluster in a temporary directory, and starts a server on it
+// that only listens on a unix socket.  The returned function stops the server and removes the cluster.
+func spawnPostgres() (dsn string, stop func(), err error) {
+	if u, err := user.Current(); err == nil && u.Uid == "0" {
+		return "", nil, fmt.Errorf("initdb cannot be run as root")
+	}
+
+	initdb, err := findPostgresBinary("initdb")
+	if err != nil {
+		return "", nil, err
+	}
+	pgCtl, err := findPostgresBinary("pg_ctl")
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "websub-postgres")
+	if err != nil {
+		return "", nil, err
+	}
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "--auth=trust").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("initdb failed: %v\n%s", err, out)
+	}
+
+	out, err = exec.Command(pgCtl, "start", "-w", "-D", data, "-l", filepath.Join(dir, "log"),
+		"-o", fmt.Sprintf("-h '' -k %s -p 5433", dir)).CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("pg_ctl failed to start postgres: %v\n%s", err, out)
+	}
+
+	stop = func() {
+		exec.Command(pgCtl, "stop", "-w", "-m", "fast", "-D", data).Run()
+		os.RemoveAll(dir)
+	}
+	return fmt.Sprintf("host=%s port=5433 user=postgres dbname=postgres sslmode=disable", dir), stop, nil
+}
+
+// findPostgresBinary looks for the named postgres binary on the PATH, and then in Debian's versioned directories
+func findPostgresBinary(name string) (string, error) {
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+
+	matches, _ := filepath.Glob(filepath.Join("/usr/lib/postgresql/*/bin", name))
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no %s binary found", name)
+	}
+	return matches[len(matches)-1], nil
+}
+
+// newTestPostgres returns a storage object that works in a schema of its own, which is dropped when the test ends
+func newTestPostgres(t *testing.T) *Postgres {
+	t.Helper()
+
+	pgStor, err := New(&Config{DSN: newTestSchema(t), MaxOpenConns: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := pgStor.Shutdown(); err != nil {
+			t.Error(err)
+		}
+	})
+	return pgStor
+}
+
+// newTestSchema creates an empty schema, which is dropped when the test ends, and returns a DSN that works in it
+func newTestSchema(t *testing.T) string {
+	t.Helper()
+	if testDSN == "" {
+		t.Skip("No postgres server is available")
+	}
+
+	schema := fmt.Sprintf("websub_test_%d", time.Now().UnixNano())
+
+	admin, err := sql.Open("postgres", testDSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Error(err)
+		}
+		admin.Close()
+	})
+
+	return withSearchPath(testDSN, schema)
+}
+
+// withSearchPath points the connections made with the DSN at the given schema
+func withSearchPath(dsn, schema string) string {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+func TestPostgres_lifecycle(t *testing.T) {
+	pgStor := newTestPostgres(t)
+	ctx := context.Background()
+
+	if err := pgStor.IndexOffer(ctx, map[string][]string{"topic": {"hub"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1. Subscription lifecycle: born, active, renewed, invalidated
+	if err := pgStor.NewCallback(ctx, "topic", "hub", "callback", "secret", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pgStor.GetLiveSubscription(ctx, "callback"); err != nil {
+		t.Fatalf("Expected a subscription awaiting verification to be live, but got {%v}", err)
+	}
+	if _, err := pgStor.GetActiveCallback(ctx, "topic", "hub"); err != sql.ErrNoRows {
+		t.Fatalf("Expected a subscription awaiting verification to be inactive, but got {%v}", err)
+	}
+	if secret, err := pgStor.GetSecret(ctx, "callback"); err != nil || secret != "secret" {
+		t.Fatalf("Expected secret {secret} but got {%v, %v}", secret, err)
+	}
+
+	if err := pgStor.ExtendLease(ctx, "callback", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	first, err := pgStor.GetSubscription(ctx, "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.RequestedLeaseSeconds != 3600 || first.GrantedLeaseSeconds != 60 {
+		t.Fatalf("Expected requested/granted leases {3600, 60} but got {%v, %v}", first.RequestedLeaseSeconds, first.GrantedLeaseSeconds)
+	}
+
+	// Renewals keep the time at which the lease was first initiated
+	if err := pgStor.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := pgStor.GetSubscription(ctx, "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.LeaseInitiated != first.LeaseInitiated || renewed.LeaseExpiration <= first.LeaseExpiration {
+		t.Fatalf("Expected the lease to be extended from the same start, but got {%v} then {%v}", first, renewed)
+	}
+
+	if err := pgStor.ExtendLease(ctx, "callback", time.Now().Add(-time.Minute)); err == nil {
+		t.Fatal("Expected a lease in the past to be rejected")
+	}
+
+	if err := pgStor.Invalidate(ctx, "callback", "denied"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pgStor.Invalidate(ctx, "callback", "denied"); err == nil {
+		t.Fatal("Expected a repeated invalidation to fail")
+	}
+	if _, err := pgStor.GetLiveSubscription(ctx, "callback"); err != sql.ErrNoRows {
+		t.Fatalf("Expected an invalidated subscription not to be live, but got {%v}", err)
+	}
+
+	inactive, _, err := pgStor.GetInactive(ctx, 10, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inactive.Subscriptions) != 1 || inactive.Subscriptions[0].InactiveReason != "denied" {
+		t.Fatalf("Expected the invalidated subscription to be inactive, but got %v", inactive.Subscriptions)
+	}
+}
+
+func TestPostgres_pagination(t *testing.T) {
+	pgStor := newTestPostgres(t)
+	ctx := context.Background()
+
+	// 2. Keyset pagination over active and inactive subscriptions
+	topics := map[string][]string{
+		"a": {"hub1", "hub2"},
+		"b": {"hub1"},
+		"c": {"hub1", "hub2"},
+	}
+	if err := pgStor.IndexOffer(ctx, topics); err != nil {
+		t.Fatal(err)
+	}
+
+	inactive := collect(t, pgStor.GetInactive)
+	if !reflect.DeepEqual(inactive, []string{"a hub1", "a hub2", "b hub1", "c hub1", "c hub2"}) {
+		t.Fatalf("Expected every offer to be inactive, in order, but got %v", inactive)
+	}
+
+	for topic, hubs := range topics {
+		for _, hub := range hubs {
+			cb := topic + hub
+			if err := pgStor.NewCallback(ctx, topic, hub, cb, "", 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := pgStor.ExtendLease(ctx, cb, time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	active := collect(t, pgStor.GetActive)
+	if !reflect.DeepEqual(active, []string{"a hub1", "a hub2", "b hub1", "c hub1", "c hub2"}) {
+		t.Fatalf("Expected every offer to be active, in order, but got %v", active)
+	}
+	if inactive := collect(t, pgStor.GetInactive); len(inactive) != 0 {
+		t.Fatalf("Expected no inactive subscriptions, but got %v", inactive)
+	}
+}
+
+// collect walks every page of the listing two subscriptions at a time, and returns the topic/hub pairs
+func collect(t *testing.T, list func(context.Context, int, string, string) (*subscriberpb.Subscriptions, bool, error)) []string {
+	t.Helper()
+
+	var pairs []string
+	lastTopic, lastHub := "", ""
+	for {
+		subs, lastPage, err := list(context.Background(), 2, lastTopic, lastHub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range subs.Subscriptions {
+			pairs = append(pairs, sub.Topic+" "+sub.Hub)
+			lastTopic, lastHub = sub.Topic, sub.Hub
+		}
+		if lastPage {
+			return pairs
+		}
+	}
+}
+
+func TestPostgres_leaseCheck(t *testing.T) {
+	pgStor := newTestPostgres(t)
+	ctx := context.Background()
+
+	if err := pgStor.IndexOffer(ctx, map[string][]string{"topic": {"hub"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pgStor.NewCallback(ctx, "topic", "hub", "callback", "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 3. Leases that end before they begin are rejected by the CHECK constraint
+	_, err := pgStor.db.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET lease_initiated=now(), lease_expiration=now() - interval '1 hour'
+		WHERE callback_url='callback';`)
+	if err == nil {
+		t.Fatal("Expected a lease that ends before it begins to be rejected")
+	}
+
+	// Subscriptions are only made through offered hubs
+	if err := pgStor.NewCallback(ctx, "topic", "unoffered", "callback2", "", 0); err == nil {
+		t.Fatal("Expected a subscription through a hub that doesn't offer the topic to be rejected")
+	}
+}
+
+func TestPostgres_MigrateHub(t *testing.T) {
+	pgStor := newTestPostgres(t)
+	ctx := context.Background()
+
+	if err := pgStor.IndexOffer(ctx, map[string][]string{"topic": {"hub", "newHub"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pgStor.NewCallback(ctx, "topic", "newHub", "stale", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := pgStor.NewCallback(ctx, "topic", "hub", "callback", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := pgStor.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 4. Migrating a hub moves the offer and its subscription
+	if err := pgStor.MigrateHub(ctx, "topic", "hub", "newHub"); err != nil {
+		t.Fatal(err)
+	}
+	if cb, err := pgStor.GetActiveCallback(ctx, "topic", "newHub"); err != nil || cb != "callback" {
+		t.Fatalf("Expected callback {callback} at the new hub, but got {%v, %v}", cb, err)
+	}
+	if _, err := pgStor.GetSubscription(ctx, "stale"); err != sql.ErrNoRows {
+		t.Fatalf("Expected the new hub's subscription to be replaced, but got {%v}", err)
+	}
+	if hubs, err := pgStor.GetOfferedHubs(ctx, "topic"); err != nil || !reflect.DeepEqual(hubs, []string{"newHub"}) {
+		t.Fatalf("Expected only the new hub to be offered, but got {%v, %v}", hubs, err)
+	}
+
+	if err := pgStor.MigrateHub(ctx, "topic", "hub", "newHub"); err == nil {
+		t.Fatal("Expected migrating a hub that isn't offered to fail")
+	}
+}
+
+func TestPostgres_ReconcileHubs(t *testing.T) {
+	pgStor := newTestPostgres(t)
+	ctx := context.Background()
+
+	if err := pgStor.IndexOffer(ctx, map[string][]string{"topic": {"hubA"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 5. Reconciling hubs withdraws and re-adds offers, and records the changes
+	if err := pgStor.ReconcileHubs(ctx, "topic", []string{"hubB"}, []string{"hubA"}); err != nil {
+		t.Fatal(err)
+	}
+	if hubs, err := pgStor.GetOfferedHubs(ctx, "topic"); err != nil || !reflect.DeepEqual(hubs, []string{"hubB"}) {
+		t.Fatalf("Expected hubs {[hubB]} but got {%v, %v}", hubs, err)
+	}
+
+	if err := pgStor.ReconcileHubs(ctx, "topic", []string{"hubA"}, []string{"hubB"}); err != nil {
+		t.Fatal(err)
+	}
+	if hubs, err := pgStor.GetOfferedHubs(ctx, "topic"); err != nil || !reflect.DeepEqual(hubs, []string{"hubA"}) {
+		t.Fatalf("Expected hubs {[hubA]} but got {%v, %v}", hubs, err)
+	}
+
+	if err := pgStor.ReconcileHubs(ctx, "topic", nil, []string{"hubB"}); err == nil {
+		t.Fatal("Expected removing a withdrawn hub to fail")
+	}
+
+	changes, err := pgStor.GetHubChanges(ctx, "topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, change := range changes.HubChanges {
+		got = append(got, change.Kind.String()+" "+change.Hub)
+	}
+	expected := []string{"HubAdded hubB", "HubRemoved hubA", "HubAdded hubA", "HubRemoved hubB"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected changes %v but got %v", expected, got)
+	}
+}
+
+func TestPostgres_replicas(t *testing.T) {
+	pgStor := newTestPostgres(t)
+	ctx := context.Background()
+
+	// 6. Two replicas share state through the same database
+	var schema string
+	if err := pgStor.db.QueryRowContext(ctx, `SELECT current_schema();`).Scan(&schema); err != nil {
+		t.Fatal(err)
+	}
+	replica, err := New(&Config{DSN: withSearchPath(testDSN, schema), MaxOpenConns: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := replica.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if err := pgStor.IndexOffer(ctx, map[string][]string{"topic": {"hub"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pgStor.NewCallback(ctx, "topic", "hub", "callback", "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The replica receives the hub's verification
+	if err := replica.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if cb, err := pgStor.GetActiveCallback(ctx, "topic", "hub"); err != nil || cb != "callback" {
+		t.Fatalf("Expected the replica's verification to be visible, but got {%v, %v}", cb, err)
+	}
+}
+
+func TestPostgres_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		pgStor, err := New(&Config{DSN: newTestSchema(t), MaxOpenConns: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pgStor
+	})
+}
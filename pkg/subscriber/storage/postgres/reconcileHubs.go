@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// ReconcileHubs brings the hubs that offer the topic in line with a fresh discovery of it, and records the change.
+// Added hubs are indexed as offers (or offered once again, if they were withdrawn), and removed hubs have their
+// offers withdrawn. Withdrawn offers keep their subscriptions, so that the hubs can still verify our unsubscriptions.
+func (pgStor *Postgres) ReconcileHubs(ctx context.Context, topic string, added, removed []string) (err error) {
+	if topic == "" {
+		return ErrMalformedTopic
+	}
+
+	tx, err := pgStor.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+
+	// Defer a rollback, if an error is encountered
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, hub := range added {
+		if hub == "" {
+			return ErrMalformedHub
+		}
+
+		if _, err = tx.ExecContext(ctx, `
+			INSERT INTO offered_subscriptions (topic_url, hub_url)
+			VALUES ($1, $2)
+			ON CONFLICT (topic_url, hub_url) DO UPDATE
+			SET withdrawn_at=NULL;`,
+			topic,
+			hub,
+		); err != nil {
+			return err
+		}
+
+		if err = recordHubChange(ctx, tx, topic, hub, subscriberpb.HubChangeKind_HubAdded); err != nil {
+			return err
+		}
+	}
+
+	for _, hub := range removed {
+		var res sql.Result
+		res, err = tx.ExecContext(ctx, `
+			UPDATE offered_subscriptions
+			SET withdrawn_at=now()
+			WHERE topic_url=$1 AND hub_url=$2 AND withdrawn_at IS NULL;`,
+			topic,
+			hub,
+		)
+		if err != nil {
+			return err
+		}
+		if err = exactlyOne(res); err != nil {
+			return err
+		}
+
+		if err = recordHubChange(ctx, tx, topic, hub, subscriberpb.HubChangeKind_HubRemoved); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// recordHubChange adds a change to the topic's hub history
+func recordHubChange(ctx context.Context, tx *sql.Tx, topic, hub string, kind subscriberpb.HubChangeKind) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO hub_changes (topic_url, hub_url, kind)
+		VALUES ($1, $2, $3);`,
+		topic,
+		hub,
+		kind.String(),
+	)
+	return err
+}
+
+// GetHubChanges returns the history of hubs being added to and removed from the topic, oldest first
+func (pgStor *Postgres) GetHubChanges(ctx context.Context, topic string) (*subscriberpb.HubChanges, error) {
+	rows, err := pgStor.db.QueryContext(ctx, `
+		SELECT hub_url, kind, changed_at
+		FROM hub_changes
+		WHERE topic_url=$1
+		ORDER BY change_id;`,
+		topic,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := &subscriberpb.HubChanges{}
+	for rows.Next() {
+		var hub, kind string
+		var changedAt time.Time
+		if err := rows.Scan(&hub, &kind, &changedAt); err != nil {
+			return nil, err
+		}
+
+		changes.HubChanges = append(changes.HubChanges, &subscriberpb.HubChange{
+			Topic:     topic,
+			Hub:       hub,
+			Kind:      subscriberpb.HubChangeKind(subscriberpb.HubChangeKind_value[kind]),
+			ChangedAt: changedAt.Unix(),
+		})
+	}
+
+	return changes, rows.Err()
+}
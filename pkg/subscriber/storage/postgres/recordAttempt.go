@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// RecordAttempt records the outcome of the latest request made to the hub about the topic.
+// The attempt is the number of consecutive tries that the request has taken so far,
+// and lastError is empty if the latest try succeeded.
+func (pgStor *Postgres) RecordAttempt(ctx context.Context, topic, hub string, attempt int, lastError string) error {
+	res, err := pgStor.db.ExecContext(ctx, `
+		UPDATE offered_subscriptions
+		SET attempts=$1, last_error=$2, last_attempt=now()
+		WHERE topic_url=$3 AND hub_url=$4;`,
+		attempt,
+		sql.NullString{String: lastError, Valid: lastError != ""},
+		topic,
+		hub,
+	)
+	if err != nil {
+		return err
+	}
+
+	return exactlyOne(res)
+}
+
+// GetAttempts returns the number of tries taken by the latest request made to the hub about the topic,
+// and the error that its last try ended with (if any).
+func (pgStor *Postgres) GetAttempts(ctx context.Context, topic, hub string) (attempts int, lastError string, err error) {
+	row := pgStor.db.QueryRowContext(ctx, `
+		SELECT attempts, last_error
+		FROM offered_subscriptions
+		WHERE topic_url=$1 AND hub_url=$2;`,
+		topic,
+		hub,
+	)
+
+	var le sql.NullString
+	if err := row.Scan(&attempts, &le); err != nil {
+		return 0, "", err
+	}
+
+	return attempts, le.String, nil
+}
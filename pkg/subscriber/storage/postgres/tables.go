@@ -0,0 +1,69 @@
+package postgres
+
+// schemaLock is the key of the advisory lock that is held while the schema is created.
+// Replicas that start together would otherwise trip over each other's CREATE ... IF NOT EXISTS statements.
+const schemaLock = 0x77656273756221
+
+// Urls are collated byte-wise ("C"), so that keyset pagination walks them in the same order as the sqlite3 backend.
+const (
+	offeredSubscriptionsTable = `
+		CREATE TABLE IF NOT EXISTS offered_subscriptions (
+			topic_url TEXT COLLATE "C" NOT NULL,
+			hub_url TEXT COLLATE "C" NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT DEFAULT NULL,
+			last_attempt TIMESTAMPTZ DEFAULT NULL,
+			withdrawn_at TIMESTAMPTZ DEFAULT NULL,
+
+			PRIMARY KEY (topic_url, hub_url));`
+
+	subscriptionTable = `
+		CREATE TABLE IF NOT EXISTS subscriptions (
+			topic_url TEXT COLLATE "C" NOT NULL,
+			hub_url TEXT COLLATE "C" NOT NULL,
+			callback_url TEXT NOT NULL,
+			lease_expiration TIMESTAMPTZ DEFAULT NULL,
+			lease_initiated TIMESTAMPTZ DEFAULT NULL,
+			inactive_reason TEXT DEFAULT NULL,
+			secret TEXT DEFAULT NULL,
+			requested_lease_seconds BIGINT DEFAULT NULL,
+			granted_lease_seconds BIGINT DEFAULT NULL,
+
+			CHECK (
+				lease_expiration IS NULL
+				OR lease_expiration > lease_initiated
+			),
+			UNIQUE (topic_url, hub_url),
+			FOREIGN KEY (topic_url, hub_url) REFERENCES offered_subscriptions (topic_url, hub_url),
+			PRIMARY KEY (callback_url));`
+
+	hubChangesTable = `
+		CREATE TABLE IF NOT EXISTS hub_changes (
+			change_id BIGSERIAL PRIMARY KEY,
+			topic_url TEXT COLLATE "C" NOT NULL,
+			hub_url TEXT COLLATE "C" NOT NULL,
+			kind TEXT NOT NULL,
+			changed_at TIMESTAMPTZ NOT NULL DEFAULT now());`
+
+	hubChangesIndex = `
+		CREATE INDEX IF NOT EXISTS hub_changes_by_topic ON hub_changes (topic_url, change_id);`
+
+	activeView = `
+		CREATE OR REPLACE VIEW active_subscriptions AS
+		SELECT topic_url, hub_url, callback_url, lease_expiration, lease_initiated, inactive_reason,
+			requested_lease_seconds, granted_lease_seconds
+		FROM subscriptions
+		WHERE
+			lease_expiration IS NOT NULL
+			AND now() < lease_expiration;`
+
+	inactiveView = `
+		CREATE OR REPLACE VIEW inactive_subscriptions AS
+		SELECT topic_url, hub_url, callback_url, lease_expiration, inactive_reason, requested_lease_seconds
+		FROM offered_subscriptions
+		LEFT OUTER JOIN subscriptions
+		USING (topic_url, hub_url)
+		WHERE withdrawn_at IS NULL AND (
+			lease_expiration IS NULL
+			OR lease_expiration <= now());`
+)
@@ -0,0 +1,47 @@
+//go:build cgo
+// +build cgo
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// copyDatabase copies the entire contents of one database into another, using SQLite3's online backup API
+func copyDatabase(dest, src *sql.DB) error {
+	ctx := context.Background()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			backup, err := destDriverConn.(*sqlite3.SQLiteConn).Backup("main", srcDriverConn.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+
+			// Step returns false without an error while the source is busy, so keep at it until it's done
+			for done := false; !done; {
+				if done, err = backup.Step(-1); err != nil {
+					backup.Finish()
+					return err
+				}
+			}
+
+			return backup.Finish()
+		})
+	})
+}
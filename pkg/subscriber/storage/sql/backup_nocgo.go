@@ -0,0 +1,14 @@
+//go:build !cgo
+// +build !cgo
+
+package sql
+
+import (
+	"database/sql"
+)
+
+// copyDatabase can't reach SQLite3's online backup API without cgo, and neither can the sqlite3 driver open a
+// database to copy, so it always fails
+func copyDatabase(dest, src *sql.DB) error {
+	return ErrNoCgo
+}
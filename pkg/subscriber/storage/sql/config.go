@@ -2,11 +2,11 @@ package sql
 
 // Config is the configuration for the storage object
 type Config struct {
-	DSN string // the 'data source name', which the sqlite3 client uses to connect
+	DSN string // the 'data source name', which the sqlite3 client uses to connect (ignored when PersistActive is set)
 
-	PersistActive     bool   // determines whether SQLite3 should periodically write to disk
+	PersistActive     bool   // determines whether SQLite3 should write every change straight to the DataSource file
 	DataSource        string // path to SQLite3 .db file
-	PersistOnShutdown bool   // determines whether SQLite3 should write to disk on shutdown (or wipe on shutdown)
+	PersistOnShutdown bool   // determines whether SQLite3 should load from the DataSource file at birth, and write to it on shutdown (or wipe on shutdown)
 }
 
 // NewConfig returns the default Config (foreign keys on, database in-memory only)
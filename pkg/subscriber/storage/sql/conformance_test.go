@@ -0,0 +1,18 @@
+package sql
+
+import (
+	"testing"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage/storagetest"
+)
+
+func TestSQL_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		sqlStor, err := New(NewConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sqlStor
+	})
+}
@@ -15,6 +15,12 @@ var (
 
 	// ErrMalformedInactiveReason is returned when a subscription is ended without a reason
 	ErrMalformedInactiveReason = errors.New("SQL storage: inactive reason provided is invalid, subscription was not killed")
+
+	// ErrMissingDataSource is returned when persistence is configured, but there is no file to persist to
+	ErrMissingDataSource = errors.New("SQL storage: persistence requires a DataSource, storage was not created")
+
+	// ErrNoCgo is returned when storage is persisted by a binary built without cgo, which sqlite3 requires
+	ErrNoCgo = errors.New("SQL storage: sqlite3 requires cgo, storage was not persisted")
 )
 
 // ErrUpdateFailed is returned when an update fails to touch exactly one row
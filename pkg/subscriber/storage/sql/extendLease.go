@@ -8,6 +8,7 @@ import (
 
 // ExtendLease provides a subscription lease to a given callback.  Implicitly, this means that the subscription is active.
 // This occurs when a subscription is first ACK'd, and also upon subsequent lease renewals.
+// The time remaining until newExpiration is recorded as the lease that the hub granted.
 func (sqlStor *SQL) ExtendLease(ctx context.Context, callback string, newExpiration time.Time) (err error) {
 	if newExpiration.Before(time.Now()) {
 		return ErrNewLeaseInPast{newExpiration}
@@ -27,7 +28,7 @@ func (sqlStor *SQL) ExtendLease(ctx context.Context, callback string, newExpirat
 
 	res, err := tx.ExecContext(ctx, `
 		UPDATE subscriptions
-		SET lease_expiration=?, lease_initiated=(
+		SET lease_expiration=?, granted_lease_seconds=?, lease_initiated=(
 			CASE
 				WHEN lease_initiated IS NULL
 				THEN ?
@@ -41,6 +42,7 @@ func (sqlStor *SQL) ExtendLease(ctx context.Context, callback string, newExpirat
 				  lease_expiration IS NOT NULL
 			    AND datetime('now') < datetime(lease_expiration)));`,
 		newExpiration.UTC().Format(sqliteTimeFmt),
+		int64(time.Until(newExpiration).Round(time.Second)/time.Second),
 		time.Now().UTC().Format(sqliteTimeFmt),
 		callback,
 	)
@@ -36,11 +36,11 @@ func TestSQL_ExtendLease(t *testing.T) {
 		}
 	}()
 
-	sqlStor.IndexOffer(map[string]string{
-		"topic": "hub",
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic": {"hub"},
 	})
 
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -71,11 +71,11 @@ func TestSQL_ExtendLease_SimpleErr(t *testing.T) {
 		}
 	}()
 
-	sqlStor.IndexOffer(map[string]string{
-		"topic": "hub",
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic": {"hub"},
 	})
 
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -99,7 +99,7 @@ func TestSQL_ExtendLease_SimpleErr(t *testing.T) {
 	}
 
 	// 3. Context expends during lease extension
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "fresh_callback")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "fresh_callback", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -132,11 +132,11 @@ func TestSQL_ExtendLease_ComplexErr(t *testing.T) {
 		}
 	}()
 
-	sqlStor.IndexOffer(map[string]string{
-		"topic": "hub",
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic": {"hub"},
 	})
 
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
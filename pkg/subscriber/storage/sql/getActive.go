@@ -1,6 +1,8 @@
 package sql
 
 import (
+	"context"
+	"database/sql"
 	"time"
 
 	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
@@ -8,9 +10,11 @@ import (
 
 // GetActive returns at most 'pageSize' active subscriptions in alphabetical order.
 // If there are more than 'pageSize', the caller can use 'pageNum' to ask for a specific partition in the sequence.
-func (sql *SQL) GetActive(pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error) {
-	rows, err := sql.db.Query(`
-		SELECT topic_url, hub_url, callback_url, lease_initiated
+// Leases that were not requested are reported as zero seconds.
+func (sqlStor *SQL) GetActive(ctx context.Context, pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error) {
+	rows, err := sqlStor.db.QueryContext(ctx, `
+		SELECT topic_url, hub_url, callback_url, lease_expiration, lease_initiated,
+			requested_lease_seconds, granted_lease_seconds
 		FROM active_subscriptions
 		WHERE (topic_url, hub_url) > (?, ?)
 		ORDER BY topic_url, hub_url
@@ -19,21 +23,27 @@ func (sql *SQL) GetActive(pageSize int, lastTopic, lastHub string) (subs *subscr
 		lastHub,
 		pageSize,
 	)
-	defer rows.Close()
 	if err != nil {
 		return nil, false, err
 	}
+	defer rows.Close()
 
 	subs = &subscriberpb.Subscriptions{}
 
 	rowCtr := 0
 	for rows.Next() {
-		var topic, hub, callback, leaseInitiated string
-		err = rows.Scan(&topic, &hub, &callback, &leaseInitiated)
+		var topic, hub, callback, leaseExpiration, leaseInitiated string
+		var requested, granted sql.NullInt64
+		err = rows.Scan(&topic, &hub, &callback, &leaseExpiration, &leaseInitiated, &requested, &granted)
 		if err != nil {
 			return nil, false, err
 		}
 
+		expiration, err := time.Parse(sqliteTimeFmt, leaseExpiration)
+		if err != nil {
+			return nil, false, ErrMalformedTime{leaseExpiration}
+		}
+
 		lease, err := time.Parse(sqliteTimeFmt, leaseInitiated)
 		if err != nil {
 			return nil, false, ErrMalformedTime{leaseInitiated}
@@ -43,10 +53,14 @@ func (sql *SQL) GetActive(pageSize int, lastTopic, lastHub string) (subs *subscr
 		subs.Subscriptions = append(
 			subs.Subscriptions,
 			&subscriberpb.Subscription{
-				Callback:       callback,
-				Topic:          topic,
-				Hub:            hub,
-				LeaseInitiated: lease.Unix(),
+				Callback:        callback,
+				Topic:           topic,
+				Hub:             hub,
+				LeaseExpiration: expiration.Unix(),
+				LeaseInitiated:  lease.Unix(),
+
+				RequestedLeaseSeconds: requested.Int64,
+				GrantedLeaseSeconds:   granted.Int64,
 			},
 		)
 		rowCtr++
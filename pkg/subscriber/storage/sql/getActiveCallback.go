@@ -1,8 +1,12 @@
 package sql
 
+import (
+	"context"
+)
+
 // GetActiveCallback returns a callback if a given topic+hub combination exists
-func (sql *SQL) GetActiveCallback(topic, hub string) (string, error) {
-	row := sql.db.QueryRow(`
+func (sql *SQL) GetActiveCallback(ctx context.Context, topic, hub string) (string, error) {
+	row := sql.db.QueryRowContext(ctx, `
 		SELECT callback_url
 		FROM active_subscriptions
 		WHERE topic_url == ? AND hub_url == ?;`,
@@ -30,18 +30,18 @@ func TestSQL_GetActive_PagingTest(t *testing.T) {
 		}
 	}()
 
-	topicsToHubs := make(map[string]string)
+	topicsToHubs := make(map[string][]string)
 	for idx := 1000; idx < 2000; idx++ {
-		topicsToHubs[fmt.Sprintf("topic_num%d", idx)] = fmt.Sprintf("hub_num%d", idx)
+		topicsToHubs[fmt.Sprintf("topic_num%d", idx)] = []string{fmt.Sprintf("hub_num%d", idx)}
 	}
-	err = sqlStor.IndexOffer(topicsToHubs)
+	err = sqlStor.IndexOffer(context.Background(), topicsToHubs)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Launch, mark active
 	for idx := 1000; idx < 2000; idx++ {
-		err := sqlStor.NewCallback(context.Background(), fmt.Sprintf("topic_num%d", idx), fmt.Sprintf("hub_num%d", idx), fmt.Sprintf("cb_num%d", idx))
+		err := sqlStor.NewCallback(context.Background(), fmt.Sprintf("topic_num%d", idx), fmt.Sprintf("hub_num%d", idx), fmt.Sprintf("cb_num%d", idx), "", 0)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -53,7 +53,7 @@ func TestSQL_GetActive_PagingTest(t *testing.T) {
 	}
 
 	// Get active ones
-	subs, last, err := sqlStor.GetActive(50, "topic_num1000", "hub_num1000")
+	subs, last, err := sqlStor.GetActive(context.Background(), 50, "topic_num1000", "hub_num1000")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -87,20 +87,20 @@ func TestSQL_GetActive_Identification(t *testing.T) {
 		}
 	}()
 
-	err = sqlStor.IndexOffer(map[string]string{
-		"topic": "hub",
+	err = sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic": {"hub"},
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// 1. NewCallback fails
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	subs, last, err := sqlStor.GetActive(10, "", "")
+	subs, last, err := sqlStor.GetActive(context.Background(), 10, "", "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -117,7 +117,7 @@ func TestSQL_GetActive_Identification(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	subs, last, err = sqlStor.GetActive(10, "", "")
+	subs, last, err = sqlStor.GetActive(context.Background(), 10, "", "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -130,7 +130,7 @@ func TestSQL_GetActive_Identification(t *testing.T) {
 
 	// 3. NewCallback + ExtendLease + Time passes fails
 	time.Sleep(time.Second)
-	subs, last, err = sqlStor.GetActive(10, "", "")
+	subs, last, err = sqlStor.GetActive(context.Background(), 10, "", "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -142,7 +142,7 @@ func TestSQL_GetActive_Identification(t *testing.T) {
 	}
 
 	// 4. NewCallback + ExtendLease + Invalidate fails
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "newCallback")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "newCallback", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -157,7 +157,7 @@ func TestSQL_GetActive_Identification(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	subs, last, err = sqlStor.GetActive(10, "", "")
+	subs, last, err = sqlStor.GetActive(context.Background(), 10, "", "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -169,7 +169,7 @@ func TestSQL_GetActive_Identification(t *testing.T) {
 	}
 
 	// 5. NewCallback + Invalidate fails
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "newestCallback")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "newestCallback", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -179,7 +179,7 @@ func TestSQL_GetActive_Identification(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	subs, last, err = sqlStor.GetActive(10, "", "")
+	subs, last, err = sqlStor.GetActive(context.Background(), 10, "", "")
 	if err != nil {
 		t.Fatal(err)
 	}
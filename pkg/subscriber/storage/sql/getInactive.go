@@ -1,16 +1,18 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
 )
 
 // GetInactive returns at most 'pageSize' inactive topic/hub tuples in alphabetical order.
 // If there are more than 'pageSize', the caller can use 'lastTopic' to ask for the next 'pageSize' topic/hub tuples.
-func (sqlStor *SQL) GetInactive(pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error) {
-	rows, err := sqlStor.db.Query(`
-		SELECT topic_url, hub_url, callback_url
+func (sqlStor *SQL) GetInactive(ctx context.Context, pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error) {
+	rows, err := sqlStor.db.QueryContext(ctx, `
+		SELECT topic_url, hub_url, callback_url, lease_expiration, inactive_reason, requested_lease_seconds
 		FROM inactive_subscriptions
 		WHERE (topic_url, hub_url) > (?, ?)
 		ORDER BY topic_url, hub_url
@@ -19,10 +21,10 @@ func (sqlStor *SQL) GetInactive(pageSize int, lastTopic, lastHub string) (subs *
 		lastHub,
 		pageSize,
 	)
-	defer rows.Close()
 	if err != nil {
 		return nil, false, err
 	}
+	defer rows.Close()
 
 	subs = &subscriberpb.Subscriptions{}
 
@@ -30,19 +32,34 @@ func (sqlStor *SQL) GetInactive(pageSize int, lastTopic, lastHub string) (subs *
 	rowCtr := 0
 	for rows.Next() {
 		var topic, hub string
-		var callback sql.NullString
-		err = rows.Scan(&topic, &hub, &callback)
+		var callback, leaseExpiration, inactiveReason sql.NullString
+		var requestedLease sql.NullInt64
+		err = rows.Scan(&topic, &hub, &callback, &leaseExpiration, &inactiveReason, &requestedLease)
 		if err != nil {
 			return nil, false, err
 		}
 
+		// Subscriptions that were never ACK'd have no expiration
+		var expiration int64
+		if leaseExpiration.Valid {
+			exp, err := time.Parse(sqliteTimeFmt, leaseExpiration.String)
+			if err != nil {
+				return nil, false, ErrMalformedTime{leaseExpiration.String}
+			}
+			expiration = exp.Unix()
+		}
+
 		// Add info to list
 		subs.Subscriptions = append(
 			subs.Subscriptions,
 			&subscriberpb.Subscription{
-				Topic:    topic,
-				Hub:      hub,
-				Callback: callback.String,
+				Topic:           topic,
+				Hub:             hub,
+				Callback:        callback.String,
+				LeaseExpiration: expiration,
+				InactiveReason:  inactiveReason.String,
+
+				RequestedLeaseSeconds: requestedLease.Int64,
 			},
 		)
 		rowCtr++
@@ -27,7 +27,7 @@ package sql
 // 		t.Fatal(err)
 // 	}
 
-// 	subs, last, err := sql.GetInactive(50, "topic_num1000", "hub_num1000")
+// 	subs, last, err := sql.GetInactive(context.Background(), 50, "topic_num1000", "hub_num1000")
 // 	if err != nil {
 // 		t.Fatal(err)
 // 	}
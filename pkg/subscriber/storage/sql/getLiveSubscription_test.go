@@ -0,0 +1,94 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+/*
+	# Test Cases
+
+	1. Callback awaiting verification is live
+	2. Active callback is live
+	3. Invalidated callback is not live
+	4. Expired callback is not live
+	5. Callback DNE
+*/
+
+func TestSQL_GetLiveSubscription(t *testing.T) {
+	sqlStor, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = sqlStor.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic":  {"hub"},
+		"topic2": {"hub2"},
+	})
+
+	// 1. Callback awaiting verification is live
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := sqlStor.GetLiveSubscription(context.Background(), "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Topic != "topic" || sub.Hub != "hub" || sub.Callback != "callback" {
+		t.Fatalf("Returned the wrong subscription: %+v", sub)
+	}
+
+	// 2. Active callback is live
+	err = sqlStor.ExtendLease(context.Background(), "callback", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sqlStor.GetLiveSubscription(context.Background(), "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3. Invalidated callback is not live
+	err = sqlStor.Invalidate(context.Background(), "callback", "denied")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sqlStor.GetLiveSubscription(context.Background(), "callback")
+	if err != sql.ErrNoRows {
+		t.Fatal(err)
+	}
+
+	// 4. Expired callback is not live
+	err = sqlStor.NewCallback(context.Background(), "topic2", "hub2", "callback2", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sqlStor.ExtendLease(context.Background(), "callback2", time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	_, err = sqlStor.GetLiveSubscription(context.Background(), "callback2")
+	if err != sql.ErrNoRows {
+		t.Fatal(err)
+	}
+
+	// 5. Callback DNE
+	_, err = sqlStor.GetLiveSubscription(context.Background(), "nonexistantcb")
+	if err != sql.ErrNoRows {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,56 @@
+package sql
+
+import (
+	"context"
+)
+
+// GetOfferedTopics returns every topic that is offered by at least one hub, in alphabetical order
+func (sqlStor *SQL) GetOfferedTopics(ctx context.Context) ([]string, error) {
+	rows, err := sqlStor.db.QueryContext(ctx, `
+		SELECT DISTINCT topic_url
+		FROM offered_subscriptions
+		WHERE withdrawn_at IS NULL
+		ORDER BY topic_url;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var topics []string
+	for rows.Next() {
+		var topic string
+		if err := rows.Scan(&topic); err != nil {
+			return nil, err
+		}
+		topics = append(topics, topic)
+	}
+
+	return topics, rows.Err()
+}
+
+// GetOfferedHubs returns the hubs that offer the topic, in alphabetical order.
+// Hubs whose offers were withdrawn are left out.
+func (sqlStor *SQL) GetOfferedHubs(ctx context.Context, topic string) ([]string, error) {
+	rows, err := sqlStor.db.QueryContext(ctx, `
+		SELECT hub_url
+		FROM offered_subscriptions
+		WHERE topic_url=? AND withdrawn_at IS NULL
+		ORDER BY hub_url;`,
+		topic,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hubs []string
+	for rows.Next() {
+		var hub string
+		if err := rows.Scan(&hub); err != nil {
+			return nil, err
+		}
+		hubs = append(hubs, hub)
+	}
+
+	return hubs, rows.Err()
+}
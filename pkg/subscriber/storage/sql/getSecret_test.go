@@ -0,0 +1,66 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+/*
+	# Test Cases
+
+	1. Callback created with a secret
+	2. Callback created without a secret
+	3. Callback DNE
+*/
+
+func TestSQL_GetSecret(t *testing.T) {
+	sqlStor, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = sqlStor.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic":  {"hub"},
+		"topic2": {"hub2"},
+	})
+
+	// 1. Callback created with a secret
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback", "s3cr3t", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := sqlStor.GetSecret(context.Background(), "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != "s3cr3t" {
+		t.Fatalf("Expected secret {s3cr3t} but got {%s}", secret)
+	}
+
+	// 2. Callback created without a secret
+	err = sqlStor.NewCallback(context.Background(), "topic2", "hub2", "callback2", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err = sqlStor.GetSecret(context.Background(), "callback2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != "" {
+		t.Fatalf("Expected no secret but got {%s}", secret)
+	}
+
+	// 3. Callback DNE
+	_, err = sqlStor.GetSecret(context.Background(), "nonexistantcb")
+	if err != sql.ErrNoRows {
+		t.Fatal(err)
+	}
+}
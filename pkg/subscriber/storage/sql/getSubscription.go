@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -8,9 +9,11 @@ import (
 )
 
 // GetSubscription returns any subscription associated with the given callback.
-func (sqlStor *SQL) GetSubscription(callback string) (*subscriberpb.Subscription, error) {
-	row := sqlStor.db.QueryRow(`
-		SELECT topic_url, hub_url, callback_url, lease_expiration, lease_initiated, inactive_reason
+// Leases that were not requested (or that the hub has not granted yet) are reported as zero seconds.
+func (sqlStor *SQL) GetSubscription(ctx context.Context, callback string) (*subscriberpb.Subscription, error) {
+	row := sqlStor.db.QueryRowContext(ctx, `
+		SELECT topic_url, hub_url, callback_url, lease_expiration, lease_initiated, inactive_reason,
+			requested_lease_seconds, granted_lease_seconds
 		FROM active_subscriptions
 		WHERE callback_url == ?;`,
 		callback,
@@ -18,7 +21,8 @@ func (sqlStor *SQL) GetSubscription(callback string) (*subscriberpb.Subscription
 
 	var tp, hb, cb, le, li string
 	var rea sql.NullString
-	if err := row.Scan(&tp, &hb, &cb, &le, &li, &rea); err != nil {
+	var req, gra sql.NullInt64
+	if err := row.Scan(&tp, &hb, &cb, &le, &li, &rea, &req, &gra); err != nil {
 		return nil, err
 	}
 
@@ -39,5 +43,8 @@ func (sqlStor *SQL) GetSubscription(callback string) (*subscriberpb.Subscription
 		LeaseExpiration: exp.Unix(),
 		LeaseInitiated:  init.Unix(),
 		InactiveReason:  rea.String,
+
+		RequestedLeaseSeconds: req.Int64,
+		GrantedLeaseSeconds:   gra.Int64,
 	}, nil
 }
@@ -0,0 +1,81 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+/*
+	# Test Cases
+
+	1. Requested lease is granted in full
+	2. Requested lease is clamped by the hub
+	3. No lease requested
+	4. Callback not yet verified
+*/
+
+func TestSQL_GetSubscription_leases(t *testing.T) {
+	sqlStor, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = sqlStor.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic":  {"hub"},
+		"topic2": {"hub2"},
+		"topic3": {"hub3"},
+		"topic4": {"hub4"},
+	})
+
+	cases := []struct {
+		topic, hub, callback string
+		requested, granted   time.Duration
+	}{
+		// 1. Requested lease is granted in full
+		{"topic", "hub", "callback", time.Hour, time.Hour},
+		// 2. Requested lease is clamped by the hub
+		{"topic2", "hub2", "callback2", time.Hour, 10 * time.Minute},
+		// 3. No lease requested
+		{"topic3", "hub3", "callback3", 0, 24 * time.Hour},
+	}
+
+	for _, c := range cases {
+		err = sqlStor.NewCallback(context.Background(), c.topic, c.hub, c.callback, "", c.requested)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sqlStor.ExtendLease(context.Background(), c.callback, time.Now().Add(c.granted))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sub, err := sqlStor.GetSubscription(context.Background(), c.callback)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub.RequestedLeaseSeconds != int64(c.requested/time.Second) {
+			t.Fatalf("Expected requested lease {%v} but got {%v}", int64(c.requested/time.Second), sub.RequestedLeaseSeconds)
+		}
+		if sub.GrantedLeaseSeconds != int64(c.granted/time.Second) {
+			t.Fatalf("Expected granted lease {%v} but got {%v}", int64(c.granted/time.Second), sub.GrantedLeaseSeconds)
+		}
+	}
+
+	// 4. Callback not yet verified
+	err = sqlStor.NewCallback(context.Background(), "topic4", "hub4", "callback4", "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sqlStor.GetSubscription(context.Background(), "callback4")
+	if err != sql.ErrNoRows {
+		t.Fatalf("Expected {%v} but got {%v}", sql.ErrNoRows, err)
+	}
+}
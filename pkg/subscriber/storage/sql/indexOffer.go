@@ -1,8 +1,13 @@
 package sql
 
-// IndexOffer indexes the topic <-> hub relationship, which is observed in the discovery phase
-func (strg *SQL) IndexOffer(topicsToHubs map[string]string) (err error) {
-	tx, err := strg.db.Begin()
+import (
+	"context"
+)
+
+// IndexOffer indexes the topic <-> hub relationships, which are observed in the discovery phase.
+// Each topic may be offered by any number of hubs. Offers that were withdrawn are offered once again.
+func (strg *SQL) IndexOffer(ctx context.Context, topicsToHubs map[string][]string) (err error) {
+	tx, err := strg.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -14,7 +19,7 @@ func (strg *SQL) IndexOffer(topicsToHubs map[string]string) (err error) {
 		}
 	}()
 
-	stmt, err := tx.Prepare(`
+	stmt, err := tx.PrepareContext(ctx, `
 	INSERT OR IGNORE INTO offered_subscriptions (
 		topic_url, hub_url
 	)
@@ -26,16 +31,30 @@ func (strg *SQL) IndexOffer(topicsToHubs map[string]string) (err error) {
 	}
 	defer stmt.Close()
 
-	for topic, hub := range topicsToHubs {
+	reofferStmt, err := tx.PrepareContext(ctx, `
+	UPDATE offered_subscriptions
+	SET withdrawn_at=NULL
+	WHERE topic_url=? AND hub_url=?;`)
+	if err != nil {
+		return err
+	}
+	defer reofferStmt.Close()
+
+	for topic, hubs := range topicsToHubs {
 		// TODO(adam) better validation
 		if topic == "" {
 			return ErrMalformedTopic
 		}
-		if hub == "" {
-			return ErrMalformedHub
-		}
-		if _, err := stmt.Exec(topic, hub); err != nil {
-			return err
+		for _, hub := range hubs {
+			if hub == "" {
+				return ErrMalformedHub
+			}
+			if _, err := stmt.ExecContext(ctx, topic, hub); err != nil {
+				return err
+			}
+			if _, err := reofferStmt.ExecContext(ctx, topic, hub); err != nil {
+				return err
+			}
 		}
 	}
 	return tx.Commit()
@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"context"
 	"testing"
 )
 
@@ -15,9 +16,9 @@ func TestSQL_IndexNormal(t *testing.T) {
 		}
 	}()
 
-	err = man.IndexOffer(
-		map[string]string{
-			"abc.com/topic": "abc.com/topic_hub",
+	err = man.IndexOffer(context.Background(),
+		map[string][]string{
+			"abc.com/topic": {"abc.com/topic_hub"},
 		})
 	if err != nil {
 		t.Fatal(err)
@@ -35,19 +36,48 @@ func TestSQL_IndexNullElements(t *testing.T) {
 		}
 	}()
 
-	err = man.IndexOffer(
-		map[string]string{
-			"abc.com/topic": "",
+	err = man.IndexOffer(context.Background(),
+		map[string][]string{
+			"abc.com/topic": {""},
 		})
 	if err == nil {
 		t.Fatal("Was able to index a null hub url")
 	}
 
-	err = man.IndexOffer(
-		map[string]string{
-			"": "abc.com/topic_hub_url",
+	err = man.IndexOffer(context.Background(),
+		map[string][]string{
+			"": {"abc.com/topic_hub_url"},
 		})
 	if err == nil {
 		t.Fatal("Was able to index a null topic url")
 	}
 }
+
+func TestSQL_IndexManyHubs(t *testing.T) {
+	man, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = man.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	err = man.IndexOffer(context.Background(),
+		map[string][]string{
+			"abc.com/topic": {"abc.com/hub_b", "abc.com/hub_a", "abc.com/hub_c"},
+			"xyz.com/topic": {"abc.com/hub_a"},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hubs, err := man.GetOfferedHubs(context.Background(), "abc.com/topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hubs) != 3 || hubs[0] != "abc.com/hub_a" || hubs[1] != "abc.com/hub_b" || hubs[2] != "abc.com/hub_c" {
+		t.Fatalf("Expected all three hubs to be offered, but got %v", hubs)
+	}
+}
@@ -33,12 +33,12 @@ func TestSQL_ValidCases(t *testing.T) {
 		}
 	}()
 
-	sqlStor.IndexOffer(map[string]string{
-		"topic": "hub",
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic": {"hub"},
 	})
 
 	// 1. Active subscription invalidated
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -53,13 +53,13 @@ func TestSQL_ValidCases(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = sqlStor.GetSubscription("callback")
+	_, err = sqlStor.GetSubscription(context.Background(), "callback")
 	if err != sql.ErrNoRows {
 		t.Fatal(err)
 	}
 
 	// 2. Inactive, but initiated subscription invalidated
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback2")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback2", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -69,7 +69,7 @@ func TestSQL_ValidCases(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = sqlStor.GetSubscription("callback2")
+	_, err = sqlStor.GetSubscription(context.Background(), "callback2")
 	if err != sql.ErrNoRows {
 		t.Fatal(err)
 	}
@@ -93,11 +93,11 @@ func TestSQL_Invalidate_ErrCases(t *testing.T) {
 	}
 
 	// 2. Repeated invalidation
-	sqlStor.IndexOffer(map[string]string{
-		"topic": "hub",
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic": {"hub"},
 	})
 
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,71 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+)
+
+// MigrateHub moves the topic's offer, and any subscription made through it, from the old hub to the new one.
+// This occurs when the old hub permanently redirects a request about the topic.
+// If the topic was already offered by the new hub, the old hub's subscription replaces the new hub's.
+func (sqlStor *SQL) MigrateHub(ctx context.Context, topic, oldHub, newHub string) (err error) {
+	if newHub == "" {
+		return ErrMalformedHub
+	}
+
+	tx, err := sqlStor.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+
+	// Defer a rollback, if an error is encountered
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO offered_subscriptions
+		(topic_url, hub_url, attempts, last_error, last_attempt)
+		SELECT topic_url, ?, attempts, last_error, last_attempt
+		FROM offered_subscriptions
+		WHERE topic_url=? AND hub_url=?;`,
+		newHub,
+		topic,
+		oldHub,
+	); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET hub_url=?
+		WHERE topic_url=? AND hub_url=?;`,
+		newHub,
+		topic,
+		oldHub,
+	); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM offered_subscriptions
+		WHERE topic_url=? AND hub_url=?;`,
+		topic,
+		oldHub,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrUpdateFailed{n}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,107 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+/*
+	# Test Cases
+
+	1. Active subscription moves to the new hub, along with its offer
+	2. Subscription at a hub that was already offered replaces the new hub's
+	3. Offer DNE
+*/
+
+func TestSQL_MigrateHub(t *testing.T) {
+	sqlStor, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = sqlStor.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic":  {"hub"},
+		"topic2": {"hub"},
+	})
+
+	// 1. Active subscription moves to the new hub, along with its offer
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sqlStor.ExtendLease(context.Background(), "callback", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sqlStor.RecordAttempt(context.Background(), "topic", "hub", 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sqlStor.MigrateHub(context.Background(), "topic", "hub", "new_hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := sqlStor.GetSubscription(context.Background(), "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Hub != "new_hub" {
+		t.Fatalf("Expected hub {new_hub} but got {%v}", sub.Hub)
+	}
+	if attempts, _, err := sqlStor.GetAttempts(context.Background(), "topic", "new_hub"); err != nil || attempts != 2 {
+		t.Fatalf("Expected the offer's 2 attempts to move along, but got {%d} (error {%v})", attempts, err)
+	}
+	if _, _, err := sqlStor.GetAttempts(context.Background(), "topic", "hub"); err != sql.ErrNoRows {
+		t.Fatalf("Expected {%v} but got {%v}", sql.ErrNoRows, err)
+	}
+
+	// Other topics offered by the old hub stay put
+	if _, _, err := sqlStor.GetAttempts(context.Background(), "topic2", "hub"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2. Subscription at a hub that was already offered replaces the new hub's
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic2": {"new_hub"},
+	})
+	err = sqlStor.NewCallback(context.Background(), "topic2", "new_hub", "stale_callback", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sqlStor.NewCallback(context.Background(), "topic2", "hub", "callback2", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sqlStor.MigrateHub(context.Background(), "topic2", "hub", "new_hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sqlStor.ExtendLease(context.Background(), "callback2", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb, err := sqlStor.GetActiveCallback(context.Background(), "topic2", "new_hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cb != "callback2" {
+		t.Fatalf("Expected callback {callback2} but got {%v}", cb)
+	}
+
+	// 3. Offer DNE
+	err = sqlStor.MigrateHub(context.Background(), "topic", "hub", "newer_hub")
+	if _, ok := err.(ErrUpdateFailed); !ok {
+		t.Fatalf("Expected an ErrUpdateFailed, but got {%v}", err)
+	}
+}
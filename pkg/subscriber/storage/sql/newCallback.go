@@ -2,12 +2,15 @@ package sql
 
 import (
 	"context"
+	"time"
 
 	sql "database/sql"
 )
 
-// NewCallback implies that the client is waiting for reply to a sub request on the given callback
-func (sqlStor *SQL) NewCallback(ctx context.Context, topic, hub, callback string) (err error) {
+// NewCallback implies that the client is waiting for reply to a sub request on the given callback.
+// The secret is the hub.secret sent along with the request, or empty if none was sent.
+// The requestedLease is the hub.lease_seconds sent along with the request, or zero if none was sent.
+func (sqlStor *SQL) NewCallback(ctx context.Context, topic, hub, callback, secret string, requestedLease time.Duration) (err error) {
 	if topic == "" {
 		return ErrMalformedTopic
 	}
@@ -28,9 +31,11 @@ func (sqlStor *SQL) NewCallback(ctx context.Context, topic, hub, callback string
 	if _, err := tx.ExecContext(
 		ctx, `
 		INSERT INTO subscriptions 
-		(topic_url, hub_url, callback_url) VALUES 
-		(?,?,?)`,
+		(topic_url, hub_url, callback_url, secret, requested_lease_seconds) VALUES 
+		(?,?,?,?,?)`,
 		topic, hub, callback,
+		sql.NullString{String: secret, Valid: secret != ""},
+		sql.NullInt64{Int64: int64(requestedLease / time.Second), Valid: requestedLease > 0},
 	); err != nil {
 		return err
 	}
@@ -38,17 +38,17 @@ func TestSQL_NewCallback_ValidCases(t *testing.T) {
 	}()
 
 	// 1. Indexed, not yet active
-	sqlStor.IndexOffer(map[string]string{
-		"topic": "hub",
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic": {"hub"},
 	})
 
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb1")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb1", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// 2. New callback endpoint, for a link that is already hot
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb_new")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb_new", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -59,17 +59,17 @@ func TestSQL_NewCallback_ValidCases(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb2") // row with cb1 replaced
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb2", "", 0) // row with cb1 replaced
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// 4. Reusing an old (erased) callback on a separate link
-	sqlStor.IndexOffer(map[string]string{
-		"otherTopic": "newHub",
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"otherTopic": {"newHub"},
 	})
 
-	err = sqlStor.NewCallback(context.Background(), "otherTopic", "newHub", "cb_new")
+	err = sqlStor.NewCallback(context.Background(), "otherTopic", "newHub", "cb_new", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -87,19 +87,19 @@ func TestSQL_NewCallback_ErrorCases(t *testing.T) {
 	}()
 
 	// 1. Unindexed link
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb", "", 0)
 	if sqliteErr, ok := err.(sqlite3.Error); !ok || sqliteErr.ExtendedCode != sqlite3.ErrConstraintForeignKey {
 		t.Fatal(err)
 	}
 
 	// 2. Recycled callback, from hot link
-	sqlStor.IndexOffer(map[string]string{
-		"topic": "hub",
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic": {"hub"},
 	})
-	sqlStor.IndexOffer(map[string]string{
-		"topic2": "hub2",
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic2": {"hub2"},
 	})
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -108,7 +108,7 @@ func TestSQL_NewCallback_ErrorCases(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = sqlStor.NewCallback(context.Background(), "topic2", "hub2", "cb")
+	err = sqlStor.NewCallback(context.Background(), "topic2", "hub2", "cb", "", 0)
 	if sqliteErr, ok := err.(sqlite3.Error); !ok || sqliteErr.ExtendedCode != sqlite3.ErrConstraintPrimaryKey {
 		t.Fatal(err)
 	}
@@ -119,18 +119,18 @@ func TestSQL_NewCallback_ErrorCases(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb")
+	err = sqlStor.NewCallback(context.Background(), "topic", "hub", "cb", "", 0)
 	if sqliteErr, ok := err.(sqlite3.Error); !ok || sqliteErr.ExtendedCode != sqlite3.ErrConstraintPrimaryKey {
 		t.Fatal(err)
 	}
 
 	// 4. Double-dipping
-	err = sqlStor.NewCallback(context.Background(), "topic2", "hub2", "cb2")
+	err = sqlStor.NewCallback(context.Background(), "topic2", "hub2", "cb2", "", 0)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = sqlStor.NewCallback(context.Background(), "topic2", "hub2", "cb2")
+	err = sqlStor.NewCallback(context.Background(), "topic2", "hub2", "cb2", "", 0)
 	if sqliteErr, ok := err.(sqlite3.Error); !ok || sqliteErr.ExtendedCode != sqlite3.ErrConstraintPrimaryKey {
 		t.Fatal(err)
 	}
@@ -0,0 +1,32 @@
+package sql
+
+import (
+	"database/sql"
+)
+
+// fileDSN returns the data source name of the SQLite3 .db file at the given path
+func fileDSN(path string) string {
+	return "file:" + path + "?_fk=yes"
+}
+
+// load copies the contents of the SQLite3 .db file at the given path into db
+func load(db *sql.DB, path string) error {
+	file, err := sql.Open("sqlite3", fileDSN(path))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return copyDatabase(db, file)
+}
+
+// flush copies the contents of db into the SQLite3 .db file at the given path, replacing whatever was there
+func flush(db *sql.DB, path string) error {
+	file, err := sql.Open("sqlite3", fileDSN(path))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return copyDatabase(file, db)
+}
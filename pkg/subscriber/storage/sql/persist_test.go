@@ -0,0 +1,103 @@
+package sql
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/*
+	# Test Cases
+
+	1. Active persistence survives a restart
+	2. Shutdown persistence survives a restart
+	3. Persistence without a DataSource
+*/
+
+func TestSQL_Persistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "websub-sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		cfg  *Config
+	}{
+		{"Active persistence", &Config{PersistActive: true, DataSource: filepath.Join(dir, "active.db")}},
+		{"Shutdown persistence", &Config{DSN: ":memory:?_fk=yes", PersistOnShutdown: true, DataSource: filepath.Join(dir, "shutdown.db")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sqlStor, err := New(test.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = sqlStor.IndexOffer(context.Background(), map[string][]string{
+				"topic": {"hub"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = sqlStor.NewCallback(context.Background(), "topic", "hub", "callback", "secret", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = sqlStor.ExtendLease(context.Background(), "callback", time.Now().Add(time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = sqlStor.Shutdown(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Pick up where we left off
+			sqlStor, err = New(test.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if err = sqlStor.Shutdown(); err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			sub, err := sqlStor.GetSubscription(context.Background(), "callback")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sub.Topic != "topic" || sub.Hub != "hub" {
+				t.Fatalf("Restored the wrong subscription: %+v", sub)
+			}
+
+			secret, err := sqlStor.GetSecret(context.Background(), "callback")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if secret != "secret" {
+				t.Fatalf("Expected secret {secret} but got {%s}", secret)
+			}
+		})
+	}
+}
+
+func TestSQL_Persistence_MissingDataSource(t *testing.T) {
+	_, err := New(&Config{DSN: ":memory:?_fk=yes", PersistOnShutdown: true})
+	if err != ErrMissingDataSource {
+		t.Fatalf("Expected {%v} but got {%v}", ErrMissingDataSource, err)
+	}
+
+	_, err = New(&Config{PersistActive: true})
+	if err != ErrMissingDataSource {
+		t.Fatalf("Expected {%v} but got {%v}", ErrMissingDataSource, err)
+	}
+}
@@ -0,0 +1,122 @@
+package sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+/*
+	# Test Cases
+
+	1. Hubs are added and removed, and the changes recorded
+	2. Withdrawn offers keep their subscriptions, but are no longer inactive
+	3. Withdrawn hubs can be added back
+	4. Removing a hub that doesn't offer the topic fails, and changes nothing
+	5. Topics without offers are not listed
+*/
+
+func TestSQL_ReconcileHubs(t *testing.T) {
+	sqlStor, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = sqlStor.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	ctx := context.Background()
+	expectHubs := func(expected ...string) {
+		t.Helper()
+		hubs, err := sqlStor.GetOfferedHubs(context.Background(), "topic")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(hubs, expected) {
+			t.Fatalf("Expected hubs %v but got %v", expected, hubs)
+		}
+	}
+
+	sqlStor.IndexOffer(context.Background(), map[string][]string{"topic": {"hubA"}})
+	if err := sqlStor.NewCallback(ctx, "topic", "hubA", "cbA", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlStor.ExtendLease(ctx, "cbA", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1. Hubs are added and removed, and the changes recorded
+	if err := sqlStor.ReconcileHubs(ctx, "topic", []string{"hubB", "hubC"}, []string{"hubA"}); err != nil {
+		t.Fatal(err)
+	}
+	expectHubs("hubB", "hubC")
+
+	changes, err := sqlStor.GetHubChanges(context.Background(), "topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		hub  string
+		kind subscriberpb.HubChangeKind
+	}{
+		{"hubB", subscriberpb.HubChangeKind_HubAdded},
+		{"hubC", subscriberpb.HubChangeKind_HubAdded},
+		{"hubA", subscriberpb.HubChangeKind_HubRemoved},
+	}
+	if len(changes.HubChanges) != len(expected) {
+		t.Fatalf("Expected %d changes but got %v", len(expected), changes.HubChanges)
+	}
+	for idx, change := range changes.HubChanges {
+		if change.Hub != expected[idx].hub || change.Kind != expected[idx].kind || change.Topic != "topic" {
+			t.Fatalf("Expected change %d to be %v of {%v}, but got %v", idx, expected[idx].kind, expected[idx].hub, change)
+		}
+		if time.Since(time.Unix(change.ChangedAt, 0)) > time.Minute {
+			t.Fatalf("Unexpected change time {%v}", time.Unix(change.ChangedAt, 0))
+		}
+	}
+
+	// 2. Withdrawn offers keep their subscriptions, but are no longer inactive
+	if _, err := sqlStor.GetActiveCallback(context.Background(), "topic", "hubA"); err != nil {
+		t.Fatal(err)
+	}
+	inactive, _, err := sqlStor.GetInactive(context.Background(), 10, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range inactive.Subscriptions {
+		if s.Hub == "hubA" {
+			t.Fatal("Expected the withdrawn offer to be left out of the inactive subscriptions")
+		}
+	}
+
+	// 3. Withdrawn hubs can be added back
+	if err := sqlStor.ReconcileHubs(ctx, "topic", []string{"hubA"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectHubs("hubA", "hubB", "hubC")
+
+	// 4. Removing a hub that doesn't offer the topic fails, and changes nothing
+	err = sqlStor.ReconcileHubs(ctx, "topic", nil, []string{"hubB", "hubD"})
+	if _, ok := err.(ErrUpdateFailed); !ok {
+		t.Fatalf("Expected {%v} but got {%v}", ErrUpdateFailed{0}, err)
+	}
+	expectHubs("hubA", "hubB", "hubC")
+
+	// 5. Topics without offers are not listed
+	sqlStor.IndexOffer(context.Background(), map[string][]string{"other": {"hubA"}})
+	if err := sqlStor.ReconcileHubs(ctx, "other", nil, []string{"hubA"}); err != nil {
+		t.Fatal(err)
+	}
+	topics, err := sqlStor.GetOfferedTopics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(topics, []string{"topic"}) {
+		t.Fatalf("Expected topics [topic] but got %v", topics)
+	}
+}
@@ -0,0 +1,53 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+)
+
+// RecordAttempt records the outcome of the latest request made to the hub about the topic.
+// The attempt is the number of consecutive tries that the request has taken so far,
+// and lastError is empty if the latest try succeeded.
+func (sqlStor *SQL) RecordAttempt(ctx context.Context, topic, hub string, attempt int, lastError string) error {
+	res, err := sqlStor.db.ExecContext(ctx, `
+		UPDATE offered_subscriptions
+		SET attempts=?, last_error=?, last_attempt=datetime('now')
+		WHERE topic_url=? AND hub_url=?;`,
+		attempt,
+		sql.NullString{String: lastError, Valid: lastError != ""},
+		topic,
+		hub,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrUpdateFailed{n}
+	}
+
+	return nil
+}
+
+// GetAttempts returns the number of tries taken by the latest request made to the hub about the topic,
+// and the error that its last try ended with (if any).
+func (sqlStor *SQL) GetAttempts(ctx context.Context, topic, hub string) (attempts int, lastError string, err error) {
+	row := sqlStor.db.QueryRowContext(ctx, `
+		SELECT attempts, last_error
+		FROM offered_subscriptions
+		WHERE topic_url=? AND hub_url=?;`,
+		topic,
+		hub,
+	)
+
+	var le sql.NullString
+	if err := row.Scan(&attempts, &le); err != nil {
+		return 0, "", err
+	}
+
+	return attempts, le.String, nil
+}
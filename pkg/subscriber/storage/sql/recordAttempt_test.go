@@ -0,0 +1,77 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+/*
+	# Test Cases
+
+	1. Offer that was never requested
+	2. Failed attempts are counted, and their error kept
+	3. Successful attempt clears the error
+	4. Offer DNE
+*/
+
+func TestSQL_RecordAttempt(t *testing.T) {
+	sqlStor, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = sqlStor.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	sqlStor.IndexOffer(context.Background(), map[string][]string{
+		"topic": {"hub"},
+	})
+
+	// 1. Offer that was never requested
+	attempts, lastError, err := sqlStor.GetAttempts(context.Background(), "topic", "hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 0 || lastError != "" {
+		t.Fatalf("Expected no attempts, but got {%d} attempts with error {%s}", attempts, lastError)
+	}
+
+	// 2. Failed attempts are counted, and their error kept
+	for attempt := 1; attempt <= 3; attempt++ {
+		if err := sqlStor.RecordAttempt(context.Background(), "topic", "hub", attempt, "hub unavailable"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attempts, lastError, err = sqlStor.GetAttempts(context.Background(), "topic", "hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || lastError != "hub unavailable" {
+		t.Fatalf("Expected 3 attempts with error {hub unavailable}, but got {%d} attempts with error {%s}", attempts, lastError)
+	}
+
+	// 3. Successful attempt clears the error
+	if err := sqlStor.RecordAttempt(context.Background(), "topic", "hub", 4, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, lastError, err = sqlStor.GetAttempts(context.Background(), "topic", "hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 4 || lastError != "" {
+		t.Fatalf("Expected 4 attempts without an error, but got {%d} attempts with error {%s}", attempts, lastError)
+	}
+
+	// 4. Offer DNE
+	if _, ok := sqlStor.RecordAttempt(context.Background(), "topic", "other_hub", 1, "").(ErrUpdateFailed); !ok {
+		t.Fatal("Expected recording an attempt on a missing offer to fail")
+	}
+	if _, _, err := sqlStor.GetAttempts(context.Background(), "topic", "other_hub"); err != sql.ErrNoRows {
+		t.Fatalf("Expected {%v} but got {%v}", sql.ErrNoRows, err)
+	}
+}
@@ -3,26 +3,49 @@ package sql
 import (
 	"database/sql"
 
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
 	_ "github.com/mattn/go-sqlite3" // Implementation of sqlite3 driver
 )
 
+// SQL must implement the subscriber's Storage interface
+var _ storage.Storage = (*SQL)(nil)
+
 // SQL is a sqlite3 implementation of the subscriber's Storage interface
 type SQL struct {
 	db *sql.DB
 
-	/* These do not do anything yet!! */
-	persistActive     bool   // determines whether SQLite3 should periodically write to disk
+	persistActive     bool   // determines whether SQLite3 writes every change straight to disk
 	dataSource        string // path to SQLite3 .db file
 	persistOnShutdown bool   // determines whether SQLite3 should write to disk on shutdown (or wipe on shutdown)
 }
 
-// New creates a new sqlite3 storage object, and returns it
+// New creates a new sqlite3 storage object, and returns it.
+// If the config asks for persistence, the storage object picks up wherever the DataSource file left off.
 func New(cfg *Config) (*SQL, error) {
-	db, err := sql.Open("sqlite3", cfg.DSN)
+	if (cfg.PersistActive || cfg.PersistOnShutdown) && cfg.DataSource == "" {
+		return nil, ErrMissingDataSource
+	}
+
+	dsn := cfg.DSN
+	if cfg.PersistActive {
+		dsn = fileDSN(cfg.DataSource)
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
 	}
 
+	// Every connection to an in-memory database gets a database of its own,
+	// and concurrent writers to a file trip over each other's locks, so we stick to one connection.
+	db.SetMaxOpenConns(1)
+
+	if cfg.PersistOnShutdown && !cfg.PersistActive {
+		if err = load(db, cfg.DataSource); err != nil {
+			return nil, err
+		}
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
@@ -35,6 +58,9 @@ func New(cfg *Config) (*SQL, error) {
 	if _, err = tx.Exec(offeredSubscriptionsTable); err != nil {
 		return nil, err
 	}
+	if _, err = tx.Exec(hubChangesTable); err != nil {
+		return nil, err
+	}
 	if _, err = tx.Exec(activeView); err != nil {
 		return nil, err
 	}
@@ -54,7 +80,14 @@ func New(cfg *Config) (*SQL, error) {
 	}, nil
 }
 
-// Shutdown closes the database
-func (sql *SQL) Shutdown() error {
-	return sql.db.Close()
+// Shutdown closes the database, flushing it to disk first if it was configured to persist on shutdown
+func (sqlStor *SQL) Shutdown() error {
+	if sqlStor.persistOnShutdown && !sqlStor.persistActive {
+		if err := flush(sqlStor.db, sqlStor.dataSource); err != nil {
+			sqlStor.db.Close()
+			return err
+		}
+	}
+
+	return sqlStor.db.Close()
 }
@@ -10,6 +10,9 @@ const (
 			lease_expiration TEXT DEFAULT NULL,
 			lease_initiated TEXT DEFAULT NULL,
 			inactive_reason TEXT DEFAULT NULL,
+			secret TEXT DEFAULT NULL,
+			requested_lease_seconds INTEGER DEFAULT NULL,
+			granted_lease_seconds INTEGER DEFAULT NULL,
 			
 			CHECK (
 				lease_expiration IS NULL
@@ -23,14 +26,28 @@ const (
 		CREATE TABLE IF NOT EXISTS offered_subscriptions (
 			topic_url TEXT NOT NULL,
 			hub_url TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT DEFAULT NULL,
+			last_attempt TEXT DEFAULT NULL,
+			withdrawn_at TEXT DEFAULT NULL,
 		
 			PRIMARY KEY (topic_url, hub_url));`
 
+	hubChangesTable = `
+		CREATE TABLE IF NOT EXISTS hub_changes (
+			change_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			topic_url TEXT NOT NULL,
+			hub_url TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			changed_at TEXT NOT NULL DEFAULT (datetime('now')));`
+
 	activeView = `
 		CREATE VIEW IF NOT EXISTS active_subscriptions (
-			topic_url, hub_url, callback_url, lease_expiration, lease_initiated, inactive_reason
+			topic_url, hub_url, callback_url, lease_expiration, lease_initiated, inactive_reason,
+			requested_lease_seconds, granted_lease_seconds
 		) AS 
-		SELECT topic_url, hub_url, callback_url, lease_expiration, lease_initiated, inactive_reason
+		SELECT topic_url, hub_url, callback_url, lease_expiration, lease_initiated, inactive_reason,
+			requested_lease_seconds, granted_lease_seconds
 		FROM subscriptions
 		WHERE ( 
 		  lease_expiration IS NOT NULL
@@ -39,16 +56,16 @@ const (
 
 	inactiveView = `
 		CREATE VIEW IF NOT EXISTS inactive_subscriptions (
-			topic_url, hub_url, callback_url, inactive_reason
+			topic_url, hub_url, callback_url, lease_expiration, inactive_reason, requested_lease_seconds
 		) AS
-		SELECT topic_url, hub_url, callback_url, inactive_reason
+		SELECT topic_url, hub_url, callback_url, lease_expiration, inactive_reason, requested_lease_seconds
 		FROM offered_subscriptions
 		LEFT OUTER JOIN subscriptions
 		USING (topic_url, hub_url)
-		WHERE (
+		WHERE withdrawn_at IS NULL AND (
 			lease_expiration IS NULL
 			OR (
 				lease_expiration IS NOT NULL 
-				AND datetime(lease_expiration) < datetime('now')))
+				AND datetime(lease_expiration) <= datetime('now')))
 		ORDER BY topic_url, hub_url;`
 )
@@ -1,43 +1,90 @@
 package storage
 
 import (
+	"context"
+	"database/sql"
 	"time"
 
 	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
 )
 
+// ErrNotFound is returned by queries that match nothing.
+// It is database/sql's ErrNoRows, so that backends built on database/sql can pass it straight through.
+var ErrNotFound = sql.ErrNoRows
+
 // Storage is the root interface for this package, and manages the state of subscription objects.
-// For more information, see README.md
+// Every method takes a context, which bounds the time spent talking to the backend.
+// Implementations must be safe for concurrent use. For more information, see README.md
 type Storage interface {
 	/* Commands */
 
-	// IndexOffer indexes the topic <-> hub relationship, which is observed in the discovery phase
-	IndexOffer(topicsToHubs map[string]string) error
+	// IndexOffer indexes the topic <-> hub relationships, which are observed in the discovery phase.
+	// Each topic may be offered by any number of hubs. Offers that were withdrawn are offered once again.
+	IndexOffer(ctx context.Context, topicsToHubs map[string][]string) error
 
-	// NewCallback records the fact that a subscription with the given hub has been initiated for the given topic.
-	NewCallback(topic, hub, callback string) error
+	// NewCallback records the fact that a subscription with the given hub has been initiated for the given topic,
+	// along with the secret shared with the hub (if any), and the lease that was requested (zero if none was).
+	NewCallback(ctx context.Context, topic, hub, callback, secret string, requestedLease time.Duration) error
 
 	// Invalidate expires a subscription.  This can happen in the cases of hub denials, or user-initiated cancels.
 	// Note that the client is NOT expected to invoke this method for subscriptions that merely expire.
-	Invalidate(callback, inactiveReason string) error
+	Invalidate(ctx context.Context, callback, inactiveReason string) error
 
 	// ExtendLease provides a subscription lease to a given callback.  Implicitly, this means that the subscription is active.
 	// This occurs when a subscription is first ACK'd, and also upon subsequent lease renewals.
-	ExtendLease(callback string, newExpiration time.Time) error
+	ExtendLease(ctx context.Context, callback string, newExpiration time.Time) error
+
+	// MigrateHub moves the topic's offer, and any subscription made through it, from the old hub to the new one.
+	// This occurs when the old hub permanently redirects a request about the topic.
+	MigrateHub(ctx context.Context, topic, oldHub, newHub string) error
+
+	// RecordAttempt records the outcome of the latest request made to the hub about the topic.
+	// The attempt is the number of consecutive tries that the request has taken so far,
+	// and lastError is empty if the latest try succeeded.
+	RecordAttempt(ctx context.Context, topic, hub string, attempt int, lastError string) error
+
+	// ReconcileHubs brings the hubs that offer the topic in line with a fresh discovery of it, and records the change.
+	// Removed hubs have their offers withdrawn, but keep their subscriptions.
+	ReconcileHubs(ctx context.Context, topic string, added, removed []string) error
 
 	/* Queries */
 
-	// GetActiveCallback returns a callback if a given topic+hub combination exists
-	GetCActiveallback(topic, hub string) (string, error)
+	// GetActiveCallback returns the callback of the active subscription for the given topic+hub combination
+	GetActiveCallback(ctx context.Context, topic, hub string) (string, error)
 
 	// GetSubscription returns any subscription associated with the given callback.
-	GetSubscription(callback string) (*subscriberpb.Subscription, error)
+	GetSubscription(ctx context.Context, callback string) (*subscriberpb.Subscription, error)
+
+	// GetLiveSubscription returns the subscription associated with the given callback,
+	// as long as it is either awaiting verification by its hub, or active.
+	GetLiveSubscription(ctx context.Context, callback string) (*subscriberpb.Subscription, error)
+
+	// GetSecret returns the hub.secret associated with the given callback (empty if its subscription request had none)
+	GetSecret(ctx context.Context, callback string) (string, error)
+
+	// GetActive returns at most 'pageSize' active subscriptions in alphabetical order, along with their requested
+	// and granted leases. If there are more than 'pageSize', the caller can use 'lastTopic' and 'lastHub' to ask for the next page.
+	GetActive(ctx context.Context, pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error)
+
+	// GetInactive returns at most 'pageSize' inactive topic/hub tuples in alphabetical order.
+	// If there are more than 'pageSize', the caller can use 'lastTopic' and 'lastHub' to ask for the next page.
+	GetInactive(ctx context.Context, pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error)
+
+	// GetAttempts returns the number of tries taken by the latest request made to the hub about the topic,
+	// and the error that its last try ended with (if any).
+	GetAttempts(ctx context.Context, topic, hub string) (attempts int, lastError string, err error)
+
+	// GetOfferedTopics returns every topic that is offered by at least one hub, in alphabetical order
+	GetOfferedTopics(ctx context.Context) ([]string, error)
+
+	// GetOfferedHubs returns the hubs that offer the topic (leaving out withdrawn offers), in alphabetical order
+	GetOfferedHubs(ctx context.Context, topic string) ([]string, error)
+
+	// GetHubChanges returns the history of hubs being added to and removed from the topic, oldest first
+	GetHubChanges(ctx context.Context, topic string) (*subscriberpb.HubChanges, error)
 
-	// GetActive returns at most 'pageSize' active subscriptions in alphabetical order.
-	// If there are more than 'pageSize', the caller can use 'pageNum' to ask for a specific partition in the sequence.
-	GetActive(pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error)
+	/* Lifecycle */
 
-	// GetInactive returns at most 'pageSize' inactive subscriptions in alphabetical order.
-	// If there are more than 'pageSize', the caller can use 'pageNum' to ask for a specific partition in the sequence.
-	GetInactive(pageSize int, lastTopic, lastHub string) (subs *subscriberpb.Subscriptions, lastPage bool, err error)
+	// Shutdown releases the backend's resources, persisting its state first if it was configured to
+	Shutdown() error
 }
@@ -0,0 +1,131 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// index offers the topic through each of the hubs
+func index(t *testing.T, stor storage.Storage, topic string, hubs ...string) {
+	t.Helper()
+	if err := stor.IndexOffer(context.Background(), map[string][]string{topic: hubs}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// listing is a listing of subscriptions, by topic and hub
+type listing map[[2]string]*subscriberpb.Subscription
+
+// list walks every page of the listing, and fails the test if the pages are out of order or overlap
+func list(t *testing.T, get func(context.Context, int, string, string) (*subscriberpb.Subscriptions, bool, error)) listing {
+	t.Helper()
+
+	subs := make(listing)
+	lastTopic, lastHub := "", ""
+	for {
+		page, lastPage, err := get(context.Background(), 3, lastTopic, lastHub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range page.Subscriptions {
+			if sub.Topic < lastTopic || (sub.Topic == lastTopic && sub.Hub <= lastHub) {
+				t.Fatalf("Listed {%v, %v} after {%v, %v}", sub.Topic, sub.Hub, lastTopic, lastHub)
+			}
+			subs[[2]string{sub.Topic, sub.Hub}] = sub
+			lastTopic, lastHub = sub.Topic, sub.Hub
+		}
+		if lastPage {
+			return subs
+		}
+	}
+}
+
+// expectBorn fails the test unless the callback's subscription is awaiting verification by its hub
+func expectBorn(t *testing.T, stor storage.Storage, topic, hub, callback string) {
+	t.Helper()
+	ctx := context.Background()
+
+	if sub, err := stor.GetLiveSubscription(ctx, callback); err != nil {
+		t.Fatalf("Expected {%v} to be live, but got {%v}", callback, err)
+	} else if sub.Topic != topic || sub.Hub != hub || sub.Callback != callback {
+		t.Fatalf("Expected {%v, %v, %v} but got {%v}", topic, hub, callback, sub)
+	}
+	if _, err := stor.GetActiveCallback(ctx, topic, hub); err != storage.ErrNotFound {
+		t.Fatalf("Expected {%v} not to be active yet, but got {%v}", callback, err)
+	}
+	if _, ok := list(t, stor.GetActive)[[2]string{topic, hub}]; ok {
+		t.Fatalf("Expected {%v} not to be listed as active", callback)
+	}
+
+	sub, ok := list(t, stor.GetInactive)[[2]string{topic, hub}]
+	if !ok {
+		t.Fatalf("Expected {%v} to be listed as inactive", callback)
+	}
+	if sub.Callback != callback || sub.LeaseExpiration != 0 {
+		t.Fatalf("Expected {%v} to be listed without an expiration, but got {%v}", callback, sub)
+	}
+}
+
+// expectActive fails the test unless the callback's subscription holds a lease from its hub
+func expectActive(t *testing.T, stor storage.Storage, topic, hub, callback string) *subscriberpb.Subscription {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := stor.GetLiveSubscription(ctx, callback); err != nil {
+		t.Fatalf("Expected {%v} to be live, but got {%v}", callback, err)
+	}
+	if cb, err := stor.GetActiveCallback(ctx, topic, hub); err != nil || cb != callback {
+		t.Fatalf("Expected {%v} to be the active callback, but got {%v, %v}", callback, cb, err)
+	}
+	if _, ok := list(t, stor.GetInactive)[[2]string{topic, hub}]; ok {
+		t.Fatalf("Expected {%v} not to be listed as inactive", callback)
+	}
+
+	listed, ok := list(t, stor.GetActive)[[2]string{topic, hub}]
+	if !ok || listed.Callback != callback {
+		t.Fatalf("Expected {%v} to be listed as active, but got {%v}", callback, listed)
+	}
+
+	sub, err := stor.GetSubscription(ctx, callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Topic != topic || sub.Hub != hub || sub.Callback != callback {
+		t.Fatalf("Expected {%v, %v, %v} but got {%v}", topic, hub, callback, sub)
+	}
+	if sub.LeaseExpiration != listed.LeaseExpiration || sub.LeaseInitiated != listed.LeaseInitiated ||
+		sub.RequestedLeaseSeconds != listed.RequestedLeaseSeconds || sub.GrantedLeaseSeconds != listed.GrantedLeaseSeconds {
+		t.Fatalf("Expected the listed lease {%v} to match the subscription's {%v}", listed, sub)
+	}
+	if sub.LeaseInitiated >= sub.LeaseExpiration {
+		t.Fatalf("Expected the lease to end after it began, but got {%v}", sub)
+	}
+	return sub
+}
+
+// expectDead fails the test unless the callback's subscription has ended, for the given reason
+func expectDead(t *testing.T, stor storage.Storage, topic, hub, callback, reason string) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := stor.GetLiveSubscription(ctx, callback); err != storage.ErrNotFound {
+		t.Fatalf("Expected {%v} not to be live, but got {%v}", callback, err)
+	}
+	if _, err := stor.GetActiveCallback(ctx, topic, hub); err != storage.ErrNotFound {
+		t.Fatalf("Expected {%v} not to be active, but got {%v}", callback, err)
+	}
+	if _, ok := list(t, stor.GetActive)[[2]string{topic, hub}]; ok {
+		t.Fatalf("Expected {%v} not to be listed as active", callback)
+	}
+
+	sub, ok := list(t, stor.GetInactive)[[2]string{topic, hub}]
+	if !ok {
+		t.Fatalf("Expected {%v} to be listed as inactive", callback)
+	}
+	if sub.Callback != callback || sub.InactiveReason != reason || sub.LeaseExpiration == 0 {
+		t.Fatalf("Expected {%v} to be listed as ended for {%v}, but got {%v}", callback, reason, sub)
+	}
+}
@@ -0,0 +1,249 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+)
+
+// testLifecycle walks a subscription through birth, activation, renewal and cancellation
+func testLifecycle(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "hub")
+
+	// Born
+	if err := stor.NewCallback(ctx, "topic", "hub", "callback", "secret", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	expectBorn(t, stor, "topic", "hub", "callback")
+	if secret, err := stor.GetSecret(ctx, "callback"); err != nil || secret != "secret" {
+		t.Fatalf("Expected secret {secret} but got {%v, %v}", secret, err)
+	}
+
+	// Active
+	if err := stor.ExtendLease(ctx, "callback", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	first := expectActive(t, stor, "topic", "hub", "callback")
+	if first.RequestedLeaseSeconds != 3600 {
+		t.Fatalf("Expected a requested lease of {3600} seconds but got {%v}", first.RequestedLeaseSeconds)
+	}
+	if first.GrantedLeaseSeconds < 59 || first.GrantedLeaseSeconds > 60 {
+		t.Fatalf("Expected a granted lease of {60} seconds but got {%v}", first.GrantedLeaseSeconds)
+	}
+
+	// Renewing, any number of times
+	for i := 1; i <= 2; i++ {
+		if err := stor.ExtendLease(ctx, "callback", time.Now().Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		renewed := expectActive(t, stor, "topic", "hub", "callback")
+		if renewed.LeaseInitiated != first.LeaseInitiated {
+			t.Fatalf("Expected renewals to keep the lease's start {%v}, but got {%v}", first.LeaseInitiated, renewed.LeaseInitiated)
+		}
+		if renewed.LeaseExpiration <= first.LeaseExpiration {
+			t.Fatalf("Expected renewals to extend the lease past {%v}, but got {%v}", first.LeaseExpiration, renewed.LeaseExpiration)
+		}
+		first = renewed
+	}
+
+	// Dead, once cancelled
+	if err := stor.Invalidate(ctx, "callback", "unsubscribed"); err != nil {
+		t.Fatal(err)
+	}
+	expectDead(t, stor, "topic", "hub", "callback", "unsubscribed")
+
+	// The dead stay dead
+	if err := stor.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("Expected a dead subscription not to be renewed")
+	}
+	if err := stor.Invalidate(ctx, "callback", "unsubscribed"); err == nil {
+		t.Fatal("Expected a dead subscription not to be invalidated again")
+	}
+	expectDead(t, stor, "topic", "hub", "callback", "unsubscribed")
+}
+
+// testDeniedWhileBorn denies a subscription before its hub ever grants it a lease
+func testDeniedWhileBorn(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "hub")
+
+	if err := stor.NewCallback(ctx, "topic", "hub", "callback", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := stor.Invalidate(ctx, "callback", "denied"); err != nil {
+		t.Fatal(err)
+	}
+	expectDead(t, stor, "topic", "hub", "callback", "denied")
+
+	if err := stor.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("Expected a denied subscription not to be activated")
+	}
+}
+
+// testDeniedWhileActive denies a subscription that the hub had granted a lease to
+func testDeniedWhileActive(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "hub")
+
+	if err := stor.NewCallback(ctx, "topic", "hub", "callback", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := stor.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	expectActive(t, stor, "topic", "hub", "callback")
+
+	if err := stor.Invalidate(ctx, "callback", "denied"); err != nil {
+		t.Fatal(err)
+	}
+	expectDead(t, stor, "topic", "hub", "callback", "denied")
+}
+
+// testExpiry lets a lease run out, which ends the subscription without a reason
+func testExpiry(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "hub")
+
+	if err := stor.NewCallback(ctx, "topic", "hub", "callback", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := stor.ExtendLease(ctx, "callback", time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leases are kept to the second by some backends
+	time.Sleep(time.Second + 100*time.Millisecond)
+	expectDead(t, stor, "topic", "hub", "callback", "")
+
+	if err := stor.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("Expected an expired subscription not to be renewed")
+	}
+	if err := stor.Invalidate(ctx, "callback", "denied"); err == nil {
+		t.Fatal("Expected an expired subscription not to be invalidated")
+	}
+}
+
+// testResubscribe replaces subscriptions, dead or alive, with new ones through the same offer
+func testResubscribe(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "hub")
+
+	if err := stor.NewCallback(ctx, "topic", "hub", "first", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := stor.Invalidate(ctx, "first", "denied"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dead subscription is replaced, and born again
+	if err := stor.NewCallback(ctx, "topic", "hub", "second", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	expectBorn(t, stor, "topic", "hub", "second")
+	if _, err := stor.GetSecret(ctx, "first"); err != storage.ErrNotFound {
+		t.Fatalf("Expected the replaced subscription to be gone, but got {%v}", err)
+	}
+
+	// So is an active one
+	if err := stor.ExtendLease(ctx, "second", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := stor.NewCallback(ctx, "topic", "hub", "third", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	expectBorn(t, stor, "topic", "hub", "third")
+	if _, err := stor.GetLiveSubscription(ctx, "second"); err != storage.ErrNotFound {
+		t.Fatalf("Expected the replaced subscription to be gone, but got {%v}", err)
+	}
+}
+
+// testErrors feeds every command bad input, and every query callbacks that don't exist
+func testErrors(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "hub")
+	index(t, stor, "topic2", "hub")
+
+	if err := stor.IndexOffer(ctx, map[string][]string{"": {"hub"}}); err == nil {
+		t.Fatal("Expected an empty topic not to be indexed")
+	}
+	if err := stor.IndexOffer(ctx, map[string][]string{"topic": {""}}); err == nil {
+		t.Fatal("Expected an empty hub not to be indexed")
+	}
+
+	if err := stor.NewCallback(ctx, "", "hub", "callback", "", 0); err == nil {
+		t.Fatal("Expected a subscription without a topic to be rejected")
+	}
+	if err := stor.NewCallback(ctx, "topic", "", "callback", "", 0); err == nil {
+		t.Fatal("Expected a subscription without a hub to be rejected")
+	}
+	if err := stor.NewCallback(ctx, "topic", "unoffered", "callback", "", 0); err == nil {
+		t.Fatal("Expected a subscription through a hub that doesn't offer the topic to be rejected")
+	}
+
+	if err := stor.NewCallback(ctx, "topic", "hub", "callback", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := stor.NewCallback(ctx, "topic2", "hub", "callback", "", 0); err == nil {
+		t.Fatal("Expected a callback not to be shared by two subscriptions")
+	}
+
+	if err := stor.ExtendLease(ctx, "callback", time.Now().Add(-time.Minute)); err == nil {
+		t.Fatal("Expected a lease in the past to be rejected")
+	}
+	if err := stor.ExtendLease(ctx, "unknown", time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("Expected an unknown callback not to be renewed")
+	}
+	if err := stor.Invalidate(ctx, "callback", ""); err == nil {
+		t.Fatal("Expected an invalidation without a reason to be rejected")
+	}
+	if err := stor.Invalidate(ctx, "unknown", "denied"); err == nil {
+		t.Fatal("Expected an unknown callback not to be invalidated")
+	}
+	expectBorn(t, stor, "topic", "hub", "callback")
+
+	if _, err := stor.GetActiveCallback(ctx, "topic", "unoffered"); err != storage.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound but got {%v}", err)
+	}
+	if _, err := stor.GetSubscription(ctx, "unknown"); err != storage.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound but got {%v}", err)
+	}
+	if _, err := stor.GetLiveSubscription(ctx, "unknown"); err != storage.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound but got {%v}", err)
+	}
+	if _, err := stor.GetSecret(ctx, "unknown"); err != storage.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound but got {%v}", err)
+	}
+	if _, _, err := stor.GetAttempts(ctx, "topic", "unoffered"); err != storage.ErrNotFound {
+		t.Fatalf("Expected ErrNotFound but got {%v}", err)
+	}
+}
+
+// testCancelledContext calls the backend with a context that is already done
+func testCancelledContext(t *testing.T, stor storage.Storage) {
+	index(t, stor, "topic", "hub")
+	if err := stor.NewCallback(context.Background(), "topic", "hub", "callback", "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := stor.ExtendLease(ctx, "callback", time.Now().Add(time.Hour)); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled but got {%v}", err)
+	}
+	if err := stor.Invalidate(ctx, "callback", "denied"); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled but got {%v}", err)
+	}
+	if _, err := stor.GetLiveSubscription(ctx, "callback"); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled but got {%v}", err)
+	}
+	if _, _, err := stor.GetInactive(ctx, 10, "", ""); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled but got {%v}", err)
+	}
+
+	// Nothing changed
+	expectBorn(t, stor, "topic", "hub", "callback")
+}
@@ -0,0 +1,177 @@
+package storagetest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// testOffers indexes offers, some of them more than once
+func testOffers(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+
+	if topics, err := stor.GetOfferedTopics(ctx); err != nil || len(topics) != 0 {
+		t.Fatalf("Expected no topics to be offered, but got {%v, %v}", topics, err)
+	}
+
+	if err := stor.IndexOffer(ctx, map[string][]string{
+		"topicB": {"hub2", "hub1"},
+		"topicA": {"hub1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Indexing is idempotent
+	index(t, stor, "topicB", "hub1")
+
+	if topics, err := stor.GetOfferedTopics(ctx); err != nil || !reflect.DeepEqual(topics, []string{"topicA", "topicB"}) {
+		t.Fatalf("Expected topics [topicA topicB] but got {%v, %v}", topics, err)
+	}
+	if hubs, err := stor.GetOfferedHubs(ctx, "topicB"); err != nil || !reflect.DeepEqual(hubs, []string{"hub1", "hub2"}) {
+		t.Fatalf("Expected hubs [hub1 hub2] but got {%v, %v}", hubs, err)
+	}
+	if hubs, err := stor.GetOfferedHubs(ctx, "unknown"); err != nil || len(hubs) != 0 {
+		t.Fatalf("Expected no hubs but got {%v, %v}", hubs, err)
+	}
+
+	// Indexing an offer again leaves its subscription alone
+	activate(t, stor, "topicA", "hub1")
+	index(t, stor, "topicA", "hub1")
+	expectActive(t, stor, "topicA", "hub1", "topicA|hub1")
+}
+
+// testMigrateHub moves offers, and the subscriptions made through them, to new hubs
+func testMigrateHub(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "old", "taken")
+	activate(t, stor, "topic", "old", "taken")
+
+	if err := stor.RecordAttempt(ctx, "topic", "old", 2, "redirected"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The offer moves to a new hub, along with its subscription and attempts
+	if err := stor.MigrateHub(ctx, "topic", "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	expectActive(t, stor, "topic", "new", "topic|old")
+	if hubs, err := stor.GetOfferedHubs(ctx, "topic"); err != nil || !reflect.DeepEqual(hubs, []string{"new", "taken"}) {
+		t.Fatalf("Expected hubs [new taken] but got {%v, %v}", hubs, err)
+	}
+	if attempts, lastError, err := stor.GetAttempts(ctx, "topic", "new"); err != nil || attempts != 2 || lastError != "redirected" {
+		t.Fatalf("Expected the attempts to move along with the offer, but got {%v, %v, %v}", attempts, lastError, err)
+	}
+
+	// The subscription replaces the one at a hub that already offered the topic
+	if err := stor.MigrateHub(ctx, "topic", "new", "taken"); err != nil {
+		t.Fatal(err)
+	}
+	expectActive(t, stor, "topic", "taken", "topic|old")
+	if _, err := stor.GetSecret(ctx, "topic|taken"); err != storage.ErrNotFound {
+		t.Fatalf("Expected the replaced subscription to be gone, but got {%v}", err)
+	}
+	if hubs, err := stor.GetOfferedHubs(ctx, "topic"); err != nil || !reflect.DeepEqual(hubs, []string{"taken"}) {
+		t.Fatalf("Expected hubs [taken] but got {%v, %v}", hubs, err)
+	}
+
+	// Hubs that don't offer the topic can't be migrated
+	if err := stor.MigrateHub(ctx, "topic", "old", "newer"); err == nil {
+		t.Fatal("Expected migrating a hub that doesn't offer the topic to fail")
+	}
+	if err := stor.MigrateHub(ctx, "topic", "taken", ""); err == nil {
+		t.Fatal("Expected migrating to an empty hub to fail")
+	}
+	expectActive(t, stor, "topic", "taken", "topic|old")
+}
+
+// testReconcileHubs adds and removes hubs, and checks the history of changes
+func testReconcileHubs(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "hubA")
+	activate(t, stor, "topic", "hubA")
+
+	if err := stor.ReconcileHubs(ctx, "topic", []string{"hubB"}, []string{"hubA"}); err != nil {
+		t.Fatal(err)
+	}
+	if hubs, err := stor.GetOfferedHubs(ctx, "topic"); err != nil || !reflect.DeepEqual(hubs, []string{"hubB"}) {
+		t.Fatalf("Expected hubs [hubB] but got {%v, %v}", hubs, err)
+	}
+
+	// Withdrawn offers keep their subscriptions, but aren't listed as inactive
+	if _, err := stor.GetLiveSubscription(ctx, "topic|hubA"); err != nil {
+		t.Fatalf("Expected the withdrawn hub's subscription to be kept, but got {%v}", err)
+	}
+	if err := stor.Invalidate(ctx, "topic|hubA", "unsubscribed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := list(t, stor.GetInactive)[[2]string{"topic", "hubA"}]; ok {
+		t.Fatal("Expected a withdrawn offer not to be listed as inactive")
+	}
+
+	// A failed reconciliation changes nothing
+	if err := stor.ReconcileHubs(ctx, "topic", []string{"hubC"}, []string{"hubA"}); err == nil {
+		t.Fatal("Expected removing a withdrawn hub to fail")
+	}
+	if hubs, err := stor.GetOfferedHubs(ctx, "topic"); err != nil || !reflect.DeepEqual(hubs, []string{"hubB"}) {
+		t.Fatalf("Expected hubs [hubB] but got {%v, %v}", hubs, err)
+	}
+
+	// Withdrawn hubs can be added back
+	if err := stor.ReconcileHubs(ctx, "topic", []string{"hubA"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if hubs, err := stor.GetOfferedHubs(ctx, "topic"); err != nil || !reflect.DeepEqual(hubs, []string{"hubA", "hubB"}) {
+		t.Fatalf("Expected hubs [hubA hubB] but got {%v, %v}", hubs, err)
+	}
+
+	changes, err := stor.GetHubChanges(ctx, "topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, change := range changes.HubChanges {
+		if change.Topic != "topic" || change.ChangedAt == 0 {
+			t.Fatalf("Expected a dated change to {topic}, but got {%v}", change)
+		}
+		got = append(got, change.Kind.String()+" "+change.Hub)
+	}
+	expected := []string{
+		subscriberpb.HubChangeKind_HubAdded.String() + " hubB",
+		subscriberpb.HubChangeKind_HubRemoved.String() + " hubA",
+		subscriberpb.HubChangeKind_HubAdded.String() + " hubA",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected changes %v but got %v", expected, got)
+	}
+}
+
+// testAttempts records the outcomes of requests made to a hub
+func testAttempts(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "hub")
+
+	if attempts, lastError, err := stor.GetAttempts(ctx, "topic", "hub"); err != nil || attempts != 0 || lastError != "" {
+		t.Fatalf("Expected no attempts yet, but got {%v, %v, %v}", attempts, lastError, err)
+	}
+
+	if err := stor.RecordAttempt(ctx, "topic", "hub", 3, "timed out"); err != nil {
+		t.Fatal(err)
+	}
+	if attempts, lastError, err := stor.GetAttempts(ctx, "topic", "hub"); err != nil || attempts != 3 || lastError != "timed out" {
+		t.Fatalf("Expected {3, timed out} but got {%v, %v, %v}", attempts, lastError, err)
+	}
+
+	if err := stor.RecordAttempt(ctx, "topic", "hub", 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if attempts, lastError, err := stor.GetAttempts(ctx, "topic", "hub"); err != nil || attempts != 1 || lastError != "" {
+		t.Fatalf("Expected {1, } but got {%v, %v, %v}", attempts, lastError, err)
+	}
+
+	if err := stor.RecordAttempt(ctx, "topic", "unoffered", 1, ""); err == nil {
+		t.Fatal("Expected recording an attempt at a hub that doesn't offer the topic to fail")
+	}
+}
@@ -0,0 +1,171 @@
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
+)
+
+// pairs lists the topic/hub pairs of a page, in order
+func pairs(subs *subscriberpb.Subscriptions) []string {
+	var pairs []string
+	for _, sub := range subs.Subscriptions {
+		pairs = append(pairs, sub.Topic+" "+sub.Hub)
+	}
+	return pairs
+}
+
+// activate subscribes through each of the topic's hubs, and has each hub grant a lease
+func activate(t *testing.T, stor storage.Storage, topic string, hubs ...string) {
+	t.Helper()
+	for _, hub := range hubs {
+		callback := fmt.Sprintf("%v|%v", topic, hub)
+		if err := stor.NewCallback(context.Background(), topic, hub, callback, "", 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := stor.ExtendLease(context.Background(), callback, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testPagingEmpty lists a backend that holds nothing
+func testPagingEmpty(t *testing.T, stor storage.Storage) {
+	for name, get := range map[string]func(context.Context, int, string, string) (*subscriberpb.Subscriptions, bool, error){
+		"active":   stor.GetActive,
+		"inactive": stor.GetInactive,
+	} {
+		subs, lastPage, err := get(context.Background(), 10, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !lastPage || len(subs.Subscriptions) != 0 {
+			t.Fatalf("Expected an empty last page of %v subscriptions, but got {%v, %v}", name, subs.Subscriptions, lastPage)
+		}
+	}
+}
+
+// testPagingBoundaries lists pages that end exactly at, short of, and past the last subscription
+func testPagingBoundaries(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	for _, topic := range []string{"t1", "t2", "t3", "t4"} {
+		index(t, stor, topic, "hub")
+		activate(t, stor, topic, "hub")
+	}
+
+	// Full pages are never reported as the last, even if nothing follows them
+	subs, lastPage, err := stor.GetActive(ctx, 2, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastPage || !reflect.DeepEqual(pairs(subs), []string{"t1 hub", "t2 hub"}) {
+		t.Fatalf("Expected a full first page, but got {%v, %v}", pairs(subs), lastPage)
+	}
+
+	subs, lastPage, err = stor.GetActive(ctx, 2, "t2", "hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastPage || !reflect.DeepEqual(pairs(subs), []string{"t3 hub", "t4 hub"}) {
+		t.Fatalf("Expected a full second page, but got {%v, %v}", pairs(subs), lastPage)
+	}
+
+	subs, lastPage, err = stor.GetActive(ctx, 2, "t4", "hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lastPage || len(subs.Subscriptions) != 0 {
+		t.Fatalf("Expected an empty last page, but got {%v, %v}", pairs(subs), lastPage)
+	}
+
+	// Short pages are the last
+	subs, lastPage, err = stor.GetActive(ctx, 3, "t2", "hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lastPage || !reflect.DeepEqual(pairs(subs), []string{"t3 hub", "t4 hub"}) {
+		t.Fatalf("Expected a short last page, but got {%v, %v}", pairs(subs), lastPage)
+	}
+
+	// Pages may start after a key that isn't stored
+	subs, _, err = stor.GetActive(ctx, 1, "t2", "zzz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pairs(subs), []string{"t3 hub"}) {
+		t.Fatalf("Expected the page to start at {t3 hub}, but got %v", pairs(subs))
+	}
+}
+
+// testPagingOrder lists subscriptions whose urls only differ in case and punctuation, which are ordered byte-wise
+func testPagingOrder(t *testing.T, stor storage.Storage) {
+	index(t, stor, "a", "hub", "Hub", "hub/2")
+	index(t, stor, "B", "hub")
+	index(t, stor, "a/b", "hub")
+	activate(t, stor, "a", "hub", "Hub", "hub/2")
+	activate(t, stor, "B", "hub")
+	activate(t, stor, "a/b", "hub")
+
+	expected := []string{"B hub", "a Hub", "a hub", "a hub/2", "a/b hub"}
+
+	var listed []string
+	lastTopic, lastHub := "", ""
+	for {
+		subs, lastPage, err := stor.GetActive(context.Background(), 1, lastTopic, lastHub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range subs.Subscriptions {
+			lastTopic, lastHub = sub.Topic, sub.Hub
+		}
+		listed = append(listed, pairs(subs)...)
+		if lastPage {
+			break
+		}
+	}
+
+	if !reflect.DeepEqual(listed, expected) {
+		t.Fatalf("Expected %v but got %v", expected, listed)
+	}
+}
+
+// testPagingActiveAndInactive lists subscriptions in every state, each of which is either active or inactive
+func testPagingActiveAndInactive(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "active", "born", "dead", "unsubscribed")
+	activate(t, stor, "topic", "active")
+
+	if err := stor.NewCallback(ctx, "topic", "born", "born", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := stor.NewCallback(ctx, "topic", "dead", "dead", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := stor.Invalidate(ctx, "dead", "denied"); err != nil {
+		t.Fatal(err)
+	}
+
+	active := list(t, stor.GetActive)
+	if len(active) != 1 || active[[2]string{"topic", "active"}] == nil {
+		t.Fatalf("Expected only {topic active} to be active, but got %v", active)
+	}
+
+	inactive := list(t, stor.GetInactive)
+	if len(inactive) != 3 {
+		t.Fatalf("Expected 3 inactive subscriptions, but got %v", inactive)
+	}
+	if sub := inactive[[2]string{"topic", "unsubscribed"}]; sub == nil || sub.Callback != "" {
+		t.Fatalf("Expected an offer that was never subscribed through to be inactive, without a callback, but got {%v}", sub)
+	}
+	if sub := inactive[[2]string{"topic", "born"}]; sub == nil || sub.Callback != "born" {
+		t.Fatalf("Expected the born subscription to be inactive, but got {%v}", sub)
+	}
+	if sub := inactive[[2]string{"topic", "dead"}]; sub == nil || sub.InactiveReason != "denied" {
+		t.Fatalf("Expected the dead subscription to be inactive, but got {%v}", sub)
+	}
+}
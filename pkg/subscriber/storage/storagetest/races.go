@@ -0,0 +1,80 @@
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+)
+
+// testExtendInvalidateRace renews and invalidates a subscription at the same time.
+// Exactly one invalidation wins, and no renewal brings the subscription back to life afterwards.
+func testExtendInvalidateRace(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+	index(t, stor, "topic", "hub")
+	activate(t, stor, "topic", "hub")
+	callback := "topic|hub"
+
+	var wg sync.WaitGroup
+	invalidated := make(chan struct{}, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			stor.ExtendLease(ctx, callback, time.Now().Add(time.Hour))
+		}()
+		go func() {
+			defer wg.Done()
+			if stor.Invalidate(ctx, callback, "unsubscribed") == nil {
+				invalidated <- struct{}{}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(invalidated) != 1 {
+		t.Fatalf("Expected exactly one invalidation to succeed, but %d did", len(invalidated))
+	}
+	expectDead(t, stor, "topic", "hub", callback, "unsubscribed")
+}
+
+// testConcurrentCallbacks subscribes to many topics at once
+func testConcurrentCallbacks(t *testing.T, stor storage.Storage) {
+	ctx := context.Background()
+
+	topics := make(map[string][]string)
+	for i := 0; i < 20; i++ {
+		topics[fmt.Sprintf("topic%02d", i)] = []string{"hub"}
+	}
+	if err := stor.IndexOffer(ctx, topics); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(topics))
+	for topic := range topics {
+		wg.Add(1)
+		go func(topic string) {
+			defer wg.Done()
+			if err := stor.NewCallback(ctx, topic, "hub", topic, "", 0); err != nil {
+				errs <- err
+				return
+			}
+			if err := stor.ExtendLease(ctx, topic, time.Now().Add(time.Hour)); err != nil {
+				errs <- err
+			}
+		}(topic)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if active := list(t, stor.GetActive); len(active) != len(topics) {
+		t.Fatalf("Expected %d active subscriptions, but got %d", len(topics), len(active))
+	}
+}
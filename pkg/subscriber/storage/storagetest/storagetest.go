@@ -0,0 +1,56 @@
+// Package storagetest holds implementations of storage.Storage to a single contract.
+//
+// The contract is the lifecycle described in storage/README.md:
+// a subscription is born when it's requested, becomes active when the hub grants it a lease,
+// is renewed any number of times, and dies when it's denied, cancelled, or its lease runs out.
+// Backends report failures with errors of their own, so the suite only checks that an error happened,
+// except for queries that match nothing, which must return storage.ErrNotFound,
+// and calls whose context is already done, which must return the context's error.
+package storagetest
+
+import (
+	"testing"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+)
+
+// Factory returns a fresh, empty backend for a single test.
+// The suite shuts the backend down when the test ends.
+type Factory func(t *testing.T) storage.Storage
+
+// Run runs the whole suite against backends made by the factory, each test as a subtest of t
+func Run(t *testing.T, newStorage Factory) {
+	for _, test := range []struct {
+		name string
+		run  func(t *testing.T, stor storage.Storage)
+	}{
+		{"Lifecycle", testLifecycle},
+		{"DeniedWhileBorn", testDeniedWhileBorn},
+		{"DeniedWhileActive", testDeniedWhileActive},
+		{"Expiry", testExpiry},
+		{"Resubscribe", testResubscribe},
+		{"Errors", testErrors},
+		{"CancelledContext", testCancelledContext},
+		{"PagingEmpty", testPagingEmpty},
+		{"PagingBoundaries", testPagingBoundaries},
+		{"PagingOrder", testPagingOrder},
+		{"PagingActiveAndInactive", testPagingActiveAndInactive},
+		{"ExtendInvalidateRace", testExtendInvalidateRace},
+		{"ConcurrentCallbacks", testConcurrentCallbacks},
+		{"Offers", testOffers},
+		{"MigrateHub", testMigrateHub},
+		{"ReconcileHubs", testReconcileHubs},
+		{"Attempts", testAttempts},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			stor := newStorage(t)
+			defer func() {
+				if err := stor.Shutdown(); err != nil {
+					t.Fatal(err)
+				}
+			}()
+			test.run(t, stor)
+		})
+	}
+}
@@ -4,58 +4,192 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// ErrNoHubs is returned by Subscribe when the topic does not advertise any hubs
+var ErrNoHubs = errors.New("Subscriber: topic does not advertise any hubs")
+
+// HubPolicy determines how many of the hubs advertised by a topic are subscribed to.
+// Positive policies subscribe to (up to) that many hubs, in the order that the topic lists them,
+// so that content keeps flowing if one of them goes down.
+type HubPolicy int
+
+const (
+	// FirstHub subscribes to the first hub advertised by the topic
+	FirstHub HubPolicy = 0
+
+	// AllHubs subscribes to every hub advertised by the topic (as does any other negative policy)
+	AllHubs HubPolicy = -1
+)
+
+// limit returns the number of the given hubs that the policy subscribes to
+func (p HubPolicy) limit(hubs int) int {
+	switch {
+	case p == FirstHub:
+		return 1
+	case p < 0 || int(p) > hubs:
+		return hubs
+	}
+	return int(p)
+}
+
+// SubscribeOptions configures a call to Subscribe
+type SubscribeOptions struct {
+	// Hubs is the policy for choosing which of the topic's hubs to subscribe to (FirstHub, by default).
+	// Content that arrives through more than one of them is only delivered once.
+	Hubs HubPolicy
+
+	// LeaseDuration overrides the lease requested by the Subscriber's Config, when non-zero
+	LeaseDuration time.Duration
+}
+
+// Subscribe discovers the hubs of the given topic url, indexes all of them, and sends subscription requests
+// to the ones chosen by the options' HubPolicy.
+// Topics discovered recently enough to still be fresh are not fetched again.
+// One handle is returned per hub that accepted the request, which resolves once that hub verifies or denies it.
+// If a request fails, the handles of the requests that were already accepted are returned alongside the error.
+func (sub *Subscriber) Subscribe(ctx context.Context, topicURL string, opts *SubscribeOptions) ([]*Handle, error) {
+	if opts == nil {
+		opts = &SubscribeOptions{}
+	}
+
+	res, err := sub.discoveries.Discover(ctx, topicURL)
+	if err != nil {
+		return nil, err
+	}
+	self := res.Self.URL
+
+	// Hubs are tried in the order that the topic lists them
+	hubs := make([]string, 0, len(res.Hubs))
+	for _, hub := range res.Hubs {
+		hubs = append(hubs, hub.URL)
+	}
+	if len(hubs) == 0 {
+		return nil, ErrNoHubs
+	}
+
+	if err := sub.storage.IndexOffer(ctx, map[string][]string{self: hubs}); err != nil {
+		return nil, err
+	}
+	hubs = hubs[:opts.Hubs.limit(len(hubs))]
+
+	sub.policyMut.Lock()
+	sub.hubPolicies[self] = opts.Hubs
+	sub.policyMut.Unlock()
+
+	lease := sub.leaseDuration
+	if opts.LeaseDuration != 0 {
+		lease = opts.LeaseDuration
+	}
+
+	handles := make([]*Handle, 0, len(hubs))
+	for _, hub := range hubs {
+		// Track the handle before sending the request, in case the hub is quick to verify.
+		// The callback is recorded before the request is sent too, so the verification finds it.
+		handle := newHandle(self, hub, generateCallback())
+		sub.trackVerification(handle)
+
+		if err := sub.requestSubscription(ctx, self, hub, handle.Callback, lease); err != nil {
+			sub.untrackVerification(handle.Callback)
+			return handles, err
+		}
+		handles = append(handles, handle)
+	}
+
+	return handles, nil
+}
+
 func (sub *Subscriber) initiateSubscription(ctx context.Context, topic, hub string) error {
-	callback := generateCallback()
+	return sub.requestSubscription(ctx, topic, hub, generateCallback(), sub.leaseDuration)
+}
+
+// requestSubscription asks the hub to subscribe the given callback to the topic, for the given lease (if non-zero).
+// The callback is recorded in storage before the request is sent, so that a hub which verifies the subscription
+// before it answers the request finds the callback, and is invalidated if the request fails for good.
+// Redirects are followed with the same callback, and failed requests are retried according to the Subscriber's RetryPolicy.
+func (sub *Subscriber) requestSubscription(ctx context.Context, topic, hub, callback string, lease time.Duration) error {
+	// Secrets are only shared over https, as the spec recommends
+	secret := ""
+	if strings.HasPrefix(hub, "https://") {
+		secret = generateSecret()
+	}
+
+	if err := sub.storage.NewCallback(ctx, topic, hub, callback, secret, lease); err != nil {
+		return err
+	}
+
+	err := sub.withRetries(ctx, topic, hub, func(hub string, chain *redirectChain) error {
+		return sub.trySubscription(ctx, topic, hub, callback, secret, lease, chain)
+	})
+	if err != nil {
+		if invErr := sub.storage.Invalidate(context.Background(), callback, "subscribe: "+err.Error()); invErr != nil {
+			log.Printf("Failed to invalidate callback {%v} after its subscription request failed: %v\n", callback, invErr)
+		}
+	}
+	return err
+}
+
+// trySubscription makes a single subscription request, following redirects
+func (sub *Subscriber) trySubscription(ctx context.Context, topic, hub, callback, secret string, lease time.Duration, chain *redirectChain) error {
+	// A secret must never be sent to a hub that we'd be talking to in the clear
+	if secret != "" && !strings.HasPrefix(hub, "https://") {
+		return ErrInsecureRedirect
+	}
 
-	resp, err := sub.sendSubscriptionRequest(topic, hub, callback)
+	resp, err := sub.sendSubscriptionRequest(ctx, topic, hub, callback, secret, lease)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	code := resp.StatusCode
 
 	// ACK
 	if code == 202 {
-		return sub.storage.NewCallback(ctx, topic, hub, callback)
+		return nil
 	}
 
 	// Redirect
 	if code == 307 || code == 308 {
-		newHubLoc := resp.Header.Get("Location")
-
-		sub.storage.IndexOffer(map[string]string{topic: newHubLoc})
-		if code == 307 {
-			log.Printf("Temporary redirect response, to new address {%v}", newHubLoc)
-		} else {
-			// TODO(adam): Consider replacing old hub url in storage, instead of supplanting
-			log.Printf("Permanent redirect response, to new address {%v}", newHubLoc)
+		newHub, err := sub.followRedirect(ctx, topic, hub, resp, chain)
+		if err != nil {
+			return err
 		}
-		return sub.initiateSubscription(ctx, topic, newHubLoc)
+		return sub.trySubscription(ctx, topic, newHub, callback, secret, lease, chain)
 	}
 
-	return fmt.Errorf("Invalid status code while making subscription request, resp: {%+v}", resp)
+	return newErrHubStatus(resp)
 }
 
-func (sub *Subscriber) sendSubscriptionRequest(topic, hub, callback string) (*http.Response, error) {
+func (sub *Subscriber) sendSubscriptionRequest(ctx context.Context, topic, hub, callback, secret string, lease time.Duration) (*http.Response, error) {
 	data := make(url.Values)
 	data.Set("hub.callback", callback)
 	data.Set("hub.mode", "subscribe")
 	data.Set("hub.topic", topic)
+	if secret != "" {
+		data.Set("hub.secret", secret)
+	}
+	if lease > 0 {
+		data.Set("hub.lease_seconds", strconv.FormatInt(int64(lease/time.Second), 10))
+	}
 
 	req, _ := http.NewRequest("POST", hub, strings.NewReader(data.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Content-Length", strconv.Itoa(len(data.Encode())))
 
-	resp, err := sub.client.Do(req)
+	resp, err := sub.client.Do(req.WithContext(ctx))
 	if err != nil {
+		// Requests cut short by the context report the context's error, rather than the transport's
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		return nil, err
 	}
 
@@ -75,18 +209,39 @@ func (sub *Subscriber) sendSubscriptionRequest(topic, hub, callback string) (*ht
 func (sub *Subscriber) renewSubscription(ctx context.Context, callback string) error {
 	// check to see that the subscription is still active.
 	// this will return an error if it isn't
-	subscription, err := sub.storage.GetSubscription(callback)
+	subscription, err := sub.storage.GetSubscription(ctx, callback)
 	if err != nil {
 		return err
 	}
 
 	topic, hub := subscription.Topic, subscription.Hub
 
-	resp, err := sub.sendSubscriptionRequest(topic, hub, callback)
+	secret, err := sub.storage.GetSecret(ctx, callback)
 	if err != nil {
 		return err
 	}
 
+	// Renewals ask for the same lease as the original request did
+	lease := time.Duration(subscription.RequestedLeaseSeconds) * time.Second
+
+	return sub.withRetries(ctx, topic, hub, func(hub string, chain *redirectChain) error {
+		return sub.tryRenewal(ctx, topic, hub, callback, secret, lease, chain)
+	})
+}
+
+// tryRenewal makes a single renewal request for the given callback, following redirects
+func (sub *Subscriber) tryRenewal(ctx context.Context, topic, hub, callback, secret string, lease time.Duration, chain *redirectChain) error {
+	// A secret must never be sent to a hub that we'd be talking to in the clear
+	if secret != "" && !strings.HasPrefix(hub, "https://") {
+		return ErrInsecureRedirect
+	}
+
+	resp, err := sub.sendSubscriptionRequest(ctx, topic, hub, callback, secret, lease)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
 	code := resp.StatusCode
 
 	// ACK
@@ -96,19 +251,14 @@ func (sub *Subscriber) renewSubscription(ctx context.Context, callback string) e
 
 	// Redirect
 	if code == 307 || code == 308 {
-		newHubLoc := resp.Header.Get("Location")
-
-		sub.storage.IndexOffer(map[string]string{topic: newHubLoc})
-		if code == 307 {
-			log.Printf("Temporary redirect response, to new address {%v}", newHubLoc)
-		} else {
-			// TODO(adam): Consider replacing old hub url in storage, instead of supplanting
-			log.Printf("Permanent redirect response, to new address {%v}", newHubLoc)
+		newHub, err := sub.followRedirect(ctx, topic, hub, resp, chain)
+		if err != nil {
+			return err
 		}
-		return sub.renewSubscription(ctx, callback)
+		return sub.tryRenewal(ctx, topic, newHub, callback, secret, lease, chain)
 	}
 
-	return fmt.Errorf("Invalid status code while making subscription request, resp: {%+v}", resp)
+	return newErrHubStatus(resp)
 }
 
 // helper function to generate a 16-byte (32 chars) string
@@ -117,3 +267,10 @@ func generateCallback() string {
 	rand.Read(randomURI)
 	return hex.EncodeToString(randomURI)
 }
+
+// helper function to generate a 32-byte (64 chars) string, used as a hub.secret
+func generateSecret() string {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return hex.EncodeToString(secret)
+}
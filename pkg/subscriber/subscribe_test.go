@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	"gopkg.in/jarcoal/httpmock.v1"
 )
@@ -19,11 +20,7 @@ func TestSubscriber_subscribe_redirect(t *testing.T) {
 	}
 	httpmock.ActivateNonDefault(sub.client)
 
-	go func(t *testing.T) {
-		if err := sub.Run(); err != http.ErrServerClosed {
-			t.Fatal(err)
-		}
-	}(t)
+	runSubscriber(t, sub)
 
 	redirectDest := "http://temp_hub.com/hub"
 
@@ -47,8 +44,8 @@ func TestSubscriber_subscribe_redirect(t *testing.T) {
 
 	setupDummyValidationAck(redirectDest)
 
-	err = sub.storage.IndexOffer(map[string]string{
-		topicURLTest: hubURLTest,
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -59,6 +56,15 @@ func TestSubscriber_subscribe_redirect(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// The topic is still offered by the hub that redirected, and by it alone
+	hubs, err := sub.storage.GetOfferedHubs(context.Background(), topicURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hubs) != 1 || hubs[0] != hubURLTest {
+		t.Fatalf("Expected offered hubs {%v} but got {%v}", []string{hubURLTest}, hubs)
+	}
+
 	err = sub.Shutdown()
 	if err != nil {
 		t.Fatal(err)
@@ -74,16 +80,12 @@ func TestSubscriber_subscribe_cancelledCtx(t *testing.T) {
 	}
 	httpmock.ActivateNonDefault(sub.client)
 
-	go func(t *testing.T) {
-		if err := sub.Run(); err != http.ErrServerClosed {
-			t.Fatal(err)
-		}
-	}(t)
+	runSubscriber(t, sub)
 
 	setupDummyValidationAck(hubURLTest)
 
-	err = sub.storage.IndexOffer(map[string]string{
-		topicURLTest: hubURLTest,
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -101,7 +103,7 @@ func TestSubscriber_subscribe_cancelledCtx(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = sub.storage.GetActiveCallback(topicURLTest, hubURLTest)
+	_, err = sub.storage.GetActiveCallback(context.Background(), topicURLTest, hubURLTest)
 	if err != sql.ErrNoRows {
 		t.Fatalf("Expected {%v} but got {%v}", sql.ErrNoRows, err)
 	}
@@ -113,3 +115,323 @@ func TestSubscriber_subscribe_cancelledCtx(t *testing.T) {
 
 	httpmock.DeactivateAndReset()
 }
+
+func TestSubscriber_Subscribe(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Discovery goes through the default client, subscription requests through the subscriber's
+	httpmock.Activate()
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	otherHubURL := "http://example.com/other_hub"
+	httpmock.RegisterResponder("GET", topicURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			resp := httpmock.NewStringResponse(200, `<html><head>
+				<link rel="hub" href="`+hubURLTest+`">
+				<link rel="hub" href="`+otherHubURL+`">
+				<link rel="self" href="`+topicURLTest+`">
+			</head></html>`)
+			resp.Header.Set("Content-Type", "text/html")
+			return resp, nil
+		})
+
+	callbacks := make(map[string]string)
+	for _, hub := range []string{hubURLTest, otherHubURL} {
+		hub := hub
+		httpmock.RegisterResponder("POST", hub,
+			func(req *http.Request) (*http.Response, error) {
+				bdy, _ := ioutil.ReadAll(req.Body)
+				vals, _ := url.ParseQuery(string(bdy))
+				callbacks[hub] = vals.Get("hub.callback")
+				return httpmock.NewStringResponse(202, ""), nil
+			})
+	}
+
+	t.Run("Subscribing to one hub", func(t *testing.T) {
+		handles, err := sub.Subscribe(context.Background(), topicURLTest, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(handles) != 1 {
+			t.Fatalf("Expected 1 handle, but got %d", len(handles))
+		}
+
+		handle := handles[0]
+		if handle.Topic != topicURLTest {
+			t.Fatalf("Expected topic {%v} but got {%v}", topicURLTest, handle.Topic)
+		}
+		if handle.Callback != callbacks[handle.Hub] {
+			t.Fatalf("Expected callback {%v} but got {%v}", callbacks[handle.Hub], handle.Callback)
+		}
+
+		select {
+		case <-handle.Done():
+			t.Fatal("Handle resolved before the hub verified the subscription")
+		default:
+		}
+
+		verifyCallback(t, topicURLTest, handle.Callback, "subscribe")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := handle.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Subscribing to all hubs", func(t *testing.T) {
+		handles, err := sub.Subscribe(context.Background(), topicURLTest, &SubscribeOptions{Hubs: AllHubs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(handles) != 2 {
+			t.Fatalf("Expected 2 handles, but got %d", len(handles))
+		}
+
+		// One hub verifies, the other denies
+		verifyCallback(t, topicURLTest, handles[0].Callback, "subscribe")
+
+		data := make(url.Values)
+		data.Set("hub.mode", "denied")
+		data.Set("hub.topic", topicURLTest)
+		data.Set("hub.reason", "no thanks")
+		testClient := &http.Client{Transport: &http.Transport{}}
+		resp, err := testClient.Get("http://localhost:4000/callback/" + handles[1].Callback + "?" + data.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("Expected code 200 but received %d", resp.StatusCode)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := handles[0].Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err, ok := handles[1].Wait(ctx).(ErrSubscriptionDenied); !ok || err.Reason != "no thanks" {
+			t.Fatalf("Expected the subscription to be denied, but got {%v}", err)
+		}
+	})
+}
+
+func TestSubscriber_Subscribe_noHubs(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpmock.Activate()
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	httpmock.RegisterResponder("GET", topicURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			resp := httpmock.NewStringResponse(200, "")
+			resp.Header.Set("Link", "<"+topicURLTest+">; rel=\"self\"")
+			return resp, nil
+		})
+
+	_, err = sub.Subscribe(context.Background(), topicURLTest, nil)
+	if err != ErrNoHubs {
+		t.Fatalf("Expected {%v} but got {%v}", ErrNoHubs, err)
+	}
+}
+
+func TestSubscriber_Subscribe_fastHub(t *testing.T) {
+	cfg := NewConfig()
+	cfg.VerificationTimeout = 100 * time.Millisecond
+	sub, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpmock.Activate()
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	httpmock.RegisterResponder("GET", topicURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			resp := httpmock.NewStringResponse(200, "")
+			resp.Header.Set("Link", "<"+hubURLTest+">; rel=\"hub\", <"+topicURLTest+">; rel=\"self\"")
+			return resp, nil
+		})
+
+	// The hub verifies the subscription before it even answers the request
+	verifying := true
+	httpmock.RegisterResponder("POST", hubURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			bdy, _ := ioutil.ReadAll(req.Body)
+			vals, _ := url.ParseQuery(string(bdy))
+			if verifying {
+				verifyCallback(t, topicURLTest, vals.Get("hub.callback"), "subscribe")
+			}
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	handles, err := sub.Subscribe(context.Background(), topicURLTest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := handles[0].Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hubs that never verify leave the handle to time out
+	verifying = false
+	handles, err = sub.Subscribe(context.Background(), topicURLTest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handles[0].Wait(ctx); err != ErrVerificationTimeout {
+		t.Fatalf("Expected {%v} but got {%v}", ErrVerificationTimeout, err)
+	}
+}
+
+func TestSubscriber_Subscribe_leaseDuration(t *testing.T) {
+	cfg := NewConfig()
+	cfg.LeaseDuration = time.Hour
+	sub, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpmock.Activate()
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	httpmock.RegisterResponder("GET", topicURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			resp := httpmock.NewStringResponse(200, "")
+			resp.Header.Set("Link", `<`+hubURLTest+`>; rel="hub", <`+topicURLTest+`>; rel="self"`)
+			return resp, nil
+		})
+
+	var leaseSeconds string
+	httpmock.RegisterResponder("POST", hubURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			bdy, _ := ioutil.ReadAll(req.Body)
+			vals, _ := url.ParseQuery(string(bdy))
+			leaseSeconds = vals.Get("hub.lease_seconds")
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	// The lease of the config is requested by default
+	_, err = sub.Subscribe(context.Background(), topicURLTest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaseSeconds != "3600" {
+		t.Fatalf("Expected hub.lease_seconds {3600} but got {%v}", leaseSeconds)
+	}
+
+	// Callers can ask for another
+	handles, err := sub.Subscribe(context.Background(), topicURLTest, &SubscribeOptions{LeaseDuration: 10 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaseSeconds != "600" {
+		t.Fatalf("Expected hub.lease_seconds {600} but got {%v}", leaseSeconds)
+	}
+
+	// The hub clamps the lease to 3 seconds, and we remember both
+	verifyCallback(t, topicURLTest, handles[0].Callback, "subscribe")
+
+	subscription, err := sub.storage.GetSubscription(context.Background(), handles[0].Callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscription.RequestedLeaseSeconds != 600 || subscription.GrantedLeaseSeconds != 3 {
+		t.Fatalf("Expected a lease of 600s clamped to 3s, but got %ds clamped to %ds",
+			subscription.RequestedLeaseSeconds, subscription.GrantedLeaseSeconds)
+	}
+}
+
+func TestSubscriber_Subscribe_discoveryCache(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpmock.Activate()
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	discoveries := 0
+	httpmock.RegisterResponder("GET", topicURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			discoveries++
+			resp := httpmock.NewStringResponse(200, "")
+			resp.Header.Set("Link", `<`+hubURLTest+`>; rel="hub", <`+topicURLTest+`>; rel="self"`)
+			resp.Header.Set("Cache-Control", "max-age=60")
+			return resp, nil
+		})
+	httpmock.RegisterResponder("POST", hubURLTest, httpmock.NewStringResponder(202, ""))
+
+	// The second subscription reuses the hubs discovered by the first
+	for i := 0; i < 2; i++ {
+		if _, err := sub.Subscribe(context.Background(), topicURLTest, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if discoveries != 1 {
+		t.Fatalf("Expected the topic to be discovered once, but it was discovered %d times", discoveries)
+	}
+}
+
+func TestHubPolicy_limit(t *testing.T) {
+	cases := []struct {
+		policy   HubPolicy
+		hubs     int
+		expected int
+	}{
+		{FirstHub, 3, 1},
+		{AllHubs, 3, 3},
+		{HubPolicy(-5), 3, 3},
+		{HubPolicy(2), 3, 2},
+		{HubPolicy(5), 3, 3},
+	}
+
+	for _, c := range cases {
+		if limit := c.policy.limit(c.hubs); limit != c.expected {
+			t.Fatalf("Expected policy %d to subscribe to %d of %d hubs, but got %d", c.policy, c.expected, c.hubs, limit)
+		}
+	}
+}
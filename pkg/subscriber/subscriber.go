@@ -10,11 +10,17 @@ package subscriber
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/adamsanghera/go-websub/pkg/discovery"
 	"github.com/adamsanghera/go-websub/pkg/subscriber/api"
 
-	"github.com/adamsanghera/go-websub/pkg/subscriber/storage/sql"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage/memory"
+	"github.com/adamsanghera/go-websub/pkg/subscriber/subscriberpb"
 )
 
 // Subscriber creates, maintains, and release to topic hubs, following the websub protocol
@@ -29,19 +35,55 @@ type Subscriber struct {
 
 	websub api.API
 
+	// Discovers the hubs of topics, reusing earlier discoveries while they're fresh
+	discoveries *discovery.Cache
+
 	// Centralized source of truth for subscriptions
-	storage *sql.SQL
+	storage storage.Storage
+
+	// Lease requested for subscriptions, unless the caller asks for another
+	leaseDuration time.Duration
+
+	// Policy for retrying failed requests to hubs
+	retryPolicy *RetryPolicy
 
 	// Sticky subscription manager
-	stickySubscriptions map[string]context.CancelFunc
+	renewals *renewalScheduler
+
+	// Subscriptions that expired while we were down, re-subscribed to once Run starts the callback server
+	expired []*subscriberpb.Subscription
+
+	// Background rediscovery of topics, stopped on shutdown
+	stopRediscovery context.CancelFunc
+	rediscoveryDone chan struct{}
+
+	// Callbacks with an unsubscription request in flight, awaiting the hub's verification
+	unsubMut               sync.Mutex
+	pendingUnsubscriptions map[string]struct{}
+
+	// Handles returned by Subscribe, awaiting the hub's verification
+	handleMut           sync.Mutex
+	pendingHandles      map[string]*Handle
+	verificationTimeout time.Duration
+
+	// Application code that receives distributed content
+	handlerMut     sync.RWMutex
+	contentHandler ContentHandler
+
+	// The HubPolicy that each topic was subscribed to with, which rediscovery keeps to
+	policyMut   sync.Mutex
+	hubPolicies map[string]HubPolicy
+
+	// Content recently delivered to the application, which redundant hubs may deliver again
+	deduper *contentDeduper
 }
 
 // New creates and returns a new Subscriber from a given config object
 func New(cfg *Config) (*Subscriber, error) {
-	// Init our storage system
-	storage, err := sql.New(sql.NewConfig())
-	if err != nil {
-		return nil, err
+	// Keep subscriptions in memory, unless we were handed a storage system
+	stor := cfg.Backend
+	if stor == nil {
+		stor = memory.New()
 	}
 
 	// Init the http server needed to support callbacks
@@ -58,21 +100,64 @@ func New(cfg *Config) (*Subscriber, error) {
 		},
 	}
 
-	return &Subscriber{
-		transport:           transport,
-		client:              client,
-		callbackMux:         callbackMux,
-		callbackSrv:         callbackSrv,
-		storage:             storage,
-		stickySubscriptions: make(map[string]context.CancelFunc),
-	}, nil
+	sub := &Subscriber{
+		transport:     transport,
+		client:        client,
+		callbackMux:   callbackMux,
+		callbackSrv:   callbackSrv,
+		storage:       stor,
+		discoveries:   discovery.NewCache(cfg.Discoverer),
+		leaseDuration: cfg.LeaseDuration,
+		retryPolicy:   cfg.RetryPolicy,
+		deduper:       newContentDeduper(cfg.DedupeWindow),
+
+		verificationTimeout: cfg.VerificationTimeout,
+
+		pendingUnsubscriptions: make(map[string]struct{}),
+		pendingHandles:         make(map[string]*Handle),
+		hubPolicies:            make(map[string]HubPolicy),
+	}
+
+	if sub.retryPolicy == nil {
+		sub.retryPolicy = &RetryPolicy{MaxAttempts: 1}
+	}
+
+	sub.renewals = newRenewalScheduler(cfg.MaxConcurrentRenewals, sub.renew)
+
+	// Pick up the subscriptions of our previous life, if storage persisted any
+	if err := sub.resume(); err != nil {
+		sub.renewals.shutdown()
+		stor.Shutdown()
+		return nil, err
+	}
+
+	rediscoveryCtx, stopRediscovery := context.WithCancel(context.Background())
+	sub.stopRediscovery = stopRediscovery
+	sub.rediscoveryDone = make(chan struct{})
+	if cfg.RediscoveryInterval > 0 {
+		go sub.rediscoverLoop(rediscoveryCtx, cfg.RediscoveryInterval)
+	} else {
+		close(sub.rediscoveryDone)
+	}
+
+	return sub, nil
 }
 
 // Run starts the Subscriber's Callback Server,
 //   which effectively means that the subscriber is on.
+// Subscriptions that expired while the Subscriber was down are re-subscribed to, once the server is listening.
 func (sub *Subscriber) Run() error {
 	sub.callbackMux.HandleFunc("/callback/", sub.callbackSwitch)
-	return sub.callbackSrv.ListenAndServe()
+
+	listener, err := net.Listen("tcp", sub.callbackSrv.Addr)
+	if err != nil {
+		return err
+	}
+
+	// Hubs may verify resumed subscriptions right away, so they wait for the callbacks to be reachable
+	sub.resumeExpired()
+
+	return sub.callbackSrv.Serve(listener)
 }
 
 // GetHubsForTopic returns all hubs associated with a given topic
@@ -96,6 +181,10 @@ func (sub *Subscriber) Shutdown() error {
 		return fmt.Errorf("Failed to shutdown callback Server %v", err)
 	}
 
+	// Stop renewing and rediscovering, but leave the subscriptions themselves alone, so that they can be resumed
+	sub.renewals.shutdown()
+	sub.stopRediscovery()
+	<-sub.rediscoveryDone
+
 	return sub.storage.Shutdown()
-	// TODO(adam) cancel all of our subscriptions
 }
@@ -1,5 +1,56 @@
 package subscriber
 
+import (
+	"context"
+	"testing"
+
+	"github.com/adamsanghera/go-websub/pkg/subscriber/storage/memory"
+)
+
+// countingBackend is a storage backend that counts the offers indexed through it
+type countingBackend struct {
+	*memory.Memory
+	offers   int
+	shutdown bool
+}
+
+func (b *countingBackend) IndexOffer(ctx context.Context, topicsToHubs map[string][]string) error {
+	for _, hubs := range topicsToHubs {
+		b.offers += len(hubs)
+	}
+	return b.Memory.IndexOffer(ctx, topicsToHubs)
+}
+
+func (b *countingBackend) Shutdown() error {
+	b.shutdown = true
+	return b.Memory.Shutdown()
+}
+
+func TestNew_backend(t *testing.T) {
+	backend := &countingBackend{Memory: memory.New()}
+
+	cfg := NewConfig()
+	cfg.Backend = backend
+	sub, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sub.storage.IndexOffer(context.Background(), map[string][]string{topicURLTest: {hubURLTest}}); err != nil {
+		t.Fatal(err)
+	}
+	if backend.offers != 1 {
+		t.Fatalf("Expected the offer to be indexed through the backend, but it indexed %d", backend.offers)
+	}
+
+	if err := sub.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if !backend.shutdown {
+		t.Fatal("Expected the backend to be shut down along with the Subscriber")
+	}
+}
+
 // import (
 // 	"testing"
 // )
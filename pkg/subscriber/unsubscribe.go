@@ -1,49 +1,112 @@
 package subscriber
 
 import (
-	"errors"
+	"context"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// unsubscribe unsubs from the given topic url.
-// If that topic has no associated url, returns an error
+// Unsubscribe asks the given hub to end our active subscription to the given topic.
+// The subscription stops being renewed right away, but it is only invalidated once the hub
+// verifies the unsubscription on its callback. If the request fails, renewals resume.
 // Handles redirect responses (307 and 308) gracefully
+// Retries failed requests according to the Subscriber's RetryPolicy
 // Gracefully passes any errors up
-func (sc *Subscriber) unsubscribe(topic string) error {
+// The context bounds the whole request, retries included.
+func (sub *Subscriber) Unsubscribe(ctx context.Context, topic, hub string) error {
+	callback, err := sub.storage.GetActiveCallback(ctx, topic, hub)
+	if err != nil {
+		return err
+	}
 
-	// TODO(adam) actually do this
-	// if topicURL, ok := sc.topicsToSelf[topic]; ok {
+	sub.renewals.cancel(callback)
 
-	// 	sc.ttsMut.Unlock() // It is ok if this state changes from underneath us
+	sub.unsubMut.Lock()
+	sub.pendingUnsubscriptions[callback] = struct{}{}
+	sub.unsubMut.Unlock()
 
-	// 	callback := generateCallback()
-	// 	req := buildUnsubscriptionRequest(callback, topicURL)
+	err = sub.withRetries(ctx, topic, hub, func(hub string, chain *redirectChain) error {
+		return sub.unsubscribe(ctx, topic, hub, callback, chain)
+	})
+	if err != nil {
+		sub.takePendingUnsubscription(callback)
+		sub.rescheduleRenewal(callback)
+		return err
+	}
 
-	// 	resp, err := http.DefaultClient.Do(req)
-	// 	if err != nil {
-	// 		panic(err)
-	// 	}
+	return nil
+}
+
+// rescheduleRenewal re-arms the renewal of a callback whose unsubscription failed, so that its lease doesn't run out
+func (sub *Subscriber) rescheduleRenewal(callback string) {
+	subscription, err := sub.storage.GetSubscription(context.Background(), callback)
+	if err != nil {
+		// The lease is no longer active, so there is nothing left to renew
+		return
+	}
+	sub.renewals.schedule(callback, time.Unix(subscription.LeaseExpiration, 0))
+}
+
+// unsubscribe sends the unsubscription request for the given callback to the hub, following redirects.
+func (sub *Subscriber) unsubscribe(ctx context.Context, topic, hub, callback string, chain *redirectChain) error {
+	resp, err := sub.sendUnsubscriptionRequest(ctx, topic, hub, callback)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	code := resp.StatusCode
 
-	// 	return sc.processSubscriptionResponse(resp, topicURL, callback, true)
-	// }
+	// ACK
+	if code == 202 {
+		return nil
+	}
 
-	// sc.ttsMut.Unlock()
-	return errors.New("No URL known for the given topic")
+	// Redirect
+	if code == 307 || code == 308 {
+		newHub, err := sub.followRedirect(ctx, topic, hub, resp, chain)
+		if err != nil {
+			return err
+		}
+		return sub.unsubscribe(ctx, topic, newHub, callback, chain)
+	}
+
+	return newErrHubStatus(resp)
 }
 
-// builds a pub-sub compliant subscription request, given a topic url and callback
-func buildUnsubscriptionRequest(callback string, topic string) *http.Request {
+// sends a pub-sub compliant unsubscription request to the hub, given a topic url and callback
+func (sub *Subscriber) sendUnsubscriptionRequest(ctx context.Context, topic, hub, callback string) (*http.Response, error) {
 	data := make(url.Values)
 	data.Set("hub.callback", callback)
 	data.Set("hub.mode", "unsubscribe")
 	data.Set("hub.topic", topic)
 
-	req, _ := http.NewRequest("POST", topic, strings.NewReader(data.Encode()))
+	req, _ := http.NewRequest("POST", hub, strings.NewReader(data.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Content-Length", strconv.Itoa(len(data.Encode())))
 
-	return req
+	resp, err := sub.client.Do(req.WithContext(ctx))
+	if err != nil {
+		// Requests cut short by the context report the context's error, rather than the transport's
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// takePendingUnsubscription removes the given callback from the set of pending unsubscriptions.
+// It reports whether an unsubscription was actually pending.
+func (sub *Subscriber) takePendingUnsubscription(callback string) bool {
+	sub.unsubMut.Lock()
+	defer sub.unsubMut.Unlock()
+
+	_, pending := sub.pendingUnsubscriptions[callback]
+	delete(sub.pendingUnsubscriptions, callback)
+	return pending
 }
@@ -0,0 +1,280 @@
+package subscriber
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestSubscriber_Unsubscribe(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	var callback, mode string
+	httpmock.RegisterResponder("POST", hubURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			bdy, _ := ioutil.ReadAll(req.Body)
+			vals, _ := url.ParseQuery(string(bdy))
+			callback = vals.Get("hub.callback")
+			mode = vals.Get("hub.mode")
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unsubscribing without a subscription fails
+	err = sub.Unsubscribe(context.Background(), topicURLTest, hubURLTest)
+	if err != sql.ErrNoRows {
+		t.Fatalf("Expected {%v} but got {%v}", sql.ErrNoRows, err)
+	}
+
+	err = sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, topicURLTest, callback, "subscribe")
+	subscribedCallback := callback
+
+	err = sub.Unsubscribe(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != "unsubscribe" {
+		t.Fatalf("Bad mode %v instead of %v", mode, "unsubscribe")
+	}
+	if callback != subscribedCallback {
+		t.Fatalf("Unsubscribed callback %v instead of %v", callback, subscribedCallback)
+	}
+
+	// The subscription is still active, until the hub verifies
+	_, err = sub.storage.GetActiveCallback(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyCallback(t, topicURLTest, callback, "unsubscribe")
+
+	_, err = sub.storage.GetActiveCallback(context.Background(), topicURLTest, hubURLTest)
+	if err != sql.ErrNoRows {
+		t.Fatalf("Expected {%v} but got {%v}", sql.ErrNoRows, err)
+	}
+}
+
+func TestSubscriber_Unsubscribe_redirect(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	var callback string
+	httpmock.RegisterResponder("POST", hubURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			bdy, _ := ioutil.ReadAll(req.Body)
+			vals, _ := url.ParseQuery(string(bdy))
+			callback = vals.Get("hub.callback")
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, topicURLTest, callback, "subscribe")
+
+	// The hub has moved, and will now unsubscribe us from its new location
+	redirectDest := "http://perm_hub.com/hub"
+	setupPermRedirect(hubURLTest, redirectDest, t, func(req *http.Request) error {
+		reqBody, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		data, err := url.ParseQuery(string(reqBody))
+		if err != nil {
+			return err
+		}
+		if data.Get("hub.mode") != "unsubscribe" {
+			return fmt.Errorf("Bad mode %v instead of %v", data.Get("hub.mode"), "unsubscribe")
+		}
+		return nil
+	})
+	setupDummyValidationAck(redirectDest)
+
+	err = sub.Unsubscribe(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyCallback(t, topicURLTest, callback, "unsubscribe")
+
+	_, err = sub.storage.GetActiveCallback(context.Background(), topicURLTest, hubURLTest)
+	if err != sql.ErrNoRows {
+		t.Fatalf("Expected {%v} but got {%v}", sql.ErrNoRows, err)
+	}
+}
+
+func TestSubscriber_unrequestedUnsubscribe(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	var callback string
+	httpmock.RegisterResponder("POST", hubURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			bdy, _ := ioutil.ReadAll(req.Body)
+			vals, _ := url.ParseQuery(string(bdy))
+			callback = vals.Get("hub.callback")
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, topicURLTest, callback, "subscribe")
+
+	// The hub tries to verify an unsubscription that we never asked for
+	data := make(url.Values)
+	data.Set("hub.mode", "unsubscribe")
+	data.Set("hub.topic", topicURLTest)
+	data.Set("hub.challenge", "kitties")
+
+	req, _ := http.NewRequest("POST", "http://localhost:4000/callback/"+callback, strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	testClient := &http.Client{Transport: &http.Transport{}}
+	resp, err := testClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("Expected code 404 but received %d", resp.StatusCode)
+	}
+
+	_, err = sub.storage.GetActiveCallback(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubscriber_Unsubscribe_failed(t *testing.T) {
+	sub, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpmock.ActivateNonDefault(sub.client)
+	defer httpmock.DeactivateAndReset()
+	defer func() {
+		if err := sub.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	runSubscriber(t, sub)
+
+	var callback string
+	httpmock.RegisterResponder("POST", hubURLTest,
+		func(req *http.Request) (*http.Response, error) {
+			bdy, _ := ioutil.ReadAll(req.Body)
+			vals, _ := url.ParseQuery(string(bdy))
+			if vals.Get("hub.mode") == "unsubscribe" {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			callback = vals.Get("hub.callback")
+			return httpmock.NewStringResponse(202, ""), nil
+		})
+
+	err = sub.storage.IndexOffer(context.Background(), map[string][]string{
+		topicURLTest: {hubURLTest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sub.initiateSubscription(context.Background(), topicURLTest, hubURLTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCallback(t, topicURLTest, callback, "subscribe")
+
+	// The hub rejects the unsubscription, so the subscription keeps being renewed
+	err = sub.Unsubscribe(context.Background(), topicURLTest, hubURLTest)
+	if err != (ErrHubStatus{StatusCode: 400}) {
+		t.Fatalf("Expected {%v} but got {%v}", ErrHubStatus{StatusCode: 400}, err)
+	}
+	if !sub.renewals.scheduled(callback) {
+		t.Fatal("Expected the renewal to be rescheduled after the unsubscription failed")
+	}
+	if sub.takePendingUnsubscription(callback) {
+		t.Fatal("Expected the failed unsubscription not to be pending")
+	}
+
+	// Retrying a hub that stays busy gives up once the context is done
+	httpmock.RegisterResponder("POST", hubURLTest, httpmock.NewStringResponder(503, ""))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := sub.Unsubscribe(ctx, topicURLTest, hubURLTest); err != context.DeadlineExceeded {
+		t.Fatalf("Expected {%v} but got {%v}", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the unsubscription to give up with its context, but it took {%v}", elapsed)
+	}
+}
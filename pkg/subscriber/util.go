@@ -2,15 +2,35 @@ package subscriber
 
 import (
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
-	"strconv"
-	"strings"
 	"testing"
+	"time"
 
 	httpmock "gopkg.in/jarcoal/httpmock.v1"
 )
 
+// runSubscriber launches the subscriber's callback server in the background.
+// It blocks until the server is accepting connections, so that tests can hit it right away.
+func runSubscriber(t *testing.T, sub *Subscriber) {
+	go func() {
+		if err := sub.Run(); err != http.ErrServerClosed {
+			t.Error(err)
+		}
+	}()
+
+	for attempt := 0; attempt < 100; attempt++ {
+		conn, err := net.Dial("tcp", "localhost"+sub.callbackSrv.Addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Callback server never started accepting connections")
+}
+
 func setupDummyValidationAck(hubURL string) {
 	httpmock.RegisterResponder("POST", hubURL,
 		func(req *http.Request) (*http.Response, error) {
@@ -42,11 +62,11 @@ func setupPermRedirect(hubURL, redirectURL string, t *testing.T, testExp func(re
 		})
 }
 
-// verifyCallback will send a verification POST to the given callback.
+// verifyCallback will send a verification GET to the given callback, as the spec describes.
 // It expects to receive a parrotted challenge in response.
-// httpmock is deactivated initially (to hit the callback), and reactivated on exit.
+// The request is made with a dedicated transport, so that it reaches the callback even while httpmock is active.
 func verifyCallback(t *testing.T, topicURL string, callback string, mode string) {
-	// Message body
+	// Query parameters
 	data := make(url.Values)
 	data.Set("hub.mode", mode)
 	data.Set("hub.topic", topicURL)
@@ -54,22 +74,17 @@ func verifyCallback(t *testing.T, topicURL string, callback string, mode string)
 	data.Set("hub.lease_seconds", "3")
 
 	// Request itself
-	req, err := http.NewRequest("POST", "http://localhost:4000/callback/"+callback, strings.NewReader(data.Encode()))
+	req, err := http.NewRequest("GET", "http://localhost:4000/callback/"+callback+"?"+data.Encode(), nil)
 	if err != nil {
 		panic(err)
 	}
 
-	// Headers
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Content-Length", strconv.Itoa(len(data.Encode())))
-
-	httpmock.Deactivate()
 	// Make the request
-	resp, err := http.DefaultClient.Do(req)
+	testClient := &http.Client{Transport: &http.Transport{}}
+	resp, err := testClient.Do(req)
 	if err != nil {
 		panic(err)
 	}
-	httpmock.Activate()
 
 	// Should be a 200
 	if resp.StatusCode != 200 {